@@ -0,0 +1,52 @@
+package main
+
+//
+// Runs consensus acceptance test scenarios written in the step language of package catdsl,
+// instead of one Go main per test:
+//
+//	go run CAT_suite.go                                - runs all the scenarios of CAT.suite
+//	go run CAT_suite.go my.suite                       - runs all the scenarios of a suite file (*.suite)
+//	go run CAT_suite.go DSL_CAT_304_S1S2S3_IQ_R1R2_IQ  - runs one scenario, given by its CAT name
+//	go run CAT_suite.go "CAT_999: S1_I50@0_Q_R1_Ic_Q"
+//
+// Each scenario sets up its own network, like a CAT main, and writes its PASSED/FAILED/ABORTED
+// line to GO_TESTS_SUMMARY. Use ../automation/go_record.sh CAT_suite.go to record the output.
+//
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"obcsdk/catdsl"
+)
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"CAT.suite"}
+	}
+	var scenarios []catdsl.Scenario
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".suite") {
+			suite, err := catdsl.LoadSuite(arg)
+			if err != nil {
+				fmt.Println("CAT_suite: ERROR: " + err.Error())
+				os.Exit(1)
+			}
+			scenarios = append(scenarios, suite...)
+			continue
+		}
+		scenario, err := catdsl.Parse(arg)
+		if err != nil {
+			fmt.Println("CAT_suite: ERROR: " + err.Error())
+			os.Exit(1)
+		}
+		scenarios = append(scenarios, scenario)
+	}
+
+	for i, scenario := range scenarios {
+		fmt.Printf("\nCAT_suite: scenario %d/%d: %s\n", i+1, len(scenarios), scenario.String())
+		catdsl.Run(scenario)
+	}
+}
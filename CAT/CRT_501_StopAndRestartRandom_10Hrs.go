@@ -26,15 +26,10 @@ import (
 	"bufio"
 	"obcsdk/chco2"
 	"fmt"
-	"strconv"
-	"obcsdk/threadutil"
 	// "bufio"
 	// "obcsdk/chaincode"
 	// "obcsdk/peernetwork"
 	// "log"
-	//"os/signal"
-	//"syscall"
-	"math/rand"
 )
 
 var osFile *os.File
@@ -80,17 +75,7 @@ func main() {
 	// Initialize everything, and start the network: deploy, invoke once on each peer, and query all peers to confirm
 	chco2.Setup( chco2.CurrentTestName, startTime )
 
- /*
-    // catch signals
-    c := make(chan os.Signal, 1)
-    signal.Notify(c, os.Interrupt)
-    signal.Notify(c, syscall.SIGTERM)
-    go func() {
-        <-c
-	chco2.TimeTrack(startTime, chco2.CurrentTestName)
-        os.Exit(1)
-    }()
- */
+	// chco2.Setup handles ^C (SIGINT) and SIGTERM: the test is reported ABORTED, and the network restored.
 
 
 	//=======================================================================================
@@ -163,34 +148,22 @@ func main() {
 
 	// CRT_501_StopAndRestartRandom_02Hrs.go
 
-	var cntr []int
-	cntr = make ( [] int, chco2.NumberOfPeersInNetwork )
-
-	for c := 0; c < chco2.NumberOfPeersInNetwork ; c++ { cntr[c] = 0 } 
+	// Each cycle stops a random peer, sends invokes and queries, then restarts it, sends invokes and queries.
+	// The peers are chosen by a seeded chaos run: it prints its seed, and CHCO2_CHAOS_SEED=<seed> replays it.
+	// (InvokeOnEachPeer is too quick/few for other peers to process and change view, to match our
+	// expectations; so send more, InvokesRequiredForCatchUp, and all are processed and test passes.)
 
 	numCycles := 400  // approx 10 hrs
-	for i:=1; i <= numCycles; i++ {
-			peerNum := rand.Intn(chco2.NumberOfPeersInNetwork)
-			cntr[peerNum] += 1
-			chco2.StopPeers( []int{ peerNum } )
-			// chco2.InvokeOnEachPeer( chco2.DefaultInvokesPerPeer ) 	// too quick/few for other peers to process and change view, to match
-			// 								// our expectations; so send more and all are procssed and test passes.
-			chco2.Invokes( chco2.InvokesRequiredForCatchUp )
-			// if (chco2.Verbose) { fmt.Println("Sleep extra 30 secs") }; time.Sleep(chco2.SleepTimeSeconds(30))
-			chco2.QueryAllPeers( "STEP 3, cycle " + strconv.Itoa(i) + "/" + strconv.Itoa(numCycles) + " after STOP " + threadutil.GetPeer(peerNum) + " and Invokes" )
-			chco2.RestartPeers( []int{ peerNum } )
-			chco2.InvokesUniqueOnEveryPeer()
-			chco2.QueryAllPeers( "STEP 6, cycle " + strconv.Itoa(i) + "/" + strconv.Itoa(numCycles) + " after RESTART and Invokes " )
-	}
+	chaos := chco2.NewChaos(0, 0)	// stops one peer at a time
+	chaos.Rounds = 2 * numCycles	// a STOP round and a RESTART round each cycle
+	chaos.MaxDown = 1
+	chco2.RunChaos(&chaos)
 
 	chco2.Invokes( chco2.InvokesRequiredForCatchUp + 5000 )
 	chco2.QueryAllPeers( "STEP FINAL, after many more invokes")
 
 	chco2.CatchUpAndConfirm()			// OPTIONAL. Depends on testcase details and objectives.
 
-	fmt.Println("\nTOTAL # times each peer was stopped during this test " + chco2.CurrentTestName)
-	for c := 0; c < chco2.NumberOfPeersInNetwork ; c++ { fmt.Println(threadutil.GetPeer(c) + "   " + strconv.Itoa(cntr[c])) }
-
 	chco2.RanToCompletion = true	// DO NOT MOVE OR CHANGE THIS. It must remain last.
 }
 
@@ -32,8 +32,6 @@ import (
 	// "obcsdk/chaincode"
 	// "obcsdk/peernetwork"
 	// "log"
-	//"os/signal"
-	//"syscall"
 )
 
 var osFile *os.File
@@ -79,17 +77,7 @@ func main() {
 	// Initialize everything, and start the network: deploy, invoke once on each peer, and query all peers to confirm
 	chco2.Setup( chco2.CurrentTestName, startTime )
 
- /*
-    // catch signals
-    c := make(chan os.Signal, 1)
-    signal.Notify(c, os.Interrupt)
-    signal.Notify(c, syscall.SIGTERM)
-    go func() {
-        <-c
-	chco2.TimeTrack(startTime, chco2.CurrentTestName)
-        os.Exit(1)
-    }()
- */
+	// chco2.Setup handles ^C (SIGINT) and SIGTERM: the test is reported ABORTED, and the network restored.
 
 
 	//=======================================================================================
@@ -23,7 +23,6 @@ package main
 import (
 	"os"
 	"time"
-	"bufio"
 	"obcsdk/chco2"
 	"fmt"
 	//"strconv"
@@ -52,22 +51,8 @@ func main() {
 
 	chco2.RanToCompletion = false
 	startTime := time.Now()
-	_, err := os.Stat(chco2.OutputSummaryFileName)	// Stat returns *FileInfo. It will return an error if there is no file.
-	if err != nil {
-		if os.IsNotExist(err) {
-			// File simply does not exist. Create the *File.
-			osFile, err = os.Create(chco2.OutputSummaryFileName)
-			chco2.Check(err)
-		} else {
-			chco2.Check(err)  // some other error; panic and exit.
-		}
-	} else {
-		// open the existing file
-		osFile, err = os.OpenFile(chco2.OutputSummaryFileName, os.O_RDWR|os.O_APPEND, 0666)
-		chco2.Check(err)
-	}
+	osFile = chco2.OpenOutputSummary()	// append the results to GO_TESTS_SUMMARY
 	defer osFile.Close()
-chco2.Writer = bufio.NewWriter(osFile)
 
 	// When main() ends, print the test PASS/FAIL line, with elapsed time, to outfile and to stdout
 	defer chco2.TimeTrack(startTime, chco2.CurrentTestName)
@@ -113,10 +98,25 @@ chco2.Writer = bufio.NewWriter(osFile)
 	//	QueryAllPeers(stepName string)
 	//	StopPeers(peerNums []int)
 	//	RestartPeers(peerNums []int)
+	//	StopPeersWithMode(peerNums []int, mode string)	// mode: chco2.DisruptStop, DisruptPause, DisruptKill, DisruptKillWipe
+	//	PrimaryPeer() int			// the current PBFT primary, from the view changes in the peer logs
+	//	BackupPeers() []int			// the running peers that are not the primary
+	//	StopPrimary() int
+	//	StopBackups(numBackups int) []int
 	//	QueryMatch(currA int, currB int)
 	//	SleepTimeSeconds(secs int) time.Duration
 	//	SleepTimeMinutes(minutes int) time.Duration
 	//	CatchUpAndConfirm()
+	//	PartitionPeers(peerGroups [][]int)
+	//	DropLink(peerNumA int, peerNumB int)
+	//	HealPartitions()
+	//	DegradePeers(peerNums []int, profile peernetwork.LinkProfile)
+	//	RestorePeersLinks(peerNums []int)
+	//	ThrottlePeers(peerNums []int, limits peernetwork.ResourceLimits)
+	//	UnthrottlePeers(peerNums []int)
+	//	InvokesWhileThrottled(peerNums []int, limits peernetwork.ResourceLimits, duration time.Duration, invokesPerRound int)
+	//	AddPeer(role string, peerID string) int	// role: peernetwork.RoleVP or RoleNVP; returns the new peer number
+	//	DecommissionPeer(peerNum int)
 	// To be implemented soon:
 	//	WaitAndConfirm()
 	//	PausePeers(peerNums []int)
@@ -135,6 +135,14 @@ chco2.Writer = bufio.NewWriter(osFile)
 	// if (chco2.Verbose) { fmt.Println("Sleep extra 60 secs") }
 	// time.Sleep(chco2.SleepTimeSeconds(60))
 	// time.Sleep(chco2.SleepTimeMinutes(1))
+	// primary := chco2.StopPrimary()
+	// chco2.RestartPeers( []int{ primary } )
+	// chco2.PartitionPeers( [][]int{ {0, 1, 2}, {3} } )
+	// chco2.HealPartitions()
+	// chco2.DegradePeers( []int{ 3 }, peernetwork.SlowLink )
+	// chco2.InvokesWhileThrottled( []int{ 2 }, peernetwork.ResourceLimits{CPUPercent: 10}, chco2.SleepTimeMinutes(5), 10 )
+	// chco2.DecommissionPeer( 2 )
+	// newPeer := chco2.AddPeer( peernetwork.RoleVP, "vp2" )	// a brand-new node takes the place of replica 2
 	// 
 	//=======================================================================================
 
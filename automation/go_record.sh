@@ -71,6 +71,14 @@ echo -e "REPOSITORY_SOURCE used by GO SDK to retrieve the fabric COMMIT image: $
 echo -e "CHCO2_VERBOSE: $CHCO2_VERBOSE"
 echo -e "CHCO2_FULL_CATCHUP: $CHCO2_FULL_CATCHUP"
 echo -e "CHCO2_EXISTING_NETWORK: $CHCO2_EXISTING_NETWORK"
+echo -e "CHCO2_WAIT_CATCHUP_BLOCKS: $CHCO2_WAIT_CATCHUP_BLOCKS"
+echo -e "PEER_READY_TIMEOUT: $PEER_READY_TIMEOUT"
+echo -e "NET_TOOLS_IMAGE: $NET_TOOLS_IMAGE"
+echo -e "NETWORK_PROFILE: $NETWORK_PROFILE   NETWORK_PROFILES: $NETWORK_PROFILES"
+echo -e "REQUEST_ROUTING: $REQUEST_ROUTING   CHCO2_NVPS: $CHCO2_NVPS"
+echo -e "OBCSDK_HOME: $OBCSDK_HOME   NETWORK_CREDENTIALS: $NETWORK_CREDENTIALS   CC_COLLECTION: $CC_COLLECTION   OBCSDK_AUTOMATION: $OBCSDK_AUTOMATION"
+echo -e "CHCO2_ARTIFACTS: $CHCO2_ARTIFACTS   CHCO2_ARTIFACTS_DIR: $CHCO2_ARTIFACTS_DIR"
+echo -e "CHCO2_HEALTH_MONITOR: $CHCO2_HEALTH_MONITOR   CHCO2_HEALTH_INTERVAL: $CHCO2_HEALTH_INTERVAL"
 
 # Finally, let's show the commands parameters passed to each docker container
 # when we execute "docker run" with the commands "peer node start"
@@ -145,7 +153,9 @@ To override any default script parameters: set and export them from your ENV, or
 	CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN 	- consensus mode [ pbft | ... ]
 	CORE_PBFT_GENERAL_MODE 			- pbft mode [ batch | noops ]
 	CORE_PBFT_GENERAL_BATCHSIZE 		- max # Tx sent in each batch for ordering (Although code dflt=500, this script sets 2 unless overridden)
-	STOP_OR_PAUSE 				- MODE used by GO tests when disrupting network CA and PEER nodes [ STOP | PAUSE ]
+	STOP_OR_PAUSE 				- MODE used by GO tests when disrupting network CA and PEER nodes [ STOP | PAUSE | KILL | KILL_WIPE ]
+	NET_TOOLS_IMAGE 			- image with iptables and tc, run in a peer network namespace to inject link faults [ nicolaka/netshoot ]
+	PEER_INTERFACE 				- network interface of the peer containers, where degraded links are applied [ eth0 ]
 
 Examples:
 
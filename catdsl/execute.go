@@ -0,0 +1,239 @@
+package catdsl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/chco2"
+	"obcsdk/peernetwork"
+)
+
+/*
+  Runs scenarios with chco2: each step is the chco2 call a CAT main would make, e.g. S1S2 is
+  chco2.StopPeers([]int{1, 2}), I100 is chco2.Invokes(100), Q is chco2.QueryAllPeers.
+*/
+
+const DefaultConvergeSeconds = 120	// the timeout of a converge step without seconds
+
+type executor struct {
+	stepNum   int		// numbers the query steps, like the STEP numbers of the CAT mains
+	since     []string	// steps since the last query, to name the next one
+	deploys   int		// deploys so far, to choose new values for D
+	loopVar   int
+	primary   int		// the peer that p was when it was disrupted, so Rp restarts that one; -1 if none
+}
+
+/*
+  runs a scenario as one test, like a CAT main: opens the output summary file, sets up the network
+  (deploy, an invoke on each peer, query), runs the steps, and reports PASSED/FAILED/ABORTED with
+  chco2.TimeTrack, which also restores the network.
+*/
+func Run(scenario Scenario) {
+	chco2.CurrentTestName = scenario.Name
+	chco2.RanToCompletion = false
+	startTime := time.Now()
+
+	osFile := chco2.OpenOutputSummary()
+	defer osFile.Close()
+
+	// When Run ends, print the test PASS/FAIL line, with elapsed time, to outfile and to stdout
+	defer chco2.TimeTrack(startTime, chco2.CurrentTestName)
+
+	var oracle chco2.Oracle		// nil: Setup uses the one named by CHCO2_ORACLE
+	if scenario.Oracle != "" {
+		oracle, _ = chco2.NewOracle(scenario.Oracle)
+	}
+	chco2.SetOracle(oracle)
+	chco2.Setup(chco2.CurrentTestName, startTime)
+	fmt.Println("Scenario " + scenario.String())
+	if err := Execute(scenario); err != nil {
+		fmt.Println("catdsl.Run(): ERROR: " + err.Error())
+		return		// not RanToCompletion: ABORTED
+	}
+	chco2.RanToCompletion = true
+}
+
+/*
+  runs the steps of a scenario on the network chco2 has set up.
+*/
+func Execute(scenario Scenario) error {
+	oracle := scenario.Oracle
+	if oracle == "" {
+		oracle = strings.TrimSpace(os.Getenv("CHCO2_ORACLE"))	// the oracle Setup chose
+	}
+	if err := checkDeploys(scenario.Steps, oracle); err != nil {
+		return errors.New("scenario " + scenario.Name + ": " + err.Error())
+	}
+	e := &executor{primary: -1}
+	return e.run(scenario.Steps)
+}
+
+func (e *executor) run(steps []Step) error {
+	for _, step := range steps {
+		if err := e.do(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *executor) do(step Step) error {
+	if step.Op == OpLoop {
+		return e.loop(step)
+	}
+	restart := step.Op == OpRestart || step.Op == OpUnpause
+	peers, err := e.peerNums(step.Peers, restart)
+	if err != nil {
+		return err
+	}
+	if step.Op != OpQuery {
+		e.since = append(e.since, e.resolved(step, peers).String())
+	}
+	switch step.Op {
+	case OpStop:
+		chco2.StopPeers(peers)
+	case OpKill:
+		chco2.StopPeersWithMode(peers, chco2.DisruptKill)
+	case OpKillWipe:
+		chco2.StopPeersWithMode(peers, chco2.DisruptKillWipe)
+	case OpPause:
+		chco2.StopPeersWithMode(peers, chco2.DisruptPause)
+	case OpUnpause, OpRestart:
+		chco2.RestartPeers(peers)	// restarts each peer the way it was disrupted, e.g. unpauses a paused one
+	case OpDecomm:
+		for _, peerNum := range peers {
+			chco2.DecommissionPeer(peerNum)
+		}
+	case OpAdd:
+		for _, peerNum := range peers {
+			if chco2.AddPeer(peernetwork.RoleVP, "vp"+strconv.Itoa(peerNum)) < 0 {
+				return errors.New("step " + step.String() + ": could not add vp" + strconv.Itoa(peerNum))
+			}
+		}
+	case OpInvoke:
+		switch {
+		case step.OnPeer != "":
+			peerNum, err := e.peerNum(step.OnPeer, false)
+			if err != nil {
+				return err
+			}
+			chco2.InvokeOnThisPeer(step.Count, peerNum)
+		case step.Count < 0:
+			chco2.Invokes(chco2.InvokesRequiredForCatchUp)
+		case step.Count == 0:
+			chco2.InvokeOnEachPeer(chco2.DefaultInvokesPerPeer)
+		default:
+			chco2.Invokes(step.Count)
+		}
+	case OpQuery:
+		e.stepNum++
+		stepName := "STEP " + strconv.Itoa(e.stepNum)
+		if len(e.since) > 0 {
+			stepName += ", after " + strings.Join(e.since, " ")
+		}
+		chco2.QueryAllPeers(stepName)
+		e.since = nil
+	case OpDeploy:
+		// DeployNew works as expected only with new values; else it talks to the old chaincode instance
+		e.deploys++
+		value := step.Count
+		if value == 0 {
+			value = 5000 * e.deploys
+		}
+		chco2.DeployNew(value, value)
+	case OpWait:
+		if chco2.Verbose { fmt.Println("Sleep extra " + strconv.Itoa(step.Count) + " secs") }
+		time.Sleep(chco2.SleepTimeSeconds(step.Count))
+	case OpCatchUp:
+		chco2.CatchUpAndConfirm()
+	case OpConverge:
+		secs := step.Count
+		if secs == 0 {
+			secs = DefaultConvergeSeconds
+		}
+		chco2.EventuallyAtLeastConsensusAgree(chco2.SleepTimeSeconds(secs))
+	case OpStrict:
+		chco2.AllRunningNodesMustMatch = true
+	case OpLenient:
+		chco2.AllRunningNodesMustMatch = false
+	default:
+		return errors.New("unknown step " + step.Op)
+	}
+	return nil
+}
+
+func (e *executor) loop(step Step) error {
+	if step.Times > 0 {
+		for i := 0; i < step.Times; i++ {
+			if err := e.run(step.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	to := step.To
+	if to == LastPeer {
+		to = chco2.NumberOfPeersInNetwork - 1
+	}
+	outer := e.loopVar
+	defer func() { e.loopVar = outer }()
+	increment := 1
+	if step.From > to {
+		increment = -1
+	}
+	for n := step.From; n != to+increment; n += increment {
+		e.loopVar = n
+		if err := e.run(step.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *executor) peerNums(refs []PeerRef, restart bool) ([]int, error) {
+	var peers []int
+	for _, ref := range refs {
+		peerNum, err := e.peerNum(ref, restart)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, peerNum)
+	}
+	return peers, nil
+}
+
+func (e *executor) peerNum(ref PeerRef, restart bool) (int, error) {
+	switch ref {
+	case LoopVar:
+		return e.loopVar, nil
+	case Primary:
+		if restart && e.primary >= 0 {
+			return e.primary, nil
+		}
+		e.primary = chco2.PrimaryPeer()
+		return e.primary, nil
+	}
+	peerNum, err := strconv.Atoi(string(ref))
+	if err != nil || peerNum >= chco2.NumberOfPeersInNetwork {
+		return 0, errors.New("no peer " + string(ref) + " in a network of " + strconv.Itoa(chco2.NumberOfPeersInNetwork) + " peers")
+	}
+	return peerNum, nil
+}
+
+/*
+  the step with its peers resolved to peer numbers, to describe what was done.
+*/
+func (e *executor) resolved(step Step, peers []int) Step {
+	step.Peers = nil
+	for _, peerNum := range peers {
+		step.Peers = append(step.Peers, PeerRef(strconv.Itoa(peerNum)))
+	}
+	if step.OnPeer == LoopVar {
+		step.OnPeer = PeerRef(strconv.Itoa(e.loopVar))
+	}
+	return step
+}
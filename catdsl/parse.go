@@ -0,0 +1,482 @@
+package catdsl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"obcsdk/chco2"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  A step language for consensus acceptance tests, the one the CAT filenames already use:
+  CAT_304_S1S2S3_IQ_R1R2_IQ means stop peers 1, 2 and 3; invoke and query; restart peers 1 and 2;
+  invoke and query. A scenario is one line, e.g. in a suite file, instead of a new Go main.
+
+  Steps (underscores and spaces only separate them, for readability):
+	S<peer>		stop a peer			S1S2S3 stops peers 1, 2 and 3 together (one StopPeers call)
+	K<peer>		kill a peer (SIGKILL)		KW<peer>  kill it and wipe its ledger
+	P<peer>		pause a peer			U<peer>   unpause it
+	R<peer>		restart a stopped, killed or paused peer
+	DC<peer>	decommission a peer		ADD<peer> add a validating peer with replica id <peer>, e.g. after DC<peer>
+	I		one invoke on each running peer (DefaultInvokesPerPeer)
+	I<count>	<count> invokes spread over the running peers; Ic sends InvokesRequiredForCatchUp
+	I<count>@<peer>	<count> invokes on one peer
+	Q		query all peers and validate the chaincode values (A and B of example02) and the chain heights
+	D		deploy a new example02 instance with new values; D<value> uses A=B=<value> (example02 oracle only)
+	W<secs>		wait
+	catchup		invokes until the peers catch up, then query (CatchUpAndConfirm)
+	converge<secs>	assert that enough peers for consensus agree within <secs> (EventuallyAtLeastConsensusAgree);
+			converge alone waits up to DefaultConvergeSeconds
+	strict		all running peers must match from now on; lenient: enough peers for consensus
+	(<steps>)x<k>		repeat the steps k times
+	(<steps>)n=<a>..<b>	repeat the steps for n = a to b (counting down if a > b); b may be "last"
+  A <peer> is a peer number, n (the loop variable), or p (the current PBFT primary; in R and U, the
+  peer that p was when it was disrupted).
+
+  Suite files have one scenario per line: "<name>: <steps>", or a CAT name, whose steps follow the
+  CAT_<number>_ prefix; a DSL_ in front (DSL_CAT_304_S1S2S3_IQ_R1R2_IQ) names the scenario apart from
+  the Go main of the same test, which may run more steps than its name tells. Optionally followed by tags, "@<tag>", a timeout, "@timeout=<duration>", and the
+  chaincode model (chco2.NewOracle), "@oracle=<name>", e.g.  CAT_304_S1S2S3_IQ_R1R2_IQ @stop @timeout=30m
+  @oracle=addrecs.  Blank lines and lines starting with # are ignored.
+*/
+
+const (
+	OpStop      = "S"
+	OpKill      = "K"
+	OpKillWipe  = "KW"
+	OpPause     = "P"
+	OpUnpause   = "U"
+	OpRestart   = "R"
+	OpDecomm    = "DC"
+	OpAdd       = "ADD"
+	OpInvoke    = "I"
+	OpQuery     = "Q"
+	OpDeploy    = "D"
+	OpWait      = "W"
+	OpCatchUp   = "catchup"
+	OpConverge  = "converge"
+	OpStrict    = "strict"
+	OpLenient   = "lenient"
+	OpLoop      = "loop"
+)
+
+const (
+	LoopVar = "n"		// peer reference to the loop variable
+	Primary = "p"		// peer reference to the current primary
+	LastPeer = -1		// loop bound "last": the highest peer number
+)
+
+// A peer reference: a peer number, LoopVar or Primary
+type PeerRef string
+
+type Step struct {
+	Op       string
+	Peers    []PeerRef	// the peers of a disruption step, in order
+	Count    int		// invokes (0: one on each peer, -1: InvokesRequiredForCatchUp), deploy value (0: new values), wait or converge seconds
+	OnPeer   PeerRef	// invokes on this peer only, if not ""
+	Body     []Step		// steps of a loop
+	Times    int		// a loop repeated Times times, or
+	From, To int		// a loop over n = From..To (To may be LastPeer), when Times is 0
+}
+
+type Scenario struct {
+	Name    string
+	Steps   []Step
+	Tags    []string	// to select scenarios, e.g. with catrun -tags
+	Timeout time.Duration	// 0 for no timeout of its own
+	Oracle  string		// the built-in chco2 oracle to use, e.g. addrecs; "" for the chco2 default
+}
+
+var catName = regexp.MustCompile(`^(DSL_)?(CAT|CRT)_[0-9]+_`)
+
+/*
+  parses one scenario: "<name>: <steps>", a CAT name such as (DSL_)CAT_304_S1S2S3_IQ_R1R2_IQ(.go),
+  or only the steps (the name is then the steps).
+*/
+func Parse(line string) (Scenario, error) {
+	var scenario Scenario
+	fields := strings.Fields(line)
+	for len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "@") {
+		tag := fields[len(fields)-1][1:]
+		fields = fields[:len(fields)-1]
+		if strings.HasPrefix(tag, "timeout=") {
+			timeout, err := time.ParseDuration(tag[len("timeout="):])
+			if err != nil {
+				return scenario, errors.New("invalid @" + tag + ": " + err.Error())
+			}
+			scenario.Timeout = timeout
+		} else if strings.HasPrefix(tag, "oracle=") {
+			scenario.Oracle = tag[len("oracle="):]
+			if _, err := chco2.NewOracle(scenario.Oracle); err != nil {
+				return scenario, errors.New("invalid @" + tag + ": " + err.Error())
+			}
+		} else if tag != "" {
+			scenario.Tags = append([]string{tag}, scenario.Tags...)
+		}
+	}
+	line = strings.Join(fields, " ")
+	steps := line
+	if i := strings.Index(line, ":"); i >= 0 {
+		scenario.Name = strings.TrimSpace(line[:i])
+		steps = line[i+1:]
+	} else {
+		steps = strings.TrimSuffix(line, ".go")
+		scenario.Name = steps
+		if prefix := catName.FindString(steps); prefix != "" {
+			steps = steps[len(prefix):]
+		}
+	}
+	p := &parser{text: steps}
+	body, err := p.steps(false)
+	if err != nil {
+		return scenario, fmt.Errorf("scenario %s: %s", scenario.Name, err.Error())
+	}
+	if len(body) == 0 {
+		return scenario, errors.New("scenario " + scenario.Name + ": no steps")
+	}
+	if err = checkLoopVar(body, false); err != nil {
+		return scenario, fmt.Errorf("scenario %s: %s", scenario.Name, err.Error())
+	}
+	if err = checkDeploys(body, scenario.Oracle); err != nil {
+		return scenario, fmt.Errorf("scenario %s: %s", scenario.Name, err.Error())
+	}
+	scenario.Steps = body
+	return scenario, nil
+}
+
+/*
+  the loop variable n may only be used inside a loop over n.
+*/
+func checkLoopVar(steps []Step, inLoop bool) error {
+	for _, step := range steps {
+		if step.Op == OpLoop {
+			if err := checkLoopVar(step.Body, inLoop || step.Times == 0); err != nil {
+				return err
+			}
+			continue
+		}
+		refs := append([]PeerRef{step.OnPeer}, step.Peers...)
+		for _, peer := range refs {
+			if peer == LoopVar && !inLoop {
+				return errors.New("step " + step.String() + " uses n outside a loop over n")
+			}
+		}
+	}
+	return nil
+}
+
+/*
+  a D step deploys example02 with new values of A and B: the steps may have one only with that oracle
+  (oracle "" is the example02 default).
+*/
+func checkDeploys(steps []Step, oracle string) error {
+	if !hasStep(steps, OpDeploy) {
+		return nil
+	}
+	model, err := chco2.NewOracle(oracle)	// chco2.Setup uses example02 instead of an unknown oracle
+	if _, ok := model.(*chco2.Example02Oracle); err == nil && !ok {
+		return errors.New("step " + OpDeploy + " deploys example02 with new values, which the oracle " + oracle + " has not")
+	}
+	return nil
+}
+
+func hasStep(steps []Step, op string) bool {
+	for _, step := range steps {
+		if step.Op == op || (step.Op == OpLoop && hasStep(step.Body, op)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  reads the scenarios of a suite file.
+*/
+func ParseSuite(reader io.Reader) ([]Scenario, error) {
+	var scenarios []Scenario
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		scenario, err := Parse(line)
+		if err != nil {
+			return scenarios, fmt.Errorf("line %d: %s", lineNum, err.Error())
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, scanner.Err()
+}
+
+func LoadSuite(path string) ([]Scenario, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scenarios, err := ParseSuite(file)
+	if err != nil {
+		return scenarios, errors.New(path + ": " + err.Error())
+	}
+	return scenarios, nil
+}
+
+/*
+  the steps again, in the step language (e.g. for a QueryAllPeers step name).
+*/
+func (step Step) String() string {
+	switch step.Op {
+	case OpLoop:
+		str := "("
+		for i, s := range step.Body {
+			if i > 0 {
+				str += "_"
+			}
+			str += s.String()
+		}
+		if step.Times > 0 {
+			return str + ")x" + strconv.Itoa(step.Times)
+		}
+		to := strconv.Itoa(step.To)
+		if step.To == LastPeer {
+			to = "last"
+		}
+		return str + ")n=" + strconv.Itoa(step.From) + ".." + to
+	case OpStop, OpKill, OpKillWipe, OpPause, OpUnpause, OpRestart, OpDecomm, OpAdd:
+		str := ""
+		for _, peer := range step.Peers {
+			str += step.Op + string(peer)
+		}
+		return str
+	case OpInvoke:
+		str := OpInvoke
+		if step.Count > 0 {
+			str += strconv.Itoa(step.Count)
+		} else if step.Count < 0 {
+			str += "c"
+		}
+		if step.OnPeer != "" {
+			str += "@" + string(step.OnPeer)
+		}
+		return str
+	case OpDeploy, OpWait, OpConverge:
+		if step.Count > 0 {
+			return step.Op + strconv.Itoa(step.Count)
+		}
+	}
+	return step.Op
+}
+
+func (scenario Scenario) String() string {
+	var steps []string
+	for _, s := range scenario.Steps {
+		steps = append(steps, s.String())
+	}
+	str := scenario.Name + ": " + strings.Join(steps, "_")
+	for _, tag := range scenario.Tags {
+		str += " @" + tag
+	}
+	if scenario.Timeout > 0 {
+		str += " @timeout=" + scenario.Timeout.String()
+	}
+	if scenario.Oracle != "" {
+		str += " @oracle=" + scenario.Oracle
+	}
+	return str
+}
+
+type parser struct {
+	text string
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at %q: %s", p.text[p.pos:], fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSeparators() {
+	for p.pos < len(p.text) && (p.text[p.pos] == '_' || p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) accept(prefix string) bool {
+	if strings.HasPrefix(p.text[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *parser) number() (int, bool) {
+	start := p.pos
+	for p.pos < len(p.text) && p.text[p.pos] >= '0' && p.text[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false
+	}
+	n, err := strconv.Atoi(p.text[start:p.pos])
+	return n, err == nil
+}
+
+func (p *parser) peerRef() (PeerRef, bool) {
+	start := p.pos
+	if _, ok := p.number(); ok {
+		return PeerRef(p.text[start:p.pos]), true
+	}
+	if p.accept(LoopVar) {
+		return LoopVar, true
+	}
+	if p.accept(Primary) {
+		return Primary, true
+	}
+	return "", false
+}
+
+/*
+  parses steps up to the end of the text, or up to the closing parenthesis of a loop.
+*/
+func (p *parser) steps(inLoop bool) ([]Step, error) {
+	var steps []Step
+	for {
+		p.skipSeparators()
+		if p.pos >= len(p.text) {
+			if inLoop {
+				return nil, errors.New("missing )")
+			}
+			return steps, nil
+		}
+		if p.text[p.pos] == ')' {
+			if !inLoop {
+				return nil, p.errorf("unexpected )")
+			}
+			return steps, nil
+		}
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+}
+
+func (p *parser) step() (Step, error) {
+	// longest names first: KW before K, DC before D, ADD before anything else
+	for _, op := range []string{OpCatchUp, OpStrict, OpLenient} {
+		if p.accept(op) {
+			return Step{Op: op}, nil
+		}
+	}
+	if p.accept(OpConverge) {
+		n, _ := p.number()
+		return Step{Op: OpConverge, Count: n}, nil
+	}
+	for _, op := range []string{OpAdd, OpDecomm, OpKillWipe, OpKill, OpStop, OpPause, OpUnpause, OpRestart} {
+		if p.accept(op) {
+			return p.disruption(op)
+		}
+	}
+	switch {
+	case p.accept("("):
+		return p.loop()
+	case p.accept(OpInvoke):
+		step := Step{Op: OpInvoke}
+		if p.accept("c") {
+			step.Count = -1
+		} else if n, ok := p.number(); ok {
+			if n == 0 {
+				return step, p.errorf("I0 sends no invokes")
+			}
+			step.Count = n
+		}
+		if p.accept("@") {
+			peer, ok := p.peerRef()
+			if !ok {
+				return step, p.errorf("expecting a peer after @")
+			}
+			if step.Count == 0 {
+				step.Count = 1
+			}
+			step.OnPeer = peer
+		}
+		return step, nil
+	case p.accept(OpQuery):
+		return Step{Op: OpQuery}, nil
+	case p.accept(OpDeploy):
+		n, _ := p.number()
+		return Step{Op: OpDeploy, Count: n}, nil
+	case p.accept(OpWait):
+		n, ok := p.number()
+		if !ok {
+			return Step{}, p.errorf("expecting the seconds to wait")
+		}
+		return Step{Op: OpWait, Count: n}, nil
+	}
+	return Step{}, p.errorf("unknown step")
+}
+
+/*
+  a disruption of one or more peers: S1S2S3 is one step stopping 1, 2 and 3.
+*/
+func (p *parser) disruption(op string) (Step, error) {
+	step := Step{Op: op}
+	for {
+		peer, ok := p.peerRef()
+		if !ok {
+			return step, p.errorf("expecting a peer after %s", op)
+		}
+		step.Peers = append(step.Peers, peer)
+		save := p.pos
+		if !p.accept(op) {
+			return step, nil
+		}
+		if op == OpKill && strings.HasPrefix(p.text[p.pos:], "W") {
+			p.pos = save	// K1KW2 is two steps
+			return step, nil
+		}
+	}
+}
+
+func (p *parser) loop() (Step, error) {
+	body, err := p.steps(true)
+	if err != nil {
+		return Step{}, err
+	}
+	if len(body) == 0 {
+		return Step{}, p.errorf("empty loop")
+	}
+	p.pos++		// the )
+	step := Step{Op: OpLoop, Body: body}
+	switch {
+	case p.accept("x"):
+		n, ok := p.number()
+		if !ok || n == 0 {
+			return step, p.errorf("expecting a number of repetitions after x")
+		}
+		step.Times = n
+	case p.accept(LoopVar + "="):
+		from, ok := p.number()
+		if !ok || !p.accept("..") {
+			return step, p.errorf("expecting n=<from>..<to>")
+		}
+		if p.accept("last") {
+			step.To = LastPeer
+		} else if step.To, ok = p.number(); !ok {
+			return step, p.errorf("expecting n=<from>..<to>")
+		}
+		step.From = from
+	default:
+		return step, p.errorf("expecting x<repetitions> or n=<from>..<to> after a loop")
+	}
+	return step, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+//
+// catrun: select and run consensus acceptance tests, and print one summary of their results.
+//
+//	go run catrun.go ../CAT                                  - all the scenarios of CAT.suite and all the CAT_*.go tests
+//	go run catrun.go -run 'CAT_3*' -timeout 45m ../CAT
+//	go run catrun.go -tags CAT1xx,stop -isolated ../CAT
+//	go run catrun.go -list ../CAT/CAT.suite
+//
+// Scenarios of the suite files run in this process (or, with -isolated, each in a child process);
+// the CAT_*.go tests run with go run. See ../catrunner/main.go for all the flags.
+//
+
+import (
+	"obcsdk/catrunner"
+)
+
+func main() {
+	catrunner.Main()
+}
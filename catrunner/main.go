@@ -0,0 +1,120 @@
+package catrunner
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"obcsdk/chco2"
+)
+
+/*
+  The runner command line, for catrun or for a test program that registers its own Go scenarios
+  with RegisterFunc and then calls Main:
+
+	catrun [flags] [<dir> | <file.suite> | <CAT_xxx.go>]...	(default: the current directory)
+	  -run CAT_3*,CAT_116_KW1_IQ_R1_IQcatchup	names or globs of the scenarios to run (default: all)
+	  -tags stop,CAT4xx				run only the scenarios with one of these tags
+	  -isolated					run each scenario in a process of its own
+	  -timeout 45m					per scenario, unless the suite gives one (@timeout=)
+	  -summary CAT_RUN_SUMMARY			file the consolidated summary is appended to
+	  -junit CAT_RUN_RESULTS.xml			JUnit XML results of the run, a testsuite per scenario ("": none)
+	  -json CAT_RUN_RESULTS.json			JSON results of the run, with the steps of each scenario ("": none)
+	  -list						list the selected scenarios, and run none
+
+  Every scenario is tagged with its kind (scenario, program or func) and its series (e.g. CAT3xx).
+  The exit status is 0 only if every selected scenario PASSED.
+*/
+func Main() {
+	run := flag.String("run", "", "comma-separated names or globs of the scenarios to run")
+	tags := flag.String("tags", "", "comma-separated tags: run the scenarios with one of them")
+	isolated := flag.Bool("isolated", false, "run each scenario in a process of its own")
+	timeout := flag.Duration("timeout", 0, "timeout of each scenario (0: none)")
+	summaryFile := flag.String("summary", "CAT_RUN_SUMMARY", "file to append the summary to")
+	junitFile := flag.String("junit", "CAT_RUN_RESULTS.xml", "file to write the JUnit XML results to")
+	jsonFile := flag.String("json", "CAT_RUN_RESULTS.json", "file to write the JSON results to")
+	list := flag.Bool("list", false, "list the selected scenarios")
+	child := flag.String("child", "", "(internal) run this scenario in this process")
+	flag.Parse()
+
+	places := flag.Args()
+	if len(places) == 0 {
+		places = []string{"."}
+	}
+	for _, place := range places {
+		if err := Discover(place); err != nil {
+			fmt.Println("catrunner: ERROR: " + err.Error())
+			os.Exit(2)
+		}
+	}
+
+	if *child != "" {
+		entry, found := Lookup(*child)
+		if !found {
+			fmt.Println("catrunner: ERROR: no scenario " + *child)
+			os.Exit(2)
+		}
+		runEntry(entry)
+		if chco2.TestResult != StatusPassed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	selected := Select(splitList(*run), splitList(*tags))
+	if len(selected) == 0 {
+		fmt.Println("catrunner: no scenario selected")
+		os.Exit(2)
+	}
+	if *list {
+		for _, entry := range selected {
+			line := fmt.Sprintf("%-9s %s  [%s]", entry.Kind, entry.Name, strings.Join(entry.Tags, ","))
+			if entry.Timeout > 0 {
+				line += "  timeout " + entry.Timeout.String()
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	runner := &Runner{Isolated: *isolated, Timeout: *timeout, ChildArgs: places}
+	summary := runner.Run(selected)
+	summary.Write(os.Stdout)
+	if file, err := os.OpenFile(*summaryFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666); err != nil {
+		fmt.Println("catrunner: WARNING: cannot write the summary: " + err.Error())
+	} else {
+		summary.Write(file)
+		file.Close()
+	}
+	writeResults(*junitFile, summary.WriteJUnit)
+	writeResults(*jsonFile, summary.WriteJSON)
+	if !summary.AllPassed() {
+		os.Exit(1)
+	}
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func writeResults(fileName string, write func(io.Writer) error) {
+	if fileName == "" {
+		return
+	}
+	file, err := os.Create(fileName)
+	if err == nil {
+		err = write(file)
+		file.Close()
+	}
+	if err != nil {
+		fmt.Println("catrunner: WARNING: cannot write " + fileName + ": " + err.Error())
+	}
+}
@@ -0,0 +1,203 @@
+package catrunner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"obcsdk/catdsl"
+)
+
+/*
+  Registry of the test scenarios the runner can select and run:
+	- scenarios in the step language of package catdsl, from suite files (*.suite)
+	- Go mains such as CAT/CAT_304_S1S2S3_IQ_R1R2_IQ.go, run with go run
+	- scenarios written in Go, registered by a test program with RegisterFunc
+  Each has a name, tags, and optionally a timeout of its own.
+*/
+
+const (
+	KindScenario = "scenario"	// catdsl step language
+	KindProgram  = "program"	// Go main, run with go run
+	KindFunc     = "func"		// Go function of the test program
+)
+
+type Entry struct {
+	Name     string
+	Kind     string
+	Tags     []string
+	Timeout  time.Duration		// 0: the runner timeout
+	Scenario catdsl.Scenario	// KindScenario
+	Program  string			// KindProgram: path of the Go file
+	Func     func()			// KindFunc: runs the whole test, like a CAT main (Setup ... TimeTrack)
+}
+
+var registry []Entry
+
+// Go mains of the test directories that are tests: CAT_<number>_..., CRT_<number>_...
+var testProgram = regexp.MustCompile(`^(CAT|CRT)_[0-9]+_.*\.go$`)
+
+func Register(entry Entry) error {
+	if entry.Name == "" {
+		return errors.New("cannot register a scenario without a name")
+	}
+	if _, found := Lookup(entry.Name); found {
+		return errors.New("scenario " + entry.Name + " is already registered")
+	}
+	registry = append(registry, entry)
+	return nil
+}
+
+func RegisterScenario(scenario catdsl.Scenario) error {
+	tags := appendTags([]string{KindScenario}, series(scenario.Name))
+	tags = appendTags(tags, scenario.Tags...)
+	return Register(Entry{Name: scenario.Name, Kind: KindScenario, Tags: tags, Timeout: scenario.Timeout, Scenario: scenario})
+}
+
+func RegisterProgram(goFile string) error {
+	name := strings.TrimSuffix(filepath.Base(goFile), ".go")
+	if entry, found := Lookup(name); found && entry.Kind == KindScenario {
+		return errors.New(goFile + ": scenario " + name + " of a suite file has the name of this test; rename the scenario")
+	}
+	return Register(Entry{Name: name, Kind: KindProgram, Tags: appendTags([]string{KindProgram}, series(name)), Program: goFile})
+}
+
+func RegisterFunc(name string, tags []string, run func()) error {
+	return Register(Entry{Name: name, Kind: KindFunc, Tags: appendTags([]string{KindFunc}, tags...), Func: run})
+}
+
+/*
+  registers the scenarios of a suite file, a Go test main, or a directory: all its suite files and
+  CAT/CRT Go mains. A suite scenario with the name of a Go main is an error, rather than running one
+  test in place of the other.
+*/
+func Discover(name string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if strings.HasSuffix(name, ".suite") {
+			return registerSuite(name)
+		}
+		return RegisterProgram(name)
+	}
+	files, err := ioutil.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".suite") {
+			if err = registerSuite(filepath.Join(name, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range files {
+		if testProgram.MatchString(f.Name()) {
+			if err = RegisterProgram(filepath.Join(name, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func registerSuite(path string) error {
+	scenarios, err := catdsl.LoadSuite(path)
+	if err != nil {
+		return err
+	}
+	for _, scenario := range scenarios {
+		if entry, found := Lookup(scenario.Name); found && entry.Kind == KindProgram {
+			return errors.New(path + ": scenario " + scenario.Name + " has the name of the test " + entry.Program + "; rename the scenario")
+		}
+		if err = RegisterScenario(scenario); err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func Lookup(name string) (Entry, bool) {
+	for _, entry := range registry {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+/*
+  returns the registered scenarios, in name order, that match one of the names or globs (all if none
+  is given) and have one of the tags (any if none is given).
+*/
+func Select(patterns []string, tags []string) []Entry {
+	var selected []Entry
+	for _, entry := range registry {
+		if matchesName(entry.Name, patterns) && hasTag(entry, tags) {
+			selected = append(selected, entry)
+		}
+	}
+	sort.Sort(byName(selected))
+	return selected
+}
+
+func matchesName(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(entry Entry, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, t := range entry.Tags {
+			if strings.EqualFold(tag, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+  the series of a test, as a tag: CAT_304_... (and DSL_CAT_304_...) is in series CAT3xx.
+*/
+func series(name string) string {
+	if m := testSeries.FindStringSubmatch(name); m != nil {
+		return m[1] + m[2] + "xx"
+	}
+	return ""
+}
+
+var testSeries = regexp.MustCompile(`^(?:DSL_)?(CAT|CRT)_([0-9])`)
+
+func appendTags(tags []string, more ...string) []string {
+	for _, tag := range more {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+type byName []Entry
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,112 @@
+package catrunner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"obcsdk/chco2"
+)
+
+/*
+  The summary of a run as JUnit XML and as JSON, for dashboards and CI viewers. A scenario that wrote
+  its chco2 results (see chco2/results.go) is a JUnit testsuite of its query steps, and its JSON result
+  includes the chco2 report with every step; any other is a testsuite with just its result.
+*/
+
+type resultJSON struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind"`
+	Status  string          `json:"status"`
+	Seconds float64         `json:"seconds"`
+	Detail  string          `json:"detail,omitempty"`
+	Report  json.RawMessage `json:"report,omitempty"`	// the chco2.TestReport
+}
+
+type summaryJSON struct {
+	Mode    string       `json:"mode"`
+	Started time.Time    `json:"started"`
+	Seconds float64      `json:"seconds"`
+	Passed  int          `json:"passed"`
+	Failed  int          `json:"failed"`
+	Aborted int          `json:"aborted"`
+	NotRun  int          `json:"notRun"`
+	Results []resultJSON `json:"results"`
+}
+
+func (summary Summary) WriteJSON(w io.Writer) error {
+	doc := summaryJSON{Mode: summary.Mode, Started: summary.Started, Seconds: summary.Elapsed.Seconds(),
+		Passed: summary.Count(StatusPassed), Failed: summary.Count(StatusFailed),
+		Aborted: summary.Count(StatusAborted), NotRun: summary.Count(StatusNotRun)}
+	for _, result := range summary.Results {
+		r := resultJSON{Name: result.Name, Kind: result.Kind, Status: result.Status, Seconds: result.Duration.Seconds(), Detail: result.Detail}
+		if report, err := loadReport(result.Report); err == nil {
+			report.Result = result.Status
+			r.Report, _ = json.Marshal(report)
+		}
+		doc.Results = append(doc.Results, r)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (summary Summary) WriteJUnit(w io.Writer) error {
+	doc := chco2.JUnitTestSuites{Name: "catrun"}
+	for _, result := range summary.Results {
+		var suite chco2.JUnitTestSuite
+		if report, err := loadReport(result.Report); err == nil {
+			report.Result = result.Status	// e.g. ABORTED by a timeout after the report was written
+			report.Seconds = result.Duration.Seconds()
+			if result.Detail != "" {
+				report.Failures = append(report.Failures, result.Detail)
+			}
+			suite = report.JUnit()
+		} else {
+			suite = resultSuite(result)
+		}
+		doc.Suites = append(doc.Suites, suite)
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append([]byte(xml.Header), append(data, '\n')...))
+	return err
+}
+
+/*
+  a testsuite of one testcase, the result of a scenario that wrote no chco2 results.
+*/
+func resultSuite(result Result) chco2.JUnitTestSuite {
+	seconds := chco2.JUnitSeconds(result.Duration)
+	tc := chco2.JUnitTestCase{Name: "result", ClassName: result.Name, Time: seconds}
+	suite := chco2.JUnitTestSuite{Name: result.Name, Tests: 1, Time: seconds}
+	switch result.Status {
+	case StatusFailed:
+		tc.Failure = &chco2.JUnitFailure{Message: StatusFailed, Text: result.Detail}
+		suite.Failures = 1
+	case StatusAborted:
+		tc.Error = &chco2.JUnitFailure{Message: StatusAborted, Text: result.Detail}
+		suite.Errors = 1
+	case StatusNotRun:
+		tc.Skipped = &chco2.JUnitFailure{Message: StatusNotRun, Text: result.Detail}
+		suite.Skipped = 1
+	}
+	suite.TestCases = []chco2.JUnitTestCase{tc}
+	return suite
+}
+
+func loadReport(path string) (chco2.TestReport, error) {
+	var report chco2.TestReport
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &report)
+	}
+	return report, err
+}
@@ -0,0 +1,341 @@
+package catrunner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"obcsdk/catdsl"
+	"obcsdk/chco2"
+)
+
+/*
+  Runs the selected scenarios one after the other, and collects their results in one summary.
+
+  Sequential mode runs the catdsl and Go function scenarios in this process, each setting up its own
+  network with chco2.Setup. Isolated mode runs each one in a child process of its own: a scenario that
+  exits with log.Fatal, panics, or times out cannot take the others down with it. Go mains (CAT_*.go)
+  always run in a child process, with go run.
+
+  A scenario that runs past its timeout is ABORTED. A child process is sent SIGTERM, then SIGKILL after
+  KillGrace; in sequential mode, the scenario cannot be stopped, so the run ends there and the
+  scenarios left are reported NOT RUN.
+
+  On SIGINT or SIGTERM, the scenario running is ABORTED and the run ends, the scenarios left NOT RUN:
+  chco2 restores the network of a scenario in this process; a child process gets the signal and does
+  the same. A second signal exits at once.
+*/
+
+const (
+	StatusPassed  = "PASSED"
+	StatusFailed  = "FAILED"
+	StatusAborted = "ABORTED"
+	StatusNotRun  = "NOT RUN"
+)
+
+// How long a child process that timed out has to end after SIGTERM, before SIGKILL
+var KillGrace = 60 * time.Second
+
+// How long an interrupted child process has to restore the network and end, before SIGKILL
+var InterruptGrace = 5 * time.Minute
+
+type Runner struct {
+	Isolated  bool
+	Timeout   time.Duration	// per scenario, unless it has its own; 0 for none
+	ChildArgs []string	// arguments of the child processes after -child <name>, so they register the same scenarios
+
+	signals     chan os.Signal
+	interrupted chan bool		// chco2 has reported and restored an interrupted scenario
+}
+
+const interruptedDetail = "interrupted"
+
+type Result struct {
+	Name     string
+	Kind     string
+	Status   string
+	Duration time.Duration
+	Detail   string		// e.g. why it was aborted
+	Report   string		// JSON results file that chco2 wrote for the scenario (chco2.ResultsPath), if any
+}
+
+type Summary struct {
+	Mode    string
+	Started time.Time
+	Elapsed time.Duration
+	Results []Result
+}
+
+// the PASSED/FAILED/ABORTED line of chco2.TimeTrack
+var resultLine = regexp.MustCompile(`^(PASSED|FAILED|ABORTED) +(\S+) \(Q_Pass=`)
+
+// the line of chco2.WriteResults with the path of the JSON results
+var resultsLine = regexp.MustCompile(`^chco2 results: (.+)$`)
+
+func (r *Runner) Run(entries []Entry) Summary {
+	summary := Summary{Mode: "sequential", Started: time.Now()}
+	if r.Isolated {
+		summary.Mode = "isolated"
+	}
+	r.signals = make(chan os.Signal, 1)
+	signal.Notify(r.signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(r.signals)
+	r.interrupted = make(chan bool, 1)
+	chco2.OnInterrupt = func() { r.interrupted <- true }
+	defer func() { chco2.OnInterrupt = nil }()
+
+	for i, entry := range entries {
+		timeout := r.Timeout
+		if entry.Timeout > 0 {
+			timeout = entry.Timeout
+		}
+		fmt.Printf("\ncatrunner: %d/%d %s (%s)\n", i+1, len(entries), entry.Name, entry.Kind)
+		var result Result
+		inProcess := !r.Isolated && entry.Kind != KindProgram
+		if inProcess {
+			result = r.runInProcess(entry, timeout)
+		} else {
+			result = r.runChild(entry, timeout)
+		}
+		fmt.Printf("catrunner: %s %s [%s] %s\n", result.Status, result.Name, result.Duration, result.Detail)
+		summary.Results = append(summary.Results, result)
+		stop := ""
+		switch {
+		case strings.HasPrefix(result.Detail, interruptedDetail):
+			stop = "after an interrupt"
+		case inProcess && result.Status == StatusAborted && strings.HasPrefix(result.Detail, "timeout"):
+			stop = "after a timeout in sequential mode"
+		case len(r.signals) > 0:
+			stop = "after an interrupt"		// between two scenarios
+		}
+		if stop != "" {
+			for _, left := range entries[i+1:] {
+				summary.Results = append(summary.Results, Result{Name: left.Name, Kind: left.Kind, Status: StatusNotRun, Detail: stop})
+			}
+			break
+		}
+	}
+	summary.Elapsed = time.Since(summary.Started)
+	return summary
+}
+
+/*
+  runs a catdsl or Go function scenario in this process, and returns its chco2.TestResult.
+*/
+func (r *Runner) runInProcess(entry Entry, timeout time.Duration) Result {
+	result := Result{Name: entry.Name, Kind: entry.Kind}
+	started := time.Now()
+	chco2.ResultsPath = ""
+	done := make(chan string, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Sprint("panic: ", p)
+			}
+		}()
+		runEntry(entry)
+		done <- ""
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	signals := r.signals
+	for result.Status == "" {
+		select {
+		case result.Detail = <-done:
+			result.Status = chco2.TestResult
+			result.Report = chco2.ResultsPath
+			if chco2.Interrupted() {
+				result.Detail = interruptedDetail	// the test ended as chco2 reported it
+			}
+			if result.Status == "" {
+				result.Status = StatusAborted
+				if result.Detail == "" {
+					result.Detail = "no result reported"
+				}
+			}
+		case <-r.interrupted:
+			result.Status = StatusAborted	// chco2 reported it ABORTED, and restored the network
+			result.Detail = interruptedDetail
+			result.Report = chco2.ResultsPath
+		case sig := <-signals:
+			if chco2.TestInProgress() {
+				signals = nil		// chco2 handles it: wait until it has restored the network
+				go exitOnSecondSignal(r.signals)
+				continue
+			}
+			result.Status = StatusAborted
+			result.Detail = interruptedDetail + " (" + sig.String() + ") before the test began"
+		case <-expired:
+			result.Status = StatusAborted
+			result.Detail = "timeout after " + timeout.String()
+		}
+	}
+	result.Duration = time.Since(started)
+	return result
+}
+
+func exitOnSecondSignal(signals chan os.Signal) {
+	<-signals
+	fmt.Println("catrunner: interrupted again: exit")
+	os.Exit(2)
+}
+
+func runEntry(entry Entry) {
+	switch entry.Kind {
+	case KindScenario:
+		catdsl.Run(entry.Scenario)
+	case KindFunc:
+		entry.Func()
+	}
+}
+
+/*
+  runs a scenario in a child process, showing its output, and finds its result in the output.
+*/
+func (r *Runner) runChild(entry Entry, timeout time.Duration) Result {
+	result := Result{Name: entry.Name, Kind: entry.Kind}
+	var cmd *exec.Cmd
+	if entry.Kind == KindProgram {
+		cmd = exec.Command("go", "run", filepath.Base(entry.Program))
+		cmd.Dir = filepath.Dir(entry.Program)	// like go_record.sh, run the test from its own directory
+	} else {
+		cmd = exec.Command(os.Args[0], append([]string{"-child", entry.Name}, r.ChildArgs...)...)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}	// to signal go run and the test it runs
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		result.Status = StatusAborted
+		result.Detail = "cannot start: " + err.Error()
+		return result
+	}
+	scanned := make(chan bool)
+	var reported, failure, report string
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			fmt.Println(line)
+			if m := resultLine.FindStringSubmatch(line); m != nil {
+				reported = m[1]
+			}
+			if m := resultsLine.FindStringSubmatch(line); m != nil {
+				report = m[1]
+			}
+			if strings.Contains(line, " FAILURE during QUERY") || strings.Contains(line, " FAILURE with CHAINHEIGHT") {
+				failure = strings.TrimSpace(line)
+			}
+		}
+		io.Copy(ioutil.Discard, reader)	// do not block the test on a line too long to scan
+		close(scanned)
+	}()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	var err error
+	timedOut := false
+	var interrupted os.Signal
+	select {
+	case err = <-exited:
+	case <-expired:
+		timedOut = true
+		err = stopChild(cmd, syscall.SIGTERM, KillGrace, exited)
+	case interrupted = <-r.signals:
+		// the child is in a process group of its own: pass the signal on, so chco2 restores the network
+		go exitOnSecondSignal(r.signals)
+		err = stopChild(cmd, interrupted, InterruptGrace, exited)
+	}
+	writer.Close()
+	<-scanned
+	result.Duration = time.Since(started)
+	if report != "" && !filepath.IsAbs(report) {
+		report = filepath.Join(cmd.Dir, report)		// relative to the directory of the child process
+	}
+	result.Report = report
+
+	switch {
+	case timedOut:
+		result.Status = StatusAborted
+		result.Detail = "timeout after " + timeout.String()
+	case interrupted != nil:
+		result.Status = StatusAborted
+		result.Detail = interruptedDetail + " (" + interrupted.String() + ")"
+	case reported != "":
+		result.Status = reported
+	case failure != "":
+		result.Status = StatusFailed	// chco2 stopped the test at the first failure (Stop_on_error)
+		result.Detail = failure
+	case err != nil:
+		result.Status = StatusAborted
+		result.Detail = err.Error()
+	default:
+		result.Status = StatusAborted
+		result.Detail = "no result reported"
+	}
+	return result
+}
+
+/*
+  signals the process group of a child process, and waits until it exits; SIGKILL after the grace time.
+*/
+func stopChild(cmd *exec.Cmd, sig os.Signal, grace time.Duration, exited chan error) error {
+	syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(grace):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return <-exited
+	}
+}
+
+func (summary Summary) Count(status string) int {
+	n := 0
+	for _, result := range summary.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (summary Summary) AllPassed() bool {
+	return summary.Count(StatusPassed) == len(summary.Results)
+}
+
+func (summary Summary) Write(w io.Writer) {
+	fmt.Fprintf(w, "\nCAT RUN SUMMARY (%s) [STARTED: %s]\n", summary.Mode, summary.Started.Format("2006-01-02 15:04:05"))
+	for _, result := range summary.Results {
+		line := fmt.Sprintf("%-8s %-12s %s", result.Status, seconds(result.Duration), result.Name)
+		if result.Detail != "" {
+			line += "  (" + result.Detail + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "TOTAL %d: %d passed, %d failed, %d aborted, %d not run [%s]\n", len(summary.Results),
+		summary.Count(StatusPassed), summary.Count(StatusFailed), summary.Count(StatusAborted), summary.Count(StatusNotRun),
+		seconds(summary.Elapsed))
+}
+
+func seconds(d time.Duration) time.Duration {
+	return d / time.Second * time.Second
+}
@@ -1,12 +1,14 @@
 package chaincode
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"obcsdk/peernetwork"
+	"obcsdk/peerrest"
 	"os"
 	//"obcsdk/util"
 	"obcsdk/threadutil"
@@ -16,6 +18,7 @@ var ThisNetwork peernetwork.PeerNetwork
 var Peers = ThisNetwork.Peers
 var ChainCodeDetails, Versions map[string]string
 var LibCC peernetwork.LibChainCodes
+var libCCOfNetwork = make(map[string]peernetwork.LibChainCodes)
 
 const invokeOnPeerUsage = ("iAPIArgs0 := []string{\"example02\", \"invoke\", \"<PEER_IP_ADDRESS>\" + \"(optional)<tagName>\"}" +
 	"invArgs0 := []string{\"a\", \"b\", \"500\"} " +
@@ -50,9 +53,32 @@ func Init() {
 	InitChainCodes()
 }
 
+/*
+  makes the package functions work on another network registered in peernetwork (see peernetwork.AddPeerNetworkFromProfile).
+  They work on one network at a time; the chaincodes deployed on each network are remembered, so switching back and forth
+  does not require deploying them again. To drive several networks at once, use a Client on each.
+*/
+func SwitchNetwork(name string) error {
+	newNetwork, err := peernetwork.LoadNetworkByName(name)
+	if err != nil {
+		return err
+	}
+	if ThisNetwork.Name != "" {
+		libCCOfNetwork[ThisNetwork.Name] = LibCC
+	}
+	lib, ok := libCCOfNetwork[name]
+	if !ok {
+		lib = peernetwork.InitializeChainCodes()
+	}
+	ThisNetwork, LibCC = newNetwork, lib
+	Peers = ThisNetwork.Peers
+	fmt.Println("chaincode API now works on network " + name)
+	return nil
+}
+
 func GetURL(ip, port string) string {
 	var url string
-	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" {
+	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" || peerrest.HasTLSConfig(ip + ":" + port) {
 		url = "https://" + ip + ":" + port
 	} else {
 		url = "http://" + ip + ":" + port
@@ -61,34 +87,39 @@ func GetURL(ip, port string) string {
 }
 
 /*
-   Registers each user on the network based on the content of ThisNetwork.Peers.
+   Registers each user on the network of the Client, based on the content of its Peers.
 */
-func RegisterUsers() bool {
+func (c *Client) RegisterUsers() bool {
 	if verbose { fmt.Println("\nRegisterUsers: register list of all users in all peers in network") }
 
 	//testuser := peernetwork.AUser(ThisNetwork)
-	Peers = ThisNetwork.Peers
-	i := 0
 	passResult := true
-	for i < len(Peers) {
-		successfuls := 0
-		userList := Peers[i].UserData // this contains the users in the database, not necessarily registered
-		for user, secret := range userList {
-			url := GetURL(Peers[i].PeerDetails["ip"], Peers[i].PeerDetails["port"])
-			if verbose {
-				msgStr := fmt.Sprintf("\nRegistering %s with password %s on %s using %s", user, secret, Peers[i].PeerDetails["name"], url)
-				fmt.Println(msgStr)
-			}
-			errStatusStr := register(url, user, secret)
-			if errStatusStr == "" { successfuls++ } else { fmt.Println("ERROR registering user:", user, " err:", errStatusStr) }
-		}
-		if successfuls != len(userList) { passResult = false }
-		fmt.Println("RegisterUsers(): Done Registering ", successfuls, "/", len(userList), " users on ", Peers[i].PeerDetails["name"], "\n")
-		i++
+	for i := 0; i < len(c.Network.Peers); i++ {
+		if peernetwork.PeerStateOf(c.Network, i) == peernetwork.DECOMMISSIONED { continue }
+		if !RegisterUsersOnPeer(peernetwork.PeerCopy(&c.Network.Peers[i])) { passResult = false }
 	}
 	return passResult
 }
 
+/**
+  registers the users of one peer on it, e.g. of a peer just added to the network
+*/
+func RegisterUsersOnPeer(aPeer peernetwork.Peer) bool {
+	successfuls := 0
+	userList := aPeer.UserData // this contains the users in the database, not necessarily registered
+	for user, secret := range userList {
+		url := GetURL(aPeer.PeerDetails["ip"], aPeer.PeerDetails["port"])
+		if verbose {
+			msgStr := fmt.Sprintf("\nRegistering %s with password %s on %s using %s", user, secret, aPeer.PeerDetails["name"], url)
+			fmt.Println(msgStr)
+		}
+		errStatusStr := register(url, user, secret)
+		if errStatusStr == "" { successfuls++ } else { fmt.Println("ERROR registering user:", user, " err:", errStatusStr) }
+	}
+	fmt.Println("RegisterUsers(): Done Registering ", successfuls, "/", len(userList), " users on ", aPeer.PeerDetails["name"], "\n")
+	return successfuls == len(userList)
+}
+
 
 func RegisterCustomUsers() bool {
 
@@ -189,7 +220,7 @@ func RegisterUsers2() {
 		var err error
 		depRes, err := chaincode.Deploy(dAPIArgs0, depArgs0)
 */
-func Deploy(args []string, depargs []string) (id string, err error)  {
+func (c *Client) Deploy(args []string, depargs []string) (id string, err error)  {
 
 	if (len(args) < 2) || (len(args) > 3) {
 		return " ", errors.New("FAILURE TO DEPLOY: Incorrect number of arguments. Expecting 2 or 3")
@@ -205,34 +236,34 @@ func Deploy(args []string, depargs []string) (id string, err error)  {
 	}
 	dargs := depargs
 	var err1 error
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("chcoAPI.Deploy() FAILURE TO DEPLOY: Inside chcoAPI.Deploy(), found error: ", err1)
 		//log.Fatal("No Chain Code Details, we cannot proceed")
 		return " ", errors.New("FAILURE TO DEPLOY: No Chain Code Details; we cannot proceed")
 	}
-	if strings.Contains(ChainCodeDetails["deployed"], "true") {
+	if strings.Contains(details["deployed"], "true") {
 		fmt.Println("\nchcoAPI.Deploy()  ** Already deployed ... skipping deploy...")
 	} else {
-		//msgStr := fmt.Sprintf("** Initializing and deploying chaincode %s on network with args %s", ChainCodeDetails["path"], dargs)
+		//msgStr := fmt.Sprintf("** Initializing and deploying chaincode %s on network with args %s", details["path"], dargs)
 		//fmt.Println(msgStr)
 		restCallName := "deploy"
-		peer, auser := peernetwork.AUserFromNetwork(ThisNetwork)
-		if verbose { fmt.Println( fmt.Sprintf("Deploying peer %s, peer.State (0=RUNNING): %d", peer.PeerDetails["name"], peer.State)) }
+		peer, auser := peernetwork.AUserFromNetwork(c.Network)
+		if verbose { fmt.Println( fmt.Sprintf("Deploying peer %s, peer.State (0=RUNNING): %d", peer.PeerDetails["name"], peernetwork.PeerCopy(peer).State)) }
 		url := GetURL(peer.PeerDetails["ip"], peer.PeerDetails["port"])
 		if verbose {
-			msgStr := fmt.Sprintf("chcoAPI.Deploy() ** Initializing and deploying chaincode %s on network with args %s", ChainCodeDetails["path"], dargs)
+			msgStr := fmt.Sprintf("chcoAPI.Deploy() ** Initializing and deploying chaincode %s on network with args %s", details["path"], dargs)
 			fmt.Println(msgStr)
-			fmt.Println("chcoAPI.Deploy() Value in the deploying peer.State (0=RUNNING): ", peer.State, " user=", auser)
+			fmt.Println("chcoAPI.Deploy() Value in the deploying peer.State (0=RUNNING): ", peernetwork.PeerCopy(peer).State, " user=", auser)
 			fmt.Println("chcoAPI.Deploy() url=", url)
 			fmt.Println("chcoAPI.Deploy() restCallname=", url, " funcName=", funcName)
 		}
-		txId = changeState(url, ChainCodeDetails["path"], restCallName, dargs, auser, funcName)
+		txId = changeState(url, details["path"], restCallName, dargs, auser, funcName)
 		//if verbose { fmt.Println("chcoAPI.Deploy() txID", txId) }
 		//storing the value of most recently deployed chaincode inside chaincode details if no tagname or versioning
-		ChainCodeDetails["dep_txid"] = txId
+		recordDeployment(details, "dep_txid", txId)
 		if len(tagName) != 0 {
-		  Versions[tagName] = txId
+		  recordDeployment(versions, tagName, txId)
 		}
 		//fmt.Println("ChainCodeDetails dep_txid = " + ChainCodeDetails["dep_txid"])
 	}
@@ -262,7 +293,7 @@ func Deploy(args []string, depargs []string) (id string, err error)  {
 		var err error
 		depRes, err := chaincode.Deploy(dAPIArgs0, depArgs0)
 */
-func DeployOnPeer(args []string, depargs []string) (id string, err error)  {
+func (c *Client) DeployOnPeer(args []string, depargs []string) (id string, err error)  {
 
 	if (len(args) < 3) || (len(args) > 4) {
 		fmt.Println("DeployOnPeer : Incorrect number of arguments. Expecting 3 or 4 in invokeAPI arguments")
@@ -281,20 +312,20 @@ func DeployOnPeer(args []string, depargs []string) (id string, err error)  {
 	}
 	dargs := depargs
 	var err1 error
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside deploy: ", err1)
 		//log.Fatal("No Chain Code Details, we cannot proceed")
 		return " ", errors.New("No Chain Code Details we cannot proceed")
 	}
-	if strings.Contains(ChainCodeDetails["deployed"], "true") {
+	if strings.Contains(details["deployed"], "true") {
 		fmt.Println("\n\n ** Already deployed ..")
 		fmt.Println(" skipping deploy...")
 	} else {
-		//msgStr := fmt.Sprintf("\n** Initializing and deploying chaincode %s on network with args %s\n", ChainCodeDetails["path"], dargs)
+		//msgStr := fmt.Sprintf("\n** Initializing and deploying chaincode %s on network with args %s\n", details["path"], dargs)
 		//fmt.Println(msgStr)
 		restCallName := "deploy"
-		ip, port, auser, err2 := peernetwork.AUserFromThisPeer(ThisNetwork, host)
+		ip, port, auser, err2 := peernetwork.AUserFromThisPeer(c.Network, host)
 		if err2 != nil {
 			fmt.Println("Inside invoke3: ", err2)
 			return "", err2
@@ -303,11 +334,11 @@ func DeployOnPeer(args []string, depargs []string) (id string, err error)  {
                       //fmt.Println("Value in State : ", peer.State)
                       //fmt.Println("Value in State : ", peer.PeerDetails["state"])
                       url := GetURL(ip, port)
-                      txId = changeState(url, ChainCodeDetails["path"], restCallName, dargs, auser, funcName)
+                      txId = changeState(url, details["path"], restCallName, dargs, auser, funcName)
                       //storing the value of most recently deployed chaincode inside chaincode details if no tagname or versioning
-                      ChainCodeDetails["dep_txid"] = txId
+                      recordDeployment(details, "dep_txid", txId)
                       if len(tagName) != 0 {
-                        Versions[tagName] = txId
+                        recordDeployment(versions, tagName, txId)
                      }
 		}
      }
@@ -333,7 +364,7 @@ func DeployOnPeer(args []string, depargs []string) (id string, err error)  {
 		var err error
 		invRes,err := chaincode.Invoke(iAPIArgs0, invArgs0)}
 */
-func Invoke(args []string, invokeargs []string) (id string, err error) {
+func (c *Client) Invoke(args []string, invokeargs []string) (id string, err error) {
 
 	if (len(args) < 2) || (len(args) > 3) {
 		fmt.Println("Invoke : Incorrect number of arguments. Expecting 2")
@@ -350,18 +381,18 @@ func Invoke(args []string, invokeargs []string) (id string, err error) {
 	invargs := invokeargs
 	//fmt.Println("Inside invoke .....")
 	var err1 error
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside invoke: ", err1)
 		log.Fatal("No Chain Code Details we cannot proceed")
 		return "", errors.New("No Chain Code Details we cannot proceed")
 	}
 	restCallName := "invoke"
-	aPeer, _ := peernetwork.APeer(ThisNetwork)
+	aPeer, _ := peernetwork.APeer(c.Network)
 	if verbose {
 		fmt.Println("Getting AUserFromAPeer at ip,port:", aPeer.PeerDetails["ip"], aPeer.PeerDetails["port"])
 	}
-	ip, port, auser, _ := peernetwork.AUserFromAPeer(*aPeer)
+	ip, port, auser, _ := peernetwork.AUserFromAPeer(peernetwork.PeerCopy(aPeer))
 	url := GetURL(ip, port)
 	if verbose {
 		msgStr0 := fmt.Sprintf("** Calling %s on chaincode %s with args %s on  %s as %s", funcName, ccName, invargs, url, auser)
@@ -369,9 +400,9 @@ func Invoke(args []string, invokeargs []string) (id string, err error) {
 	}
 	var txId string
 	if len(tagName) != 0 {
-		txId = changeState(url, Versions[tagName], restCallName, invargs, auser, funcName)
+		txId = changeState(url, deployment(versions, tagName), restCallName, invargs, auser, funcName)
 	} else {
-		txId = changeState(url, (ChainCodeDetails["dep_txid"]), restCallName, invargs, auser, funcName)
+		txId = changeState(url, deployment(details, "dep_txid"), restCallName, invargs, auser, funcName)
 	}
 	//fmt.Println("*** END Invoking as  ***", auser, " on a single peer")
 	return txId, nil
@@ -397,7 +428,7 @@ func Invoke(args []string, invokeargs []string) (id string, err error) {
 		var err error
 		invRes,err := chaincode.Invoke(iAPIArgs0, invArgs0)}
 */
-func InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
+func (c *Client) InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
 
 	//fmt.Println("Inside InvokeOnPeer .....")
 	if (len(args) < 3) || (len(args) > 4) {
@@ -418,14 +449,14 @@ func InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
 	restCallName := "invoke"
 	var err1 error
 	var txId string
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside InvokeOnPeer: ", err1)
 		log.Fatal("No Chain Code Details we cannot proceed")
 		return "", errors.New("No Chain Code Details we cannot proceed")
 	}
 
-	ip, port, auser, err2 := peernetwork.AUserFromThisPeer(ThisNetwork, host)
+	ip, port, auser, err2 := peernetwork.AUserFromThisPeer(c.Network, host)
 	if err2 != nil {
 		fmt.Println("Inside invoke3: ", err2)
 		return "", err2
@@ -436,9 +467,9 @@ func InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
 			fmt.Println(msgStr0)
 		}
 		if (len(tagName) > 0) {
-			txId = changeState(url, Versions[tagName], restCallName, invargs, auser, funcName)
+			txId = changeState(url, deployment(versions, tagName), restCallName, invargs, auser, funcName)
 		}else {
-		        txId = changeState(url, (ChainCodeDetails["dep_txid"]), restCallName, invargs, auser, funcName)
+		        txId = changeState(url, deployment(details, "dep_txid"), restCallName, invargs, auser, funcName)
 		}
 		return txId, nil
 	}
@@ -464,7 +495,7 @@ func InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
 		var err error
 		invRes,err := chaincode.Invoke(iAPIArgs0, invArgs0)}
 */
-func InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
+func (c *Client) InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
 	if (len(args) < 3) || (len(args) > 4) {
 		fmt.Println("InvokeAsUser : Incorrect number of arguments. Expecting 3 or 4 in invokeAPI arguments")
 		fmt.Println(invokeAsUserUsage)
@@ -482,14 +513,14 @@ func InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
 	invargs := invokeargs
 	var err1 error
 	var txId string
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside InvokeAsUser err1: ", err1)
 		log.Fatal("No Chain Code Details we cannot proceed")
 		return "", errors.New("No Chain Code Details we cannot proceed")
 	}
 	restCallName := "invoke"
-	ip, port, auser, err2 := peernetwork.PeerOfThisUser(ThisNetwork, userName)
+	ip, port, auser, err2 := peernetwork.PeerOfThisUser(c.Network, userName)
 	if err2 != nil {
 		fmt.Println("inside InvokeAsUser err2: ", err2)
 		//return "", err2
@@ -501,11 +532,11 @@ func InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
 			msgStr0 := fmt.Sprintf("InvokeAsUser: ** Calling function:%s on chaincode name:%s with args:%s on url:%s as user:%s using tagName:%s", funcName, ccName, invargs, url, auser, tagName)
 			fmt.Println(msgStr0)
 		}
-		//txId := changeState(url, Versions[tagName], restCallName, invargs, auser, funcName)
+		//txId := changeState(url, deployment(versions, tagName), restCallName, invargs, auser, funcName)
 		if (len(tagName) > 0) {
-			txId = changeState(url, Versions[tagName], restCallName, invargs, auser, funcName)
+			txId = changeState(url, deployment(versions, tagName), restCallName, invargs, auser, funcName)
 		}else {
-		        txId = changeState(url, (ChainCodeDetails["dep_txid"]), restCallName, invargs, auser, funcName)
+		        txId = changeState(url, deployment(details, "dep_txid"), restCallName, invargs, auser, funcName)
 		}
 		return txId, nil
 	}
@@ -530,7 +561,7 @@ func InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
 		var err error
 		queryRes,err := chaincode.Query(qAPIArgs0, qArgsa)
 */
-func Query(args []string, queryArgs []string) (id string, err error) {
+func (c *Client) Query(args []string, queryArgs []string) (id string, err error) {
 
 	if (len(args) < 2) || (len(args) > 3) {
 		return "", errors.New("Incorrect number of arguments. Expecting 2")
@@ -546,14 +577,14 @@ func Query(args []string, queryArgs []string) (id string, err error) {
 	qargs := queryArgs
 	var err1 error
 
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside Query: ", err1)
 		fmt.Println("No Chain Code Details we cannot proceed")
 		return "", errors.New("No Chain Code Details we cannot proceed")
 	}
 	restCallName := "query"
-	peer, auser := peernetwork.AUserFromNetwork(ThisNetwork)
+	peer, auser := peernetwork.AUserFromNetwork(c.Network)
 	url := GetURL(peer.PeerDetails["ip"], peer.PeerDetails["port"])
 
 	var txId string
@@ -563,9 +594,9 @@ func Query(args []string, queryArgs []string) (id string, err error) {
 	}
 
 	if len(tagName) != 0 {
-		txId = readState(url, Versions[tagName], restCallName, qargs, auser, funcName)
+		txId = readState(url, deployment(versions, tagName), restCallName, qargs, auser, funcName)
 	} else {
-		txId = readState(url, (ChainCodeDetails["dep_txid"]), restCallName, qargs, auser, funcName)
+		txId = readState(url, deployment(details, "dep_txid"), restCallName, qargs, auser, funcName)
 	}
 
 	return txId, nil
@@ -595,7 +626,7 @@ func Query(args []string, queryArgs []string) (id string, err error) {
 
 
 
-func QueryOnHost(args []string, queryargs []string) (id string, err error) {
+func (c *Client) QueryOnHost(args []string, queryargs []string) (id string, err error) {
 	if (len(args) < 3) || (len(args) > 4) {
 		fmt.Println("QueryOnHost : Incorrect number of arguments. Expecting 3 or 4 in invokeAPI arguments")
 		fmt.Println(invokeOnPeerUsage)
@@ -616,14 +647,14 @@ func QueryOnHost(args []string, queryargs []string) (id string, err error) {
 	qryargs := queryargs
 	var err1 error
 	var txId string
-	ChainCodeDetails, Versions, err1 = peernetwork.GetCCDetailByName(ccName, LibCC)
+	details, versions, err1 := peernetwork.GetCCDetailByName(ccName, c.LibCC)
 	if err1 != nil {
 		fmt.Println("Inside QueryOnHost: peernetwork.GetCCDetailByName returned error:", err1)
 		log.Fatal("No Chain Code Details we cannot proceed")
 		return "", errors.New("No Chain Code Details we cannot proceed")
 	}
 	restCallName := "query"
-	ip, port, auser, err2 := peernetwork.AUserFromThisPeer(ThisNetwork, host)
+	ip, port, auser, err2 := peernetwork.AUserFromThisPeer(c.Network, host)
 	if err2 != nil {
 		fmt.Println("Inside QueryOnHost: peernetwork.AUserFromThisPeer (host=" + host + ") returned error:", err2)
 		return "", err2
@@ -635,19 +666,19 @@ func QueryOnHost(args []string, queryargs []string) (id string, err error) {
 		}
 		if (len(tagName) > 0) {
 // why are we not using readState here???
-			txId = changeState(url, Versions[tagName], restCallName, qryargs, auser, funcName)
+			txId = changeState(url, deployment(versions, tagName), restCallName, qryargs, auser, funcName)
 		}else {
-			txId = changeState(url, (ChainCodeDetails["dep_txid"]), restCallName, qryargs, auser, funcName)
+			txId = changeState(url, deployment(details, "dep_txid"), restCallName, qryargs, auser, funcName)
 		}
 		return txId, nil
 	}
 
 }
 
-func GetChainHeight(host string) (ht int, err error) {
+func (c *Client) GetChainHeight(host string) (ht int, err error) {
 
 			//fmt.Println("Inside GetChainHeight chcoAPI.....")
-			ip, port, _, err2 := peernetwork.AUserFromThisPeer(ThisNetwork, host)
+			ip, port, _, err2 := peernetwork.AUserFromThisPeer(c.Network, host)
 			if err2 != nil {
 				fmt.Println("Inside GetChainHeight: ", err2)
 				return -1, err2
@@ -659,9 +690,9 @@ func GetChainHeight(host string) (ht int, err error) {
 
 }
 
-func GetBlockTrxInfoByHost(host string, block int) (bsNonHash NonHashData, err error) {
+func (c *Client) GetBlockTrxInfoByHost(host string, block int) (bsNonHash NonHashData, err error) {
 	//respBody, status := peerrest.GetChainInfo(url + "/chain/blocks/" + strconv.Itoa(block))
-	ip, port, _, err2 := peernetwork.AUserFromThisPeer(ThisNetwork, host)
+	ip, port, _, err2 := peernetwork.AUserFromThisPeer(c.Network, host)
 	if err2 != nil {
 		fmt.Println("Inside GetBlockTrxInfoByHost(), AUserFromThisPeer <" +host+ "> returned err:", err2)
 		var emptyNonHashData NonHashData
@@ -674,6 +705,26 @@ func GetBlockTrxInfoByHost(host string, block int) (bsNonHash NonHashData, err e
 	}
 }
 
+/*
+  returns a block of the chain of a peer, with the UUIDs of its transactions; quietly, so a test can read
+  whole chains. Returns an error if the peer does not have the block.
+*/
+func (c *Client) GetBlockByHost(host string, block int) (Block, error) {
+	var blockStruct Block
+	ip, port, _, err := peernetwork.AUserFromThisPeer(c.Network, host)
+	if err != nil {
+		return blockStruct, err
+	}
+	respBody, status := peerrest.GetChainInfo(GetURL(ip, port) + "/chain/blocks/" + strconv.Itoa(block))
+	if !strings.HasPrefix(status, "200") {
+		return blockStruct, errors.New("GET /chain/blocks/" + strconv.Itoa(block) + " on " + host + ": " + status + " " + respBody)
+	}
+	if err = json.Unmarshal([]byte(respBody), &blockStruct); err != nil {
+		return blockStruct, errors.New("GET /chain/blocks/" + strconv.Itoa(block) + " on " + host + ": " + err.Error())
+	}
+	return blockStruct, nil
+}
+
 /* *** WORKING UNDER CONSTRUCTIOn ***
    deploys a chaincode in the fabric to later execute functions on this deployed chaincode
    Takes two arguments
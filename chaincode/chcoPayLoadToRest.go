@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"obcsdk/peernetwork"
 	"obcsdk/peerrest"
 )
 
@@ -183,7 +184,9 @@ func BlockStats(url string, block int) string {
 }
 
 /*
-  
+  returns the /network/peers response of a peer, indented, and the response status.
+	url  (http://IP:PORT) is the address of network peer
+  See GetNetworkPeers for the parsed peer entries.
 */
 func NetworkPeers(url string) (string, string) {
 	var body, status string
@@ -203,6 +206,24 @@ func NetworkPeers(url string) (string, string) {
 
 }
 
+/*
+  returns the peers known by a peer (ID, gRPC address, type, pkiID), parsed from /network/peers.
+	url  (http://IP:PORT) is the address of network peer
+*/
+func GetNetworkPeers(url string) ([]peernetwork.PeerEndpoint, error) {
+	endpoints, err := peernetwork.GetNetworkPeers(url)
+	if err != nil {
+		fmt.Println("GetNetworkPeers() ERROR: ", err)
+		return nil, err
+	}
+	if verbose {
+		for _, ep := range endpoints {
+			fmt.Println("GetNetworkPeers() peer: ", ep.ID, ep.Address, ep.Type)
+		}
+	}
+	return endpoints, nil
+}
+
 /*
   displays if the given user has been already registed.
 	url  (http://IP:PORT) is the address of network peer
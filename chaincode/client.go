@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"sync"
+
+	"obcsdk/peernetwork"
+)
+
+/*
+  A Client is the chaincode API on one network, with the chaincodes deployed on it. The package functions
+  (Deploy, InvokeOnPeer, QueryOnHost, ...) work on ThisNetwork and LibCC; each Client works on its own network,
+  so that several networks can be driven at once, from different goroutines: e.g. a v0.5 and a v0.6 network
+  under the same load, each by its own chco2.Scenario.
+
+	netB, _ := peernetwork.LoadNetworkByName("netB")
+	clientB := chaincode.NewClient(netB, peernetwork.InitializeChainCodes())
+	clientB.RegisterUsers()
+	clientB.DeployOnPeer([]string{"example02", "init", "PEER0"}, []string{"a", "100", "b", "200"})
+*/
+type Client struct {
+	Network peernetwork.PeerNetwork
+	LibCC   peernetwork.LibChainCodes	// the chaincodes, and the txIds they were deployed with on Network
+}
+
+// Guards the deployment txIds recorded in the chaincode details of every Client, since a Client
+// may invoke from one goroutine while it deploys from another.
+var deployLock sync.Mutex
+
+func NewClient(thisNetwork peernetwork.PeerNetwork, lib peernetwork.LibChainCodes) *Client {
+	return &Client{Network: thisNetwork, LibCC: lib}
+}
+
+// returns the txId of a deployment recorded in chaincode details or versions (key dep_txid, or a tag name)
+func deployment(record map[string]string, key string) string {
+	deployLock.Lock()
+	defer deployLock.Unlock()
+	return record[key]
+}
+
+func recordDeployment(record map[string]string, key string, txId string) {
+	deployLock.Lock()
+	defer deployLock.Unlock()
+	record[key] = txId
+}
+
+/*
+  returns the Client of the package functions, on ThisNetwork and LibCC; with the chaincode named in args,
+  it also sets ChainCodeDetails and Versions to its details, as the package functions always did.
+*/
+func packageClient(args []string) *Client {
+	if len(args) > 0 {
+		ChainCodeDetails, Versions, _ = peernetwork.GetCCDetailByName(args[0], LibCC)
+	}
+	Peers = ThisNetwork.Peers
+	return NewClient(ThisNetwork, LibCC)
+}
+
+// RegisterUsers runs Client.RegisterUsers on ThisNetwork.
+func RegisterUsers() bool {
+	return packageClient(nil).RegisterUsers()
+}
+
+// Deploy runs Client.Deploy on ThisNetwork.
+func Deploy(args []string, depargs []string) (id string, err error) {
+	return packageClient(args).Deploy(args, depargs)
+}
+
+// DeployOnPeer runs Client.DeployOnPeer on ThisNetwork.
+func DeployOnPeer(args []string, depargs []string) (id string, err error) {
+	return packageClient(args).DeployOnPeer(args, depargs)
+}
+
+// Invoke runs Client.Invoke on ThisNetwork.
+func Invoke(args []string, invokeargs []string) (id string, err error) {
+	return packageClient(args).Invoke(args, invokeargs)
+}
+
+// InvokeOnPeer runs Client.InvokeOnPeer on ThisNetwork.
+func InvokeOnPeer(args []string, invokeargs []string) (id string, err error) {
+	return packageClient(args).InvokeOnPeer(args, invokeargs)
+}
+
+// InvokeAsUser runs Client.InvokeAsUser on ThisNetwork.
+func InvokeAsUser(args []string, invokeargs []string) (id string, err error) {
+	return packageClient(args).InvokeAsUser(args, invokeargs)
+}
+
+// Query runs Client.Query on ThisNetwork.
+func Query(args []string, queryArgs []string) (id string, err error) {
+	return packageClient(args).Query(args, queryArgs)
+}
+
+// QueryOnHost runs Client.QueryOnHost on ThisNetwork.
+func QueryOnHost(args []string, queryargs []string) (id string, err error) {
+	return packageClient(args).QueryOnHost(args, queryargs)
+}
+
+// GetChainHeight runs Client.GetChainHeight on ThisNetwork.
+func GetChainHeight(host string) (ht int, err error) {
+	return packageClient(nil).GetChainHeight(host)
+}
+
+// GetBlockTrxInfoByHost runs Client.GetBlockTrxInfoByHost on ThisNetwork.
+func GetBlockTrxInfoByHost(host string, block int) (bsNonHash NonHashData, err error) {
+	return packageClient(nil).GetBlockTrxInfoByHost(host, block)
+}
+
+// GetBlockByHost runs Client.GetBlockByHost on ThisNetwork.
+func GetBlockByHost(host string, block int) (Block, error) {
+	return packageClient(nil).GetBlockByHost(host, block)
+}
@@ -0,0 +1,235 @@
+package chco2
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+  A ChainOracle checks the blockchain of each peer against the transactions chco2 submitted, instead of
+  predicting the chain height from the batch size: it records the UUID of every transaction a peer
+  accepted (the deploys, and the invokes that returned a UUID), reads the blocks of the peers, and checks
+  that every processed transaction is in the chain exactly once, that no block has more transactions than
+  the batch size, that a chain has no blocks of unknown transactions, and that the peers have the same
+  blocks (a peer may lag behind, with blocks missing at the end, but not have other ones).
+
+  The blocks already read are kept, since the blocks of a chain do not change; unless the peer ledger is
+  wiped (Forget), or the peer chain gets shorter.
+*/
+type ChainOracle struct {
+	BatchSize int
+	submitted []string       // the UUIDs of the accepted transactions, in the order they were submitted
+	processed int            // the first ones, sent while the network had consensus, must be in the chains
+	known     map[string]int // the index of each UUID in submitted
+	deploys   map[string]bool
+	chains    map[string][][]string // the UUIDs of the transactions of blocks 1.. (block 0 is the genesis block), by peer
+}
+
+// The outcome of the check of the chain of one peer.
+type ChainCheck struct {
+	Height     int
+	Missing    int      // processed transactions not in the chain
+	MissingIDs []string // their UUIDs, in the order they were submitted
+	Duplicates []string // transactions more than once in the chain
+	Oversized  []int    // blocks with more than BatchSize transactions
+	Unknown    int      // transactions chco2 did not submit, after the blocks of the network before the test
+	Diverged   int      // the first block that differs from the reference chain; 0 if none
+	Expected   int      // the height of the chain once all the processed transactions are in it
+	Err        error    // the chain could not be read
+}
+
+func NewChainOracle(batchSize int) *ChainOracle {
+	return &ChainOracle{
+		BatchSize: batchSize,
+		known:     make(map[string]int),
+		deploys:   make(map[string]bool),
+		chains:    make(map[string][][]string),
+	}
+}
+
+// Records a transaction accepted by a peer; a redeploy of the same chaincode has the same UUID.
+func (c *ChainOracle) Submitted(uuid string, deploy bool) {
+	if uuid == "" {
+		return
+	}
+	if deploy {
+		c.deploys[uuid] = true
+	}
+	if _, found := c.known[uuid]; found {
+		return
+	}
+	c.known[uuid] = len(c.submitted)
+	c.submitted = append(c.submitted, uuid)
+}
+
+// Marks all the transactions accepted so far as processed: the network has consensus, so they must be in the chains.
+func (c *ChainOracle) Processed() { c.processed = len(c.submitted) }
+
+// The number of transactions accepted, and how many of them must be in the chains.
+func (c *ChainOracle) Counts() (submitted int, processed int) { return len(c.submitted), c.processed }
+
+// Returns a peer whose chain, as last read, holds the transaction, and the block; "" if none.
+func (c *ChainOracle) Locate(uuid string) (string, int) {
+	for peer, blocks := range c.chains {
+		for n, uuids := range blocks {
+			for _, id := range uuids {
+				if id == uuid {
+					return peer, n + 1
+				}
+			}
+		}
+	}
+	return "", 0
+}
+
+// Drops the blocks read from a peer, e.g. once its ledger is wiped.
+func (c *ChainOracle) Forget(peer string) {
+	delete(c.chains, peer)
+}
+
+// Returns the transactions of the blocks 1..height-1 of the peer chain, reading only the blocks not read yet.
+func (c *ChainOracle) chain(peer string, height int, read func(block int) ([]string, error)) ([][]string, error) {
+	blocks := c.chains[peer]
+	if len(blocks) > height-1 {
+		blocks = nil // the chain got shorter: the ledger was replaced
+	}
+	for block := len(blocks) + 1; block < height; block++ {
+		uuids, err := read(block)
+		if err != nil {
+			c.chains[peer] = blocks
+			return blocks, err
+		}
+		blocks = append(blocks, uuids)
+	}
+	c.chains[peer] = blocks
+	return blocks, nil
+}
+
+/*
+  Checks the chains of the peers ("" for a peer not running), given their heights. The transactions sent
+  since the network lost consensus may be queued, not processed yet: they may be missing. The reference
+  chain, against which the others must not diverge, is the longest chain that passes its own checks, or
+  else the longest.
+*/
+func (c *ChainOracle) Check(peers []string, heights []int, read func(peer string, block int) ([]string, error)) []ChainCheck {
+	checks := make([]ChainCheck, len(peers))
+	chains := make([][][]string, len(peers))
+	for i, peer := range peers {
+		checks[i].Height = heights[i]
+		if peer == "" {
+			continue
+		}
+		p := peer
+		chains[i], checks[i].Err = c.chain(peer, heights[i], func(block int) ([]string, error) { return read(p, block) })
+		if checks[i].Err == nil {
+			c.checkChain(chains[i], &checks[i])
+		}
+	}
+
+	reference := -1
+	for i := range peers {
+		if peers[i] == "" || checks[i].Err != nil {
+			continue
+		}
+		if reference < 0 || (checks[i].ok() && !checks[reference].ok()) ||
+			(checks[i].ok() == checks[reference].ok() && len(chains[i]) > len(chains[reference])) {
+			reference = i
+		}
+	}
+	for i := range peers {
+		if peers[i] == "" || checks[i].Err != nil || i == reference || reference < 0 {
+			continue
+		}
+		for block := 0; block < len(chains[i]) && block < len(chains[reference]); block++ {
+			if !sameBlock(chains[i][block], chains[reference][block]) {
+				checks[i].Diverged = block + 1
+				break
+			}
+		}
+	}
+	return checks
+}
+
+func (c *ChainOracle) checkChain(blocks [][]string, check *ChainCheck) {
+	count := make(map[string]int)
+	ours := false // past the blocks of the network before the test
+	check.Expected = 1
+	for n, uuids := range blocks {
+		if len(uuids) > c.BatchSize {
+			check.Oversized = append(check.Oversized, n+1)
+		}
+		for _, uuid := range uuids {
+			index, known := c.known[uuid]
+			if !known {
+				if ours {
+					check.Unknown++
+				}
+				continue
+			}
+			if !c.deploys[uuid] {
+				ours = true // a deploy may be in the chain from a previous test, with the same UUID
+			}
+			count[uuid]++
+			if count[uuid] == 2 && !c.deploys[uuid] {
+				check.Duplicates = append(check.Duplicates, uuid)
+			}
+			if index < c.processed && n+2 > check.Expected {
+				check.Expected = n + 2
+			}
+		}
+		if !ours {
+			check.Expected = n + 2
+		}
+	}
+	for _, uuid := range c.submitted[:c.processed] {
+		if count[uuid] == 0 {
+			check.Missing++
+			check.MissingIDs = append(check.MissingIDs, uuid)
+		}
+	}
+}
+
+func (check ChainCheck) ok() bool {
+	return check.Err == nil && check.Missing == 0 && len(check.Duplicates) == 0 && len(check.Oversized) == 0 &&
+		check.Unknown == 0 && check.Diverged == 0
+}
+
+// Why the chain does not pass, e.g. "3 missing, 2 duplicate"; "" if it passes.
+func (check ChainCheck) Problems() string {
+	var problems []string
+	if check.Err != nil {
+		problems = append(problems, "unreadable: "+check.Err.Error())
+	}
+	if check.Missing > 0 {
+		problems = append(problems, strconv.Itoa(check.Missing)+" missing")
+	}
+	if len(check.Duplicates) > 0 {
+		problems = append(problems, strconv.Itoa(len(check.Duplicates))+" duplicate")
+	}
+	if len(check.Oversized) > 0 {
+		var blocks []string
+		for _, block := range check.Oversized {
+			blocks = append(blocks, strconv.Itoa(block))
+		}
+		problems = append(problems, "oversized blocks "+strings.Join(blocks, ","))
+	}
+	if check.Unknown > 0 {
+		problems = append(problems, strconv.Itoa(check.Unknown)+" unknown")
+	}
+	if check.Diverged > 0 {
+		problems = append(problems, "diverged at block "+strconv.Itoa(check.Diverged))
+	}
+	return strings.Join(problems, ", ")
+}
+
+func sameBlock(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,266 @@
+package chco2
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/peernetwork"
+	"obcsdk/threadutil"
+)
+
+/*
+  A Chaos run disrupts the network at random, for long regression tests (CRT): each round either disrupts
+  more validating peers, with a disruption picked from the Menu by weight, or heals one of the disruptions
+  in place; then it sends invokes and queries all peers. At most MaxDown peers are disrupted at once: by
+  default F, so the network keeps consensus; set it above F to deliberately lose consensus now and then.
+
+  Every choice comes from a random generator seeded with Seed, and depends on nothing else, so a run bounded
+  by Rounds can be replayed exactly: RunChaos prints the seed, logs every action (in the output, the steps.log
+  of the artifacts and the chaos of the JSON results), and CHCO2_CHAOS_SEED=<seed> replays it. A run bounded
+  by Duration replays the same choices, but may end after more or fewer rounds. Stops use DisruptionMode, e.g.
+
+	chaos := chco2.NewChaos(0, chco2.SleepTimeMinutes(600))
+	chaos.Menu = chco2.ChaosMenu{Stop: 4, Pause: 2, Kill: 1, Partition: 1, Throttle: 2}
+	chco2.RunChaos(&chaos)
+*/
+
+// the disruptions of a chaos run
+const (
+	ChaosStop      = "stop"
+	ChaosPause     = "pause"
+	ChaosKill      = "kill"
+	ChaosPartition = "partition" // the peers are cut off from the others, until healed
+	ChaosThrottle  = "throttle"  // the peers get ThrottleLimits
+)
+
+// The weights of the disruptions; 0 leaves one out.
+type ChaosMenu struct {
+	Stop, Pause, Kill, Partition, Throttle int
+}
+
+type Chaos struct {
+	Seed            int64         // 0 for CHCO2_CHAOS_SEED, else the time; CHCO2_CHAOS_SEED always wins, to replay a run
+	Duration        time.Duration // no new round after this long; 0 for no limit
+	Rounds          int           // the number of rounds; 0 for no limit
+	Menu            ChaosMenu
+	MaxDown         int // the most validating peers disrupted at once; 0 for F
+	InvokesPerRound int // 0 for InvokesRequiredForCatchUp
+	ThrottleLimits  peernetwork.ResourceLimits
+	Actions         []string // the actions of the run, filled in by RunChaos
+}
+
+// What a chaos run did, in the JSON results.
+type ChaosReport struct {
+	Seed    int64    `json:"seed"`
+	Actions []string `json:"actions"`
+}
+
+// a disruption in place
+type chaosDisruption struct {
+	kind  string
+	peers []int
+}
+
+// Returns a chaos run of the duration that stops one peer at a time.
+func NewChaos(seed int64, duration time.Duration) Chaos {
+	return Chaos{Seed: seed, Duration: duration, Menu: ChaosMenu{Stop: 1}, ThrottleLimits: peernetwork.ResourceLimits{CPUPercent: 10}}
+}
+
+// Picks a disruption by weight; partition only if no partition is in place, since healing heals them all.
+func (menu ChaosMenu) pick(rng *rand.Rand, partitioned bool) string {
+	kinds := []string{ChaosStop, ChaosPause, ChaosKill, ChaosPartition, ChaosThrottle}
+	weights := []int{menu.Stop, menu.Pause, menu.Kill, menu.Partition, menu.Throttle}
+	if partitioned {
+		weights[3] = 0
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return ""
+	}
+	r := rng.Intn(total)
+	for i, w := range weights {
+		if r < w {
+			return kinds[i]
+		}
+		r -= w
+	}
+	return ""
+}
+
+// Runs the chaos rounds until Rounds or Duration (set at least one), then heals the network and checks it
+// converges; the actions are in c.Actions.
+func (s *Scenario) RunChaos(c *Chaos) {
+	if !s.beginStep("RunChaos") {
+		return
+	}
+	defer s.endStep()
+	envvar := strings.TrimSpace(os.Getenv("CHCO2_CHAOS_SEED"))
+	seed, err := strconv.ParseInt(envvar, 10, 64)
+	if envvar != "" && err != nil {
+		fmt.Println("RunChaos(): WARNING: ignoring invalid value (" + envvar + ") for CHCO2_CHAOS_SEED; using a new seed")
+	}
+	if envvar != "" && err == nil {
+		c.Seed = seed
+	} else if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(c.Seed))
+	maxDown := c.MaxDown
+	if maxDown <= 0 {
+		maxDown = s.NumberOfPeersOkToFail
+	}
+	invokes := c.InvokesPerRound
+	if invokes <= 0 {
+		invokes = s.InvokesRequiredForCatchUp
+	}
+	c.Actions = nil
+	s.testReport.Chaos = &ChaosReport{Seed: c.Seed}
+	myStr := fmt.Sprintf("CHAOS seed %d (replay with CHCO2_CHAOS_SEED=%d): duration %s, rounds %d, at most %d peers down (F=%d), menu %+v",
+		c.Seed, c.Seed, c.Duration, c.Rounds, maxDown, s.NumberOfPeersOkToFail, c.Menu)
+	fmt.Println("\n" + myStr)
+	s.LogStep(myStr)
+
+	var active []chaosDisruption
+	disrupted := make(map[int]bool)
+	counts := make(map[int]int)
+	started := time.Now()
+	for round := 1; (c.Rounds == 0 || round <= c.Rounds) && (c.Duration == 0 || time.Since(started) < c.Duration); round++ {
+		if s.Interrupted() {
+			return // restore_all brings the peers back
+		}
+		var candidates []int
+		for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+			if s.peerIsValidating(n) && !disrupted[n] {
+				candidates = append(candidates, n)
+			}
+		}
+		down := len(disrupted)
+		partitioned := false
+		for _, d := range active {
+			if d.kind == ChaosPartition {
+				partitioned = true
+			}
+		}
+
+		action := ""
+		if len(active) > 0 && (down >= maxDown || len(candidates) == 0 || rng.Intn(2) == 0) {
+			i := rng.Intn(len(active))
+			d := active[i]
+			active = append(active[:i], active[i+1:]...)
+			for _, peerNum := range d.peers {
+				delete(disrupted, peerNum)
+			}
+			action = "heal " + d.kind + " " + peerNames(d.peers)
+			s.logChaos(c, round, action)
+			switch d.kind {
+			case ChaosPartition:
+				s.HealPartitions()
+			case ChaosThrottle:
+				s.UnthrottlePeers(d.peers)
+			default:
+				s.RestartPeers(d.peers)
+			}
+		} else if kind := c.Menu.pick(rng, partitioned); kind != "" && down < maxDown && len(candidates) > 0 {
+			room := maxDown - down
+			if room > len(candidates) {
+				room = len(candidates)
+			}
+			var peers []int
+			for _, i := range rng.Perm(len(candidates))[:1+rng.Intn(room)] {
+				peers = append(peers, candidates[i])
+			}
+			sort.Ints(peers) // SDK limitation requires listing the lower one first
+			for _, peerNum := range peers {
+				disrupted[peerNum] = true
+				counts[peerNum]++
+			}
+			active = append(active, chaosDisruption{kind: kind, peers: peers})
+			action = kind + " " + peerNames(peers)
+			s.logChaos(c, round, action)
+			switch kind {
+			case ChaosStop:
+				s.StopPeers(peers)
+			case ChaosPause:
+				s.StopPeersWithMode(peers, DisruptPause)
+			case ChaosKill:
+				s.StopPeersWithMode(peers, DisruptKill)
+			case ChaosPartition:
+				var others []int // the running peers left alone; peers are among the disrupted by now
+				for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+					if !disrupted[n] && peerIsRunning(n, s.MyNetwork) {
+						others = append(others, n)
+					}
+				}
+				if len(others) == 0 {
+					fmt.Println("CHAOS: no running, undisrupted peer to partition " + peerNames(peers) + " from; skipped")
+					break
+				}
+				s.PartitionPeers([][]int{peers, others})
+			case ChaosThrottle:
+				s.ThrottlePeers(peers, c.ThrottleLimits)
+			}
+		} else {
+			action = "no disruption"
+			s.logChaos(c, round, action)
+		}
+		s.Invokes(invokes)
+		s.QueryAllPeers("CHAOS round " + strconv.Itoa(round) + " (seed " + strconv.FormatInt(c.Seed, 10) + "), after " + action + " and Invokes")
+	}
+
+	// heal what is still in place, latest first, and check the network recovers
+	for i := len(active) - 1; i >= 0; i-- {
+		d := active[i]
+		s.logChaos(c, 0, "heal "+d.kind+" "+peerNames(d.peers))
+		switch d.kind {
+		case ChaosPartition:
+			s.HealPartitions()
+		case ChaosThrottle:
+			s.UnthrottlePeers(d.peers)
+		default:
+			s.RestartPeers(d.peers)
+		}
+	}
+	s.Invokes(s.InvokesRequiredForCatchUp)
+	s.EventuallyAtLeastConsensusAgree(SleepTimeMinutes(5))
+
+	myStr = fmt.Sprintf("CHAOS seed %d done: %d actions in %s; times each peer was disrupted:", c.Seed, len(c.Actions), time.Since(started))
+	for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+		if s.peerIsValidating(n) {
+			myStr += fmt.Sprintf(" %s(%d)", threadutil.GetPeer(n), counts[n])
+		}
+	}
+	fmt.Println(myStr)
+	s.LogStep(myStr)
+}
+
+// Logs a chaos action; round 0 for the healing at the end.
+func (s *Scenario) logChaos(c *Chaos, round int, action string) {
+	when := "end"
+	if round > 0 {
+		when = "round " + strconv.Itoa(round)
+		if c.Rounds > 0 {
+			when += "/" + strconv.Itoa(c.Rounds)
+		}
+	}
+	entry := when + ": " + action
+	c.Actions = append(c.Actions, entry)
+	s.testReport.Chaos.Actions = c.Actions
+	fmt.Println("\nCHAOS " + entry)
+	s.LogStep("CHAOS " + entry)
+}
+
+func peerNames(peerNums []int) string {
+	var names []string
+	for _, peerNum := range peerNums {
+		names = append(names, threadutil.GetPeer(peerNum))
+	}
+	return strings.Join(names, " ")
+}
@@ -8,7 +8,10 @@ import (
 	"errors"
 	"bufio"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"obcsdk/chaincode"
+	"obcsdk/peerlogs"
 	"obcsdk/peernetwork"
 	"obcsdk/threadutil"
 	"strconv"
@@ -47,107 +50,48 @@ const (
 	OutputSummaryFileName = "GO_TESTS_SUMMARY"
 )
 
+// How long DeployInit waits for the deployed chaincode to answer queries (the peer builds its container first)
+var DeployTimeout = SleepTimeMinutes(5)
 
-var Writer *bufio.Writer
-var url string
-var MyNetwork peernetwork.PeerNetwork
-var Verbose bool	// Another option: go edit  "verbose" in chaincode/const.go for more info about lower level functions operation
-var Stop_on_error bool
-var RanToCompletion bool
-var CurrentTestName string
-var currA, currB int
-var initA, initB  string
-var currCH  int
-var queryTestsPass, chainHeightTestsPass bool
-
-type peerQData struct {
-	resA int
-	resB int
-}
-// Use slices, not an array. NumberOfPeersInNetwork is set after initialization, so leave size open-ended for now.
-var qData []peerQData		// latest queried values for A and B for each peer
-var qtransPerPeerForCH []int 	// counts of transactions queued per peer, for calculating blockchainheight
-var qtrans int			// counts of transactions queued, for calculating expected values of A & B
-
-// bools to control when to stop/abort test (and to print additional error msgs when that happens)
-
-var EnforceQueryTestsPass, EnforceChainHeightTestsPass bool
-
-// bools ...MustMatchExpected... :
-// True means strict mode: the values must be in consensus AND match an internal counter based on our testcase logic/expectations.
-// False means lenient mode, or "Consensus only": the values must match each other but not an internal counter.
-//    Use false when we can't fully understand or complete our own test code logic for the counters.
-
-var QsMustMatchExpected bool 	// Queried values (A & B) must match the internal counters = "expected" values (currA & currB) 
-var CHsMustMatchExpected bool 	// ChainHeight values (CH) must match the internal counter = "expected" value (currCH)
-
-// AllRunningNodesMustMatch=true implies for ALL running nodes; false implies only just enough for consensus.
-// Typically set false except at very beginning and in CatchUpAndConfirm() - after sending
-// many many (how many?) invokes that guarantee every node catches up.
-
-var AllRunningNodesMustMatch bool
-
-var NetworkAlreadyRunning bool
-
-var LoggingLevel string
-var localNetwork bool
-var Security bool
-var ConsensusMode string
-var PbftMode string
-var NumberOfPeersInNetwork int
-var NumberOfPeersOkToFail int
-var MaxNumberOfPeersThatCanFailWhileStillHaveConsensus int
-var MinNumberOfPeersNeededForConsensus int
-var NumberOfPeersNeededForConsensus int
-var InvokesRequiredForCatchUp int
-var K int
-var logmultiplier int
-var batchsize int	// Note: default CORE_PBFT_GENERAL_BATCHSIZE=500. If you change this to anything more than 5, then
-			//	set InvokesRequiredForCatchUp to 200 for a good effort to ensure catch up - but
-			//	be aware that this will be lower than the required number so some testcases that
-			//	may fail sometimes if they set CHsMustMatchExpected=true.
-var batchtimeout int							// default 2
-var batchTimeout string	// CORE_PBFT_GENERAL_TIMEOUT_BATCH=2s		// default 2s
-			// CORE_PBFT_GENERAL_TIMEOUT_REQUEST=10s	// default 2
-			// CORE_PBFT_GENERAL_TIMEOUT_VIEWCHANGE=2s	// default 2
-			// CORE_PBFT_GENERAL_TIMEOUT_NULLREQUEST=1s	// default 0 = disable keep-alive nullrequests
-
-var pauseInsteadOfStop bool	// Set pauseInsteadOfStop to true to run all tests using docker pause/unpause
-				// instead of docker stop/restart. This allows tests to be reused, instead of duplicated.
-
-
+// Disruption modes: how StopPeers takes a peer node down, and so how RestartPeers brings it back.
+const (
+	DisruptStop     = "STOP"		// docker stop: graceful shutdown
+	DisruptPause    = "PAUSE"		// docker pause: the peer freezes, and all its connections stay open
+	DisruptKill     = "KILL"		// docker kill: SIGKILL, no graceful shutdown, like a crash
+	DisruptKillWipe = "KILL_WIPE"		// SIGKILL, and delete the ledger: the peer restarts empty, and needs state transfer from genesis
+)
 
 
 
-func Setup(testName string, started time.Time) {
-	setup_part1(testName, started)
-	setup_part2_network()
-	setup_part3_verifyNetworkAndDeployCC()
+func (s *Scenario) Setup(testName string, started time.Time) {
+	s.setup_part1(testName, started)
+	s.setup_part2_network()
+	s.setup_part3_verifyNetworkAndDeployCC()
 }
 
-func SetupQuick(testName string, started time.Time) {
-	setup_part1(testName, started)
+func (s *Scenario) SetupQuick(testName string, started time.Time) {
+	s.setup_part1(testName, started)
 	fmt.Println("chco2.SetupQuick(): Skipping creation of a network; assuming one is already running.")
-	setup_part3_verifyNetworkAndDeployCC()
+	s.setup_part3_verifyNetworkAndDeployCC()
 }
 
-func setup_part1(testName string, started time.Time) {
+func (s *Scenario) setup_part1(testName string, started time.Time) {
 
 	//---------------------------------------------------------------------------------------------------------------
 	// configure the booleans, environment variables, and the test parameter constants and slices that depend on them
 	//---------------------------------------------------------------------------------------------------------------
 
-	CurrentTestName = testName
-	RanToCompletion = false
-	Verbose = false			// See also:  "verbose" in chaincode/const.go for lower level functions
-	Stop_on_error = false
-	queryTestsPass = true
-	chainHeightTestsPass = true
-	EnforceQueryTestsPass = true
-	EnforceChainHeightTestsPass = true
-	QsMustMatchExpected = true 	// Queried values (A & B) must match the internal counters = "expected" values (currA & currB) 
-	CHsMustMatchExpected = false 	// ChainHeight values (CH) must match the internal counter = "expected" value (currCH)
-	AllRunningNodesMustMatch = true // values should match on ALL avail nodes - not just enough nodes for consensus;
+	s.CurrentTestName = testName
+	s.RanToCompletion = false
+	s.Verbose = false			// See also:  "verbose" in chaincode/const.go for lower level functions
+	s.Stop_on_error = false
+	s.queryTestsPass = true
+	s.chainHeightTestsPass = true
+	s.EnforceQueryTestsPass = true
+	s.EnforceChainHeightTestsPass = true
+	s.QsMustMatchExpected = true 	// Queried values must match the internal counters = "expected" values (the oracle) 
+	s.CHsMustMatchExpected = true 	// ChainHeight values (CH) must match the "expected" value (currCH) of the chain oracle
+	s.AllRunningNodesMustMatch = true // values should match on ALL avail nodes - not just enough nodes for consensus;
 					// set true after sending enough invokes to ensure all nodes caught up; not sure how many,
 					// or why, so most testcases should set this to false after the initial setup and query is done.
 
@@ -159,22 +103,32 @@ func setup_part1(testName string, started time.Time) {
 	// default settings, so that our test code uses the same values as the running peers and we can
 	// tune our tests accordingly (e.g. counting transactions).
 
-	localNetwork = true
-	NumberOfPeersInNetwork = 4	//  CORE_PBFT_GENERAL_N         - number of validating peers in the network
-	NumberOfPeersOkToFail = 1	//  CORE_PBFT_GENERAL_F         - max # possible faulty nodes while still can reach consensus
-	LoggingLevel = "error"		//  CORE_LOGGING_LEVEL          - [critical|error|warning|notice|info|debug] as defined in peer/core.yaml
-	Security = true			//  CORE_SECURITY_ENABLED       - use secure network using MemberSrvc CA [Y|N]
-	ConsensusMode = "pbft"		//  CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN - consensus mode [pbft|...]
-        PbftMode = "batch"		//  CORE_PBFT_GENERAL_MODE      - pbft mode [batch|noops]
-	batchsize = 2			//  CORE_PBFT_GENERAL_BATCHSIZE - max # Tx sent in each batch for ordering; we override the default [500]
-	batchTimeout = "2s"		//  CORE_PBFT_GENERAL_TIMEOUT_BATCH=2s
-	batchtimeout = 2		//    - default 2 in v0.5 Jun 2016, default 1 in gerrit fabric Aug 2016
-	pauseInsteadOfStop = false	//  STOP_OR_PAUSE               - MODE used by GO tests when disrupting network CA and Peer nodes [STOP|PAUSE]
+	s.localNetwork = true
+	s.NumberOfPeersInNetwork = 4	//  CORE_PBFT_GENERAL_N         - number of validating peers in the network
+	s.NumberOfPeersOkToFail = 1	//  CORE_PBFT_GENERAL_F         - max # possible faulty nodes while still can reach consensus
+	s.LoggingLevel = "error"		//  CORE_LOGGING_LEVEL          - [critical|error|warning|notice|info|debug] as defined in peer/core.yaml
+	s.Security = true			//  CORE_SECURITY_ENABLED       - use secure network using MemberSrvc CA [Y|N]
+	s.ConsensusMode = "pbft"		//  CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN - consensus mode [pbft|...]
+        s.PbftMode = "batch"		//  CORE_PBFT_GENERAL_MODE      - pbft mode [batch|noops]
+	s.batchsize = 2			//  CORE_PBFT_GENERAL_BATCHSIZE - max # Tx sent in each batch for ordering; we override the default [500]
+	s.batchTimeout = "2s"		//  CORE_PBFT_GENERAL_TIMEOUT_BATCH=2s
+	s.batchtimeout = 2		//    - default 2 in v0.5 Jun 2016, default 1 in gerrit fabric Aug 2016
+	s.DisruptionMode = DisruptStop	//  STOP_OR_PAUSE               - MODE used by GO tests when disrupting network CA and Peer nodes [STOP|PAUSE|KILL|KILL_WIPE]
+	s.peerDisruptedBy = make(map[int]string)
+	s.stoppedWhilePrimary = make(map[int]bool)
+	s.CatchUpBlocks = 10		//  CHCO2_WAIT_CATCHUP_BLOCKS   - after restart, wait for peers to be within this many blocks of the others [10; -1=no wait]
+	s.healthMonitoring = true		//  CHCO2_HEALTH_MONITOR        - poll peers in background and reconcile their states [TRUE|FALSE]
+	s.healthInterval = 5		//  CHCO2_HEALTH_INTERVAL       - seconds between health polls [5]
+	s.ArtifactsMode = "ONFAIL"	//  CHCO2_ARTIFACTS             - capture peer logs etc. at end of test [ALWAYS|ONFAIL|NEVER]
+	s.ArtifactsDir = "artifacts"	//  CHCO2_ARTIFACTS_DIR         - directory for the per-test artifacts directories [./artifacts]
+	s.NumberOfNVPs = 0		//  CHCO2_NVPS                  - number of non-validating peers to add to a new network [0]
+	s.ResultsDir = "results"		//  CHCO2_RESULTS_DIR           - directory for the JSON and JUnit XML results of each test [./results, NONE=none]
+	oracleName := "example02"	//  CHCO2_ORACLE                - model of the chaincode, when the test plugs none in Scenario.Oracle [example02|addrecs]
 
 					// Others that we may use in future:
 					//  CORE_PBFT_GENERAL_TIMEOUT_BATCH - batch timeout value, use s for seconds, default=[2s]
-	logmultiplier = 4		//  CORE_PBFT_GENERAL_LOGMULTIPLIER - logmultiplier [4]
-	K = 10				//  CORE_PBFT_GENERAL_K             - checkpoint period K [10]
+	s.logmultiplier = 4		//  CORE_PBFT_GENERAL_LOGMULTIPLIER - logmultiplier [4]
+	s.K = 10				//  CORE_PBFT_GENERAL_K             - checkpoint period K [10]
 
 
 	//---------------------------------------------------------------------------------------------------------------
@@ -183,29 +137,49 @@ func setup_part1(testName string, started time.Time) {
 
 	var envvar string
 	envvar = strings.ToUpper(os.Getenv("NETWORK"))
-	if envvar != "" && envvar != "LOCAL" { localNetwork = false }
+	if envvar != "" && envvar != "LOCAL" { s.localNetwork = false }
 	if strings.ToUpper(os.Getenv("CHCO2_VERBOSE")) == "TRUE" {
-		Verbose = true 	// Another option: edit  "verbose" in chaincode/const.go for more info about lower level functions operation
+		s.Verbose = true 	// Another option: edit  "verbose" in chaincode/const.go for more info about lower level functions operation
 	}
 	envvar = strings.TrimSpace(os.Getenv("CORE_PBFT_GENERAL_N"))
-	if envvar != "" { NumberOfPeersInNetwork, _ = strconv.Atoi(envvar) }
+	if envvar != "" { s.NumberOfPeersInNetwork, _ = strconv.Atoi(envvar) }
+	s.NumberOfValidatingPeers = s.NumberOfPeersInNetwork
 	envvar = strings.TrimSpace(os.Getenv("CORE_PBFT_GENERAL_F"))
-	if envvar != "" { NumberOfPeersOkToFail, _  = strconv.Atoi(envvar) }
+	if envvar != "" { s.NumberOfPeersOkToFail, _  = strconv.Atoi(envvar) }
 	envvar = strings.TrimSpace(os.Getenv("CORE_LOGGING_LEVEL"))
-	if envvar != "" { LoggingLevel = envvar }
+	if envvar != "" { s.LoggingLevel = envvar }
 	envvar = strings.TrimSpace(os.Getenv("CORE_SECURITY_ENABLED"))
-	if strings.ToUpper(envvar) == "N" { Security = false }
+	if strings.ToUpper(envvar) == "N" { s.Security = false }
 	envvar = strings.TrimSpace(os.Getenv("CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN"))
-	if envvar != "" { ConsensusMode = envvar }
+	if envvar != "" { s.ConsensusMode = envvar }
 	envvar = strings.TrimSpace(os.Getenv("CORE_PBFT_GENERAL_MODE"))
-	if envvar != "" { PbftMode = strings.ToUpper(envvar) }
+	if envvar != "" { s.PbftMode = strings.ToUpper(envvar) }
 	envvar = strings.TrimSpace(os.Getenv("CORE_PBFT_GENERAL_BATCHSIZE"))
-	if envvar != "" { batchsize, _ = strconv.Atoi(envvar) }
+	if envvar != "" { s.batchsize, _ = strconv.Atoi(envvar) }
 	// Must read batchTimeout (string) and set batchtimeout (int) after stripping the trailing 's'...
 	// envvar = strings.TrimSpace(os.Getenv("CORE_PBFT_GENERAL_TIMEOUT_BATCH"))
 	// if envvar != "" { batchtimeout, _  = strconv.Atoi(envvar) }
 	envvar = strings.TrimSpace(os.Getenv("STOP_OR_PAUSE"))
-	if strings.ToUpper(envvar) == "PAUSE" { pauseInsteadOfStop = true }
+	switch strings.ToUpper(envvar) {
+	case DisruptPause, DisruptKill, DisruptKillWipe: s.DisruptionMode = strings.ToUpper(envvar)
+	}
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_WAIT_CATCHUP_BLOCKS"))
+	if envvar != "" { s.CatchUpBlocks, _ = strconv.Atoi(envvar) }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_HEALTH_MONITOR"))
+	if strings.ToUpper(envvar) == "FALSE" { s.healthMonitoring = false }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_HEALTH_INTERVAL"))
+	if envvar != "" { s.healthInterval, _ = strconv.Atoi(envvar) }
+	envvar = strings.ToUpper(strings.TrimSpace(os.Getenv("CHCO2_ARTIFACTS")))
+	if envvar == "ALWAYS" || envvar == "ONFAIL" || envvar == "NEVER" { s.ArtifactsMode = envvar }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_ARTIFACTS_DIR"))
+	if envvar != "" { s.ArtifactsDir = envvar }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_NVPS"))
+	if envvar != "" { s.NumberOfNVPs, _ = strconv.Atoi(envvar) }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_RESULTS_DIR"))
+	if envvar != "" { s.ResultsDir = envvar }
+	envvar = strings.TrimSpace(os.Getenv("CHCO2_ORACLE"))
+	if envvar != "" { oracleName = envvar }
+	s.applyProfileConsensus()	// a network profile describes how the peers run, so it wins over the CORE_PBFT_* env vars
 
 
 	//---------------------------------------------------------------------------------------------------------------
@@ -215,9 +189,9 @@ func setup_part1(testName string, started time.Time) {
 
 	// validate N and related items
 
-	if Security {
-		if NumberOfPeersInNetwork < 4 {
-			fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(NumberOfPeersInNetwork) + ") provided for N when security is enabled; a secure network must contain a minimum of 4 peer nodes.")
+	if s.Security {
+		if s.NumberOfPeersInNetwork < 4 {
+			fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(s.NumberOfPeersInNetwork) + ") provided for N when security is enabled; a secure network must contain a minimum of 4 peer nodes.")
 			//fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(NumberOfPeersInNetwork) + ") PROVIDED FOR N !!!  When security is enabled, a network must contain a minimum of 4 peer nodes. Resetting N to 4")
 			//NumberOfPeersInNetwork = 4 // we could reset it, but then we wouldn't see how the fabric reacts...
 		}
@@ -227,22 +201,22 @@ func setup_part1(testName string, started time.Time) {
 	if envvar == "TRUE" {
 		// InvokesRequiredForCatchUp really should be (K * batchsize * logmultiplier) to ensure recovery.
 		// (Although sometimes, depending on timing and state transitions, I wonder if maybe even that is not enough???)
-		InvokesRequiredForCatchUp = (K * batchsize * logmultiplier)
+		s.InvokesRequiredForCatchUp = (s.K * s.batchsize * s.logmultiplier)
 	} else {
 		// Since it gets so big for larger networks and slows down our tests. (That is one reason why
 		// we set a default batchsize of 2 for these consensus tests.) And we often do not need it to be
 		// the maximum for tests to pass, so let's just try this, which seems to be enough in most cases:
-		InvokesRequiredForCatchUp = (NumberOfPeersInNetwork * 25)
+		s.InvokesRequiredForCatchUp = (s.NumberOfPeersInNetwork * 25)
 	}
 
 	// validate F and related items
 
 	// ensure we do not set F to a value exceeding (n-1)/3. And set other related vars.
 
-        MaxNumberOfPeersThatCanFailWhileStillHaveConsensus = (NumberOfPeersInNetwork - 1) / 3
+        s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus = (s.NumberOfValidatingPeers - 1) / 3
 
-	if (strings.ToUpper(ConsensusMode) == "PBFT") && (NumberOfPeersOkToFail > MaxNumberOfPeersThatCanFailWhileStillHaveConsensus) {
-		fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(NumberOfPeersOkToFail) + ") provided for F !!!  Maximum is (N-1)/3 = " + strconv.Itoa(MaxNumberOfPeersThatCanFailWhileStillHaveConsensus))
+	if (strings.ToUpper(s.ConsensusMode) == "PBFT") && (s.NumberOfPeersOkToFail > s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus) {
+		fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(s.NumberOfPeersOkToFail) + ") provided for F !!!  Maximum is (N-1)/3 = " + strconv.Itoa(s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus))
 		//fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(NumberOfPeersOkToFail) + ") PROVIDED FOR F !!!  CHANGING TO (N-1)/3 = " + strconv.Itoa(MaxNumberOfPeersThatCanFailWhileStillHaveConsensus))
 		//NumberOfPeersOkToFail = MaxNumberOfPeersThatCanFailWhileStillHaveConsensus // we could reset it, but then we wouldn't see how the fabric reacts...
 	}
@@ -254,68 +228,137 @@ func setup_part1(testName string, started time.Time) {
 	// user may desire 9/10 to be functional.)
 	// Another way to look at it: min value for "N" is 3F+1.
 
-	MinNumberOfPeersNeededForConsensus = NumberOfPeersInNetwork - MaxNumberOfPeersThatCanFailWhileStillHaveConsensus
-	NumberOfPeersNeededForConsensus = NumberOfPeersInNetwork - NumberOfPeersOkToFail
+	s.MinNumberOfPeersNeededForConsensus = s.NumberOfValidatingPeers - s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus
+	s.NumberOfPeersNeededForConsensus = s.NumberOfValidatingPeers - s.NumberOfPeersOkToFail
 
 	// validate batchsize
 
-	if batchsize < 1 {
-		fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(batchsize) + ") provided for batchsize !!!  CHANGING TO 1")
-		batchsize = 1
+	if s.batchsize < 1 {
+		fmt.Println("WARNING: INVALID VALUE (" + strconv.Itoa(s.batchsize) + ") provided for batchsize !!!  CHANGING TO 1")
+		s.batchsize = 1
 	}
 
-	if pauseInsteadOfStop { fmt.Println("All STOPS and STARTS will be executed with Docker PAUSE and UNPAUSE") }
+	if s.DisruptionMode == DisruptPause { fmt.Println("All STOPS and STARTS will be executed with Docker PAUSE and UNPAUSE") }
+	if s.DisruptionMode == DisruptKill { fmt.Println("All STOPS will be executed with Docker KILL (SIGKILL)") }
+	if s.DisruptionMode == DisruptKillWipe { fmt.Println("All STOPS will be executed with Docker KILL (SIGKILL), and the peer ledgers deleted") }
 
 	fmt.Println("INFO: setup_part1(): TransPerSecRate = ", TransPerSecRate)
 
+	s.oracle = s.Oracle
+	if s.oracle == nil {
+		var err error
+		if s.oracle, err = NewOracle(oracleName); err != nil {
+			fmt.Println("WARNING: " + err.Error() + "; using example02")
+			s.oracle, _ = NewOracle("example02")
+		}
+	}
+
 	//---------------------------------------------------------------------------------------------------------------
 	// create and initialize storage slices for queued transactions counters, now that we know size of "N"
 	//---------------------------------------------------------------------------------------------------------------
 
-	qData = make([]peerQData, NumberOfPeersInNetwork)
-	qtransPerPeerForCH = make([]int, NumberOfPeersInNetwork)
-	qtrans = 0
-	for i:= 0; i < NumberOfPeersInNetwork; i++ {
-		qtransPerPeerForCH[i] = 0
-		qData[i].resA = 0
-		qData[i].resB = 0
+	s.qData = make([][]string, s.NumberOfPeersInNetwork)
+	s.qtrans = 0
+	for i:= 0; i < s.NumberOfPeersInNetwork; i++ {
+		s.qData[i] = nil
 	}
+	s.chain = NewChainOracle(s.batchsize)
+
+
+	s.testMutex.Lock()
+	s.stepLog = nil
+	s.diagnoses = nil
+	s.diagnosedStep = ""
+	s.testMutex.Unlock()
+	s.TestResult = ""
+	s.beginTestReport(s.CurrentTestName, started)
+	s.beginTest(started)	// from now on, SIGINT and SIGTERM abort the test and restore the network
+	s.LogStep("BEGIN " + s.CurrentTestName)
+	myStr := fmt.Sprintf("\nBEGIN  %s (Enforce Q=%t CH=%t, MustMatch Q=%t CH=%t AllRunningNodes=%t) [STARTED: %s]", s.CurrentTestName, s.EnforceQueryTestsPass, s.EnforceChainHeightTestsPass, s.QsMustMatchExpected, s.CHsMustMatchExpected, s.AllRunningNodesMustMatch, started)
+	fmt.Println(myStr)
+	fmt.Fprintln(s.Writer, myStr)
+	s.Writer.Flush()
+}
 
+// Returns the profile of the network of the scenario: of its registered network, else the NETWORK_PROFILE file; nil if none.
+func (s *Scenario) networkProfile() *peernetwork.NetworkProfile {
+	if s.Network != "" { return peernetwork.ProfileOf(peernetwork.PeerNetwork{Name: s.Network}) }
+	profilePath := strings.TrimSpace(os.Getenv("NETWORK_PROFILE"))
+	if profilePath == "" { return nil }
+	profile, err := peernetwork.LoadNetworkProfile(profilePath)
+	if err != nil { fmt.Println("WARNING: could not load the network profile for its consensus settings: " + err.Error()); return nil }
+	return profile
+}
 
-	myStr := fmt.Sprintf("\nBEGIN  %s (Enforce Q=%t CH=%t, MustMatch Q=%t CH=%t AllRunningNodes=%t) [STARTED: %s]", CurrentTestName, EnforceQueryTestsPass, EnforceChainHeightTestsPass, QsMustMatchExpected, CHsMustMatchExpected, AllRunningNodesMustMatch, started)
-	fmt.Println(myStr)
-	fmt.Fprintln(Writer, myStr)
-	Writer.Flush()
+// Takes the consensus settings (N, F, plugin, mode, batch size and timeout, K, logmultiplier) that the network profile gives.
+func (s *Scenario) applyProfileConsensus() {
+	profile := s.networkProfile()
+	if profile == nil { return }
+	c := profile.Consensus
+	if c.N > 0 { s.NumberOfPeersInNetwork = c.N; s.NumberOfValidatingPeers = c.N }
+	if c.F > 0 { s.NumberOfPeersOkToFail = c.F }
+	if c.Plugin != "" { s.ConsensusMode = c.Plugin }
+	if c.Mode != "" { s.PbftMode = strings.ToUpper(c.Mode) }
+	if c.BatchSize > 0 { s.batchsize = c.BatchSize }
+	if c.BatchTimeout != "" {
+		if timeout, err := time.ParseDuration(c.BatchTimeout); err == nil {
+			s.batchTimeout = c.BatchTimeout
+			s.batchtimeout = int((timeout + time.Second - 1) / time.Second)	// whole seconds, rounded up
+		}
+	}
+	if c.K > 0 { s.K = c.K }
+	if c.LogMultiplier > 0 { s.logmultiplier = c.LogMultiplier }
+	fmt.Println("INFO: consensus settings from network profile " + profile.Name + ": N=" + strconv.Itoa(s.NumberOfPeersInNetwork) + " F=" + strconv.Itoa(s.NumberOfPeersOkToFail) + " " + s.ConsensusMode + "/" + s.PbftMode + " batchsize=" + strconv.Itoa(s.batchsize) + " batchTimeout=" + s.batchTimeout)
 }
 
-func setup_part2_network() {
-    if strings.ToUpper(os.Getenv("CHCO2_EXISTING_NETWORK")) == "TRUE" {
+func (s *Scenario) setup_part2_network() {
+    if s.Network != "" {
+	fmt.Println("chco2.setup_part2_network(): the scenario uses the registered network " + s.Network + "; we will NOT create a new network.")
+    } else if strings.ToUpper(os.Getenv("CHCO2_EXISTING_NETWORK")) == "TRUE" {
 	fmt.Println("chco2.setup_part2_network(): CHCO2_EXISTING_NETWORK is TRUE, which means:\n (1) we will NOT create a new network, and\n (2) we will IGNORE the COMMIT image and a few other env vars, and\n (3) we will use the existing Network as previously created.")
     } else {
-	fmt.Println("Creating a local docker network with # peers = ", NumberOfPeersInNetwork)
+	fmt.Println("Creating a local docker network with # peers = ", s.NumberOfPeersInNetwork)
 	peernetwork.SetupLocalNetworkWithMoreOptions(
-		NumberOfPeersInNetwork,	//  CORE_PBFT_GENERAL_N
-		NumberOfPeersOkToFail,	//  CORE_PBFT_GENERAL_F
-		LoggingLevel,		//  CORE_LOGGING_LEVEL
-		Security,		//  CORE_SECURITY_ENABLED
-		ConsensusMode,		//  CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN
+		s.NumberOfPeersInNetwork,	//  CORE_PBFT_GENERAL_N
+		s.NumberOfPeersOkToFail,	//  CORE_PBFT_GENERAL_F
+		s.LoggingLevel,		//  CORE_LOGGING_LEVEL
+		s.Security,		//  CORE_SECURITY_ENABLED
+		s.ConsensusMode,		//  CORE_PEER_VALIDATOR_CONSENSUS_PLUGIN
         	//PbftMode,		//  CORE_PBFT_GENERAL_MODE
 		//batchTimeout,		//  CORE_PBFT_GENERAL_TIMEOUT_BATCH
-		batchsize )		//  CORE_PBFT_GENERAL_BATCHSIZE
+		s.batchsize )		//  CORE_PBFT_GENERAL_BATCHSIZE
 
-	if (Verbose) { fmt.Println("Sleep 10 secs extra after setup_part2 created network") }; time.Sleep(10000 * time.Millisecond)
+	// no extra sleep here; setup_part3 waits until the peers answer /chain
     }
 }
 
-func setup_part3_verifyNetworkAndDeployCC() {
+func (s *Scenario) setup_part3_verifyNetworkAndDeployCC() {
 
-	peernetwork.PrintNetworkDetails()
-	MyNetwork = chaincode.InitNetwork()
-	chaincode.InitChainCodes()
-	chaincode.RegisterUsers()
+	if s.Network != "" {
+		thisNetwork, err := peernetwork.LoadNetworkByName(s.Network)
+		if err != nil { log.Fatal("chco2.Setup(): " + err.Error()) }
+		s.MyNetwork = thisNetwork
+		s.cc = chaincode.NewClient(s.MyNetwork, peernetwork.InitializeChainCodes())
+	} else {
+		peernetwork.PrintNetworkDetails()
+		s.MyNetwork = chaincode.InitNetwork()
+		chaincode.InitChainCodes()
+		s.cc = chaincode.NewClient(s.MyNetwork, chaincode.LibCC)	// the package functions work on this network too
+	}
+	s.controller = s.nodeController()
+	s.waitForRunningPeersReady(SleepTimeSeconds(60))
+	if s.healthMonitoring && s.healthInterval > 0 {
+		s.healthMonitor = peernetwork.StartHealthMonitor(s.MyNetwork, SleepTimeSeconds(s.healthInterval))
+	}
+	s.cc.RegisterUsers()
+	if s.Network == "" && strings.ToUpper(os.Getenv("CHCO2_EXISTING_NETWORK")) != "TRUE" {
+		for i := 0; i < s.NumberOfNVPs; i++ {
+			if s.AddPeer(peernetwork.RoleNVP, "") < 0 { fmt.Println("Setup() ERROR: Cannot add NVP " + strconv.Itoa(i)) }
+		}
+	}
 
 	// get any avail node URL details to get info on chainstats/transactions/blocks etc.
-	aPeer, _ := peernetwork.APeer(chaincode.ThisNetwork)
+	aPeer, _ := peernetwork.APeer(s.MyNetwork)
 	url := chaincode.GetURL(aPeer.PeerDetails["ip"], aPeer.PeerDetails["port"])
 
 	chaincode.NetworkPeers(url)
@@ -325,39 +368,39 @@ func setup_part3_verifyNetworkAndDeployCC() {
 	//chaincode.User_Registration_Status(url, "nishi")
 	//chaincode.User_Registration_ecertDetail(url, "lukas")
 
-	initA = "1000000"		// start with ONE MILLION
-	initB = "1000000"		// start with ONE MILLION
-	currA, _ = strconv.Atoi(initA)
-	currB, _ = strconv.Atoi(initB)
-	currCH = 1			// one for genesis block
+	s.oracle.Reset()		// example02 starts with ONE MILLION in A and B
+	s.currCH = 1			// one for genesis block
 
 	// find highest numbered running peer; deploy; and send one invoke request to each peer
-        peerNum := NumberOfPeersInNetwork-1
-        for ; peerNum >= 0; peerNum-- { if peerIsRunning(peerNum,MyNetwork) { break } }
+        peerNum := s.NumberOfPeersInNetwork-1
+        for ; peerNum >= 0; peerNum-- { if peerIsRunning(peerNum,s.MyNetwork) { break } }
 	if peerNum < 0 {
 		fmt.Println("Setup() ERROR: Cannot find any running peer for Deploy!!!!!!!!!!")
 	} else {
-		if Verbose { fmt.Println("setup_part3_verify, before deploy: A/B/chainheight values: ", currA, currB, currCH) }
+		if s.Verbose { fmt.Println("setup_part3_verify, before deploy: " + strings.Join(s.oracle.Keys(0), "/") + "/chainheight values: ", s.oracle.Expected(0), s.currCH) }
 
-		DeployInit(peerNum)
+		s.DeployInit(peerNum)
 
 		if strings.ToUpper(os.Getenv("CHCO2_EXISTING_NETWORK")) == "TRUE" {
 			// STANDARD (RE)DEPLOYMENT OVERRIDE:
 			// Useful for rerunning this testcase and others, to use the existing network with existing deployment
 			// (by using same standard numbers), rather than creating yet another deployment for the existing peers...
 			// but that means the values for A and B will not be reset to 1 million, because they already exist with
-			// other values, so we need to go find the actual CURRENT values for A & B
+			// other values, so we need to go find the actual CURRENT values of the oracle keys
 
-			success := QueryAllHostsToGetCurrentValues(MyNetwork, &currA, &currB, &currCH)
-			if !success {
+			values, ch, success := s.queryAllHosts(s.MyNetwork, s.oracle)
+			if success {
+				s.oracle.Adopt(values)
+				s.currCH = ch
+			} else {
 				fmt.Println("setup_part3_verify: CANNOT find consensus in existing network; chainheight/A/B invoke and query tests will likely fail to match expected values!!!")
 				// panic(errors.New("setup_part3_verify: CANNOT find consensus in existing network"))
 			}
 		}
-		if Verbose { fmt.Println("setup_part3_verify, AFTER deploy,QueryAllHosts: A/B/chainheight values: ", currA, currB, currCH) }
+		if s.Verbose { fmt.Println("setup_part3_verify, AFTER deploy,QueryAllHosts: " + strings.Join(s.oracle.Keys(0), "/") + "/chainheight values: ", s.oracle.Expected(0), s.currCH) }
 
-		InvokeOnEachPeer(1)
-		QueryAllPeers("STEP SETUP, after initial Deployment followed by 1 Invoke on each peer")
+		s.InvokeOnEachPeer(1)
+		s.QueryAllPeers("STEP SETUP, after initial Deployment followed by 1 Invoke on each peer")
 	}
 }
 
@@ -365,107 +408,116 @@ func setup_part3_verifyNetworkAndDeployCC() {
 // This func can be used by chco2 funcs as well as external funcs such as chcotest/BasicFuncExistingNetwork.go
 // that have their own network (and which did not call chco2 setup/init functions)
 
-func QueryAllHostsToGetCurrentValues(mynetwork peernetwork.PeerNetwork, a *int, b *int, ch *int) bool {		// using example02
+func (s *Scenario) QueryAllHostsToGetCurrentValues(mynetwork peernetwork.PeerNetwork, a *int, b *int, ch *int) bool {		// using example02
+	if !s.beginStep("QueryAllHostsToGetCurrentValues") { return false }
+	defer s.endStep()
+	values, height, found_consensus := s.queryAllHosts(mynetwork, NewExample02Oracle(0, 0))
+	if found_consensus {
+		*a, _ = strconv.Atoi(values[0])
+		*b, _ = strconv.Atoi(values[1])
+		*ch = height
+	}
+	if s.Verbose { fmt.Println("QueryAllHosts() returning: A, B, CH, found_consensus? : ", *a, *b, *ch, found_consensus) }
+	return found_consensus
+}
+
+// Returns the values of the oracle keys and the chain height that enough peers agree on for consensus.
+func (s *Scenario) queryAllHosts(mynetwork peernetwork.PeerNetwork, oracle Oracle) (values []string, ch int, found_consensus bool) {
 
-	found_consensus := false
 	N := peernetwork.GetNumberOfPeers(mynetwork)	// CORE_PBFT_GENERAL_N ; in chco2, this is same as NumberOfPeersInNetwork
 	F := (N - 1) / 3				// Max value for F (if CORE_PBFT_GENERAL_F is actually set lower than F and
 							//   if the current number of available peers is somewhere in between, then
 							//   we may be OK here but the testcase could conceivably still fail later)
-	var queryData []peerQData			// queried values for A and B for each peer
-	queryData = make([]peerQData, N)		// queried values for block chain height for each peer
+	var queryData [][]string			// queried values of the oracle keys for each peer
+	queryData = make([][]string, N)
 	var ht []int
-	ht = make([]int, N)
+	ht = make([]int, N)				// queried values for block chain height for each peer
 	runningPeerCounter := 0
-        qArgsa := []string{"a"}
-        qArgsb := []string{"b"}
-	qAPIArgs := []string{"example02", "query", threadutil.GetPeer(0)}
+	keys := oracle.Keys(0)
 
 	// loop through and query all hosts to determine the current values
 	for n:=0; n < N; n++ {
-		queryData[n].resA = 0
-		queryData[n].resB = 0
 		ht[n] = 0
 		if peerIsRunning(n,mynetwork) {
 			runningPeerCounter++
-			ht[n], _ = chaincode.GetChainHeight(threadutil.GetPeer(n))
-			qAPIArgs = []string{ "example02", "query", threadutil.GetPeer(n) }
-			chco2_QueryOnHost(qAPIArgs, qArgsa, qArgsb, &queryData[n].resA, &queryData[n].resB)
+			ht[n], _ = s.chainHeight(n)
+			queryData[n] = s.queryPeer(oracle.Chaincode(), threadutil.GetPeer(n), keys)
 		}
-        	if Verbose { fmt.Println(fmt.Sprintf("QueryAllHosts() found on Peer %d :  A=%d, B=%d, CH=%d", n, queryData[n].resA, queryData[n].resB, ht[n])) }
+        	if s.Verbose { fmt.Println(fmt.Sprintf("QueryAllHosts() found on Peer %d :  %s=%s, CH=%d", n, strings.Join(keys, "/"), strings.Join(queryData[n], "/"), ht[n])) }
 	}
 
 	// loop through to determine if we have consensus, and obtain the consensus values
 	if runningPeerCounter > 2*F {
 		// there are enough peers running to have a chance to find consensus
 		for i := 0 ; i <= F ; i++ {
+			if queryData[i] == nil { continue }
 			candidate_cntr := 1
-			candidate_A := queryData[i].resA
-			candidate_B := queryData[i].resB
 			candidate_CH := ht[i]
 			for j := i+1 ; j < N ; j++ {
-				if queryData[j].resA == candidate_A && queryData[j].resB == candidate_B && ht[j] == candidate_CH {
+				if queryData[j] != nil && oracle.Matches(queryData[i], queryData[j]) && ht[j] == candidate_CH {
 					candidate_cntr++
-					if Verbose { fmt.Println("QueryAllHosts(): values match on peers ", i, j) }
+					if s.Verbose { fmt.Println("QueryAllHosts(): values match on peers ", i, j) }
 				}
 			}
-			if candidate_cntr >= 2*F+1 { *a = candidate_A; *b = candidate_B; *ch = candidate_CH; found_consensus = true; break }
+			if candidate_cntr >= 2*F+1 { values = queryData[i]; ch = candidate_CH; found_consensus = true; break }
 		}
 	} else { fmt.Println("QueryAllHosts(): NOT ENOUGH RUNNING Peers TO FIND CONSENSUS! #running/#total = ", runningPeerCounter, N) }
 
 	if !found_consensus { fmt.Println("QueryAllHosts(): CANNOT FIND CONSENSUS!") }
 
-	if Verbose { fmt.Println("QueryAllHosts() returning: A, B, CH, found_consensus? : ", *a, *b, *ch, found_consensus) }
-
-	return found_consensus
+	return values, ch, found_consensus
 }
 
-func TestsCurrentlyPass() bool {
+func (s *Scenario) TestsCurrentlyPass() bool {
 	rc := true
 	//QueryAllPeers("STEP CHECK Tests Status")
-	if (EnforceQueryTestsPass && !queryTestsPass) || (EnforceChainHeightTestsPass && !chainHeightTestsPass) {
-		queryTestsPass = true 
-		chainHeightTestsPass = true
+	if (s.EnforceQueryTestsPass && !s.queryTestsPass) || (s.EnforceChainHeightTestsPass && !s.chainHeightTestsPass) {
+		s.queryTestsPass = true 
+		s.chainHeightTestsPass = true
 		//fmt.Println("RecheckCurrentStatus: Tests still not passing.")
-		QueryAllPeers("STEP RECHECK Tests Status")
+		s.QueryAllPeers("STEP RECHECK Tests Status")
 	}
-	if (EnforceQueryTestsPass && !queryTestsPass) || (EnforceChainHeightTestsPass && !chainHeightTestsPass) { rc = false }
+	if (s.EnforceQueryTestsPass && !s.queryTestsPass) || (s.EnforceChainHeightTestsPass && !s.chainHeightTestsPass) { rc = false }
 	return rc
 }
 
-func WaitAndConfirm(sleepExtra int) {
-	if (EnforceQueryTestsPass && !queryTestsPass) || (EnforceChainHeightTestsPass && !chainHeightTestsPass) {
-		queryTestsPass = true 
-		chainHeightTestsPass = true
-		fmt.Println("WaitAndConfirm: Tests still not passing. Sleep extra (" + strconv.Itoa((int)(sleepExtra)) + " secs) and check again...")
-		time.Sleep(SleepTimeSeconds(sleepExtra))
-		QueryAllPeers("STEP to WAIT EXTRA TIME and CHECK AGAIN to see if all nodes catch up.")
+func (s *Scenario) WaitAndConfirm(sleepExtra int) {
+	if !s.beginStep("WaitAndConfirm") { return }
+	defer s.endStep()
+	if (s.EnforceQueryTestsPass && !s.queryTestsPass) || (s.EnforceChainHeightTestsPass && !s.chainHeightTestsPass) {
+		s.queryTestsPass = true 
+		s.chainHeightTestsPass = true
+		fmt.Println("WaitAndConfirm: Tests still not passing. Wait up to " + strconv.Itoa((int)(sleepExtra)) + " secs for the peers to agree, and check again...")
+		s.waitForAgreement(SleepTimeSeconds(sleepExtra))	// rather than sleeping all of it
+		s.QueryAllPeers("STEP to WAIT EXTRA TIME and CHECK AGAIN to see if all nodes catch up.")
 	}
 }
 
-func CatchUpAndConfirm() {
+func (s *Scenario) CatchUpAndConfirm() {
+	if !s.beginStep("CatchUpAndConfirm") { return }
+	defer s.endStep()
 	// Calling this is optional. If you just care about a "current status", to see if
 	// everything eventually catches up and synchronizes, then call this method;
 	// it will send enough invokes to ensure all active nodes catch up, and then
-	// sleep a long time, and finally query all active nodes to confirm.
+	// wait (a long time at most) until they agree, and finally query all active nodes to confirm.
+	// To assert that they converge, and learn how long it takes, see the Eventually assertions.
 
-	if enoughPeersRunningForConsensus() {
+	if s.enoughPeersRunningForConsensus() {
 
-	    if (EnforceQueryTestsPass && !queryTestsPass) || (EnforceChainHeightTestsPass && !chainHeightTestsPass) {
+	    if (s.EnforceQueryTestsPass && !s.queryTestsPass) || (s.EnforceChainHeightTestsPass && !s.chainHeightTestsPass) {
 
-		if (Verbose) { fmt.Println("\nCATCH UP AND CONFIRM RESULTS: Something failed along the way; send enough Invokes for peers to catch up, and sleep awhile, and then recheck.") }
+		if (s.Verbose) { fmt.Println("\nCATCH UP AND CONFIRM RESULTS: Something failed along the way; send enough Invokes for peers to catch up, and sleep awhile, and then recheck.") }
 
 		// reset TestsPass booleans: all we care about is the final query, i.e. if all peers have caught up
 		// finally and we pass tests here, then we probably can ignore all the preliminary test failures
 		// and consider those failures as misunderstood behavior expectations - maybe they were
 		// stale values, observable due to delay/timing issues (test expectation errors)
 
-		queryTestsPass = true 
-		chainHeightTestsPass = true
+		s.queryTestsPass = true 
+		s.chainHeightTestsPass = true
 
-		// Do not set the CHsMustMatchExpected boolean to true. If they are not already set (indicating a tested scenario),
-		// then we cannot guarantee our code logic and the consensus network will come up with the same answers.
-		// // CHsMustMatchExpected = true
+		// CHsMustMatchExpected is true by default: the chain oracle knows which transactions must be in the chains.
+		// Testcases that set it false (for known issues) keep it so.
 		// 
 		// QsMustMatchExpected should already be true (unless specific testcases disable it for
 		// their own reasons, to workaround known issues).
@@ -482,36 +534,44 @@ func CatchUpAndConfirm() {
 		// will catch up when needed (when a peer stops, leaving only 2f peers running)
 		// after another set of invokes totalling 
 
-		numInvokes := InvokesRequiredForCatchUp		// send enough invokes to ensure queues emptied
+		numInvokes := s.InvokesRequiredForCatchUp		// send enough invokes to ensure queues emptied
 
-		Invokes( numInvokes )
+		s.Invokes( numInvokes )
 
-		// sleep again, to allow double the expected processing time, to help ensure all transactions are processed
-		if (Verbose) { fmt.Println("Sleep extra time...") }
-		time.Sleep(sleepTimeForTrans(numInvokes))
+		// wait again, up to double the expected processing time, to help ensure all transactions are processed;
+		// stop waiting as soon as the peers agree
+		if (s.Verbose) { fmt.Println("Wait extra time...") }
+		s.waitForAgreement(s.sleepTimeForTrans(numInvokes))
 
-		QueryAllPeers("STEP to CATCH UP AND CONFIRM RESULTS after extra invokes and sleep")
+		s.QueryAllPeers("STEP to CATCH UP AND CONFIRM RESULTS after extra invokes and sleep")
 
 		// if still not passing, wait again extra time, with NO more invokes, and recheck.
-		WaitAndConfirm( ((numInvokes * 3) / TransPerSecRate) + batchtimeout )
+		s.WaitAndConfirm( ((numInvokes * 3) / TransPerSecRate) + s.batchtimeout )
 
 	    } else { fmt.Println("CatchUpAndConfirm: All enforced test types already passed. Hooray!") }
 
-	} else { if (Verbose) { fmt.Println("CatchUpAndConfirm: CANNOT try, because not enough peers for consensus are running") } }
+	} else { if (s.Verbose) { fmt.Println("CatchUpAndConfirm: CANNOT try, because not enough peers for consensus are running") } }
 }
 
-func DeployNew(a int, b int) {
-	peer := NumberOfPeersInNetwork-1	// default is to use the last node in the network (this is how the chaincode.Deploy code works when it chooses any peer)
-	DeployNewOnPeer(a, b, peer)
+func (s *Scenario) DeployNew(a int, b int) {
+	peer := s.NumberOfPeersInNetwork-1	// default is to use the last node in the network (this is how the chaincode.Deploy code works when it chooses any peer)
+	s.DeployNewOnPeer(a, b, peer)
 }
 
-func DeployNewOnPeer(a int, b int, peer int) {
-	if peer < 0 || peer >= NumberOfPeersInNetwork {
-		panic(errors.New("DeployNew : Invalid value for peer (" + strconv.Itoa(peer) + "). Expecting 0.." + strconv.Itoa(NumberOfPeersInNetwork-1)))
+func (s *Scenario) DeployNewOnPeer(a int, b int, peer int) {
+	if !s.beginStep("DeployNewOnPeer") { return }
+	defer s.endStep()
+	if peer < 0 || peer >= s.NumberOfPeersInNetwork {
+		panic(errors.New("DeployNew : Invalid value for peer (" + strconv.Itoa(peer) + "). Expecting 0.." + strconv.Itoa(s.NumberOfPeersInNetwork-1)))
+	}
+	o, ok := s.oracle.(*Example02Oracle)
+	if !ok {
+		panic(errors.New("DeployNew : the oracle of chaincode " + s.oracle.Chaincode() + " has no values A and B to deploy; use DeployInit"))
 	}
 	strA := strconv.Itoa(a)
 	strB := strconv.Itoa(b)
-	if initA == strA && initB == strB {
+	s.LogStep("DEPLOY on peer " + threadutil.GetPeer(peer) + ", A=" + strA + " B=" + strB)
+	if o.InitA == a && o.InitB == b {
 		fmt.Println("\nPOST/Chaincode: NEW DEPLOY, on peer " + threadutil.GetPeer(peer) + ", using SAME INIT VALUES (and therefore no new chaincode instance, so this will be ignored), A=" + strA + " B=" + strB)
 		// same values for A and B ==>
 		// the request will be mapped to same hash ==>
@@ -522,30 +582,39 @@ func DeployNewOnPeer(a int, b int, peer int) {
 		// A new chaincode instance (and hash) will be created on each peer node, for this new deployed network.
 		// Our GO SDK will be using the new values from now on, so set our internal values accordingly.
 		// (To access the old one too, refer to usage of deployUsingTagName())
-		currA = a
-		currB = b
-		initA = strA
-		initB = strB
+		o.InitA = a
+		o.InitB = b
+		o.Reset()
 	}
-	DeployInit(peer)
+	s.DeployInit(peer)
 }
 
-func DeployInit(peerNum int) {
+func (s *Scenario) DeployInit(peerNum int) {
+	if !s.beginStep("DeployInit") { return }
+	defer s.endStep()
 	peerStr := threadutil.GetPeer(peerNum)
-	fmt.Println("\nPOST/Chaincode: DEPLOY chaincode on peer " + peerStr + ", A=" + initA + " B=" + initB)
-	dAPIArgs := []string{"example02", "init", peerStr}
-	depArgs := []string{"a", initA, "b", initB}
-	txId, err := chaincode.DeployOnPeer(dAPIArgs, depArgs)
+	dAPIArgs := []string{s.oracle.Chaincode(), "init", peerStr}
+	depArgs := s.oracle.DeployArgs()
+	fmt.Println("\nPOST/Chaincode: DEPLOY chaincode " + s.oracle.Chaincode() + " on peer " + peerStr + ", args: " + strings.Join(depArgs, " "))
+	txId, err := s.client().DeployOnPeer(dAPIArgs, depArgs)
 	Check(err) 	// if we cannot deploy, then panic
-	if (Verbose) { fmt.Println("Sleep 30 secs, after deployed, txId=" + txId) }
-	time.Sleep(30000 * time.Millisecond)
-	incrHeightCount(1, peerNum)
-	setQueuedTransactionCounter(1)
+	s.chain.Submitted(txId, true)
+	if (s.Verbose) { fmt.Println("Wait until the chaincode answers queries on " + peerStr + ", after deployed, txId=" + txId) }
+	keys := s.oracle.Keys(0)
+	waited, err := peernetwork.WaitUntil("chaincode " + s.oracle.Chaincode() + " to answer queries on " + peerStr, DeployTimeout, func() bool {
+		for _, value := range s.queryPeer(s.oracle.Chaincode(), peerStr, keys) { if value == "" { return false } }
+		return true
+	})
+	Check(err) 	// a chaincode that never answers was not deployed
+	fmt.Println("DeployInit(): chaincode " + s.oracle.Chaincode() + " answers queries on " + peerStr + " after " + waited.String())
+	s.setQueuedTransactionCounter(1)
 }
 
-func Invokes(totalNumInvokes int) {
+func (s *Scenario) Invokes(totalNumInvokes int) {
+	if !s.beginStep("Invokes") { return }
+	defer s.endStep()
 	// count the num running peers, and determine numInvokes to send to each peer
-	numPeersRunning := getNumberOfPeersRunning()
+	numPeersRunning := s.getNumberOfPeersRunning()
 	if (numPeersRunning == 0) {
         	fmt.Println("Invokes: ERROR: CANNOT send INVOKEs : no peers running!")
 		return
@@ -555,57 +624,57 @@ func Invokes(totalNumInvokes int) {
 		return
 	}
         fmt.Println("\nPOST/Chaincode: INVOKEs total (" + strconv.Itoa(totalNumInvokes) + ") divided among all " + strconv.Itoa(numPeersRunning) + " running peers")
+	s.LogStep("INVOKEs total (" + strconv.Itoa(totalNumInvokes) + ") divided among all " + strconv.Itoa(numPeersRunning) + " running peers")
 	numInvokesPerPeer := totalNumInvokes / numPeersRunning
 	extras := totalNumInvokes % numPeersRunning
 	runningPeerCounter := 0
 	firstOne := true
-        for peerNum := 0; runningPeerCounter < numPeersRunning && peerNum < NumberOfPeersInNetwork; peerNum++ {
-        	if peerIsRunning(peerNum,MyNetwork) {
+        for peerNum := 0; runningPeerCounter < numPeersRunning && peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+        	if peerIsRunning(peerNum,s.MyNetwork) {
 			runningPeerCounter++
 			if firstOne {
 				firstOne = false
-				doInvoke(&currA, &currB, numInvokesPerPeer + extras, threadutil.GetPeer(peerNum))
-				incrHeightCount(numInvokesPerPeer + extras, peerNum)
+				s.doInvoke(numInvokesPerPeer + extras, threadutil.GetPeer(peerNum))
 				if numInvokesPerPeer == 0 { break }
 			} else {
-				doInvoke(&currA, &currB, numInvokesPerPeer, threadutil.GetPeer(peerNum))
-				incrHeightCount(numInvokesPerPeer, peerNum)
+				s.doInvoke(numInvokesPerPeer, threadutil.GetPeer(peerNum))
 			}
 		}
 	}
 
 	if (runningPeerCounter > 0) {
-        	setQueuedTransactionCounter(totalNumInvokes)
+        	s.setQueuedTransactionCounter(totalNumInvokes)
 	} else {
 		fmt.Println("Invokes: ERROR: CANNOT send INVOKEs; runningPeerCounter = " + strconv.Itoa(runningPeerCounter))
 	}
 }
 
-func InvokeOnEachPeer(numInvokesPerPeer int) {
+func (s *Scenario) InvokeOnEachPeer(numInvokesPerPeer int) {
+	if !s.beginStep("InvokeOnEachPeer") { return }
+	defer s.endStep()
 	runningPeerCounter := 0
         fmt.Println("\nPOST/Chaincode: INVOKEs (" + strconv.Itoa(numInvokesPerPeer) + ") being sent to each running peer")
-        for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        	if peerIsRunning(peerNum,MyNetwork) {
-			doInvoke(&currA, &currB, numInvokesPerPeer, threadutil.GetPeer(peerNum))
-			incrHeightCount(numInvokesPerPeer, peerNum)
+	s.LogStep("INVOKEs (" + strconv.Itoa(numInvokesPerPeer) + ") being sent to each running peer")
+        for peerNum := 0; peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+        	if peerIsRunning(peerNum,s.MyNetwork) {
+			s.doInvoke(numInvokesPerPeer, threadutil.GetPeer(peerNum))
 			runningPeerCounter++
 		}
 	}
 	if (runningPeerCounter > 0) {
-		setQueuedTransactionCounter(runningPeerCounter * numInvokesPerPeer)
+		s.setQueuedTransactionCounter(runningPeerCounter * numInvokesPerPeer)
 	} else {
 		fmt.Println("InvokeOnEachPeer: WARNING: CANNOT send INVOKEs; no peers are running!")
 	}
 }
 
-func invokeOnAnyPeer(totalNumInvokes int) {
+func (s *Scenario) invokeOnAnyPeer(totalNumInvokes int) {
         fmt.Println("\nPOST/Chaincode: INVOKEs (%d) using first available peer", strconv.Itoa(totalNumInvokes))
 	sent := false
-        for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        	if peerIsRunning(peerNum,MyNetwork) {
-			doInvoke(&currA, &currB, totalNumInvokes, threadutil.GetPeer(peerNum))
-			incrHeightCount(totalNumInvokes, 0)
-        		setQueuedTransactionCounter(totalNumInvokes)
+        for peerNum := 0; peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+        	if peerIsRunning(peerNum,s.MyNetwork) {
+			s.doInvoke(totalNumInvokes, threadutil.GetPeer(peerNum))
+        		s.setQueuedTransactionCounter(totalNumInvokes)
 			sent = true
 			break
 		}
@@ -613,107 +682,46 @@ func invokeOnAnyPeer(totalNumInvokes int) {
 	if !sent { fmt.Println("invokeOnAnyPeer: WARNING: CANNOT send INVOKEs; no peers are running!") }
 }
 
-func InvokesUniqueOnEveryPeer() {
+func (s *Scenario) InvokesUniqueOnEveryPeer() {
 	powerOf2 := 1
-	for i := 0 ; i < NumberOfPeersInNetwork ; i++ {
-       		if peerIsRunning(i,MyNetwork) { InvokeOnThisPeer( powerOf2, i ) }
+	for i := 0 ; i < s.NumberOfPeersInNetwork ; i++ {
+       		if peerIsRunning(i,s.MyNetwork) { s.InvokeOnThisPeer( powerOf2, i ) }
 		powerOf2 = powerOf2 * 2
 	}
 }
 
-func InvokeOnThisPeer(totalNumInvokes int, peerNum int) {
+func (s *Scenario) InvokeOnThisPeer(totalNumInvokes int, peerNum int) {
+	if !s.beginStep("InvokeOnThisPeer") { return }
+	defer s.endStep()
         fmt.Println("\nPOST/Chaincode: INVOKEs (" + strconv.Itoa(totalNumInvokes) + ") using peer " + strconv.Itoa(peerNum))
-       	if peerIsRunning(peerNum,MyNetwork) {
-		doInvoke(&currA, &currB, totalNumInvokes, threadutil.GetPeer(peerNum))
-		incrHeightCount(totalNumInvokes, 0)
-        	setQueuedTransactionCounter(totalNumInvokes)
+	s.LogStep("INVOKEs (" + strconv.Itoa(totalNumInvokes) + ") using peer " + strconv.Itoa(peerNum))
+       	if peerIsRunning(peerNum,s.MyNetwork) {
+		s.doInvoke(totalNumInvokes, threadutil.GetPeer(peerNum))
+        	s.setQueuedTransactionCounter(totalNumInvokes)
 	} else {
-		if Verbose { fmt.Println("InvokeOnThisPeer: ERROR: CANNOT send INVOKEs; peer " + strconv.Itoa(peerNum) + " is not running!") }
+		if s.Verbose { fmt.Println("InvokeOnThisPeer: ERROR: CANNOT send INVOKEs; peer " + strconv.Itoa(peerNum) + " is not running!") }
 	}
 }
 
-func incrHeightCount(numInvokesOnThisPeer int, thisPeerNum int) {
-
-	// PREcondition: The associated peer should be RUNNING, otherwise we won't be called (and
-	// we shouldn't be queueing up any transactions since the peer isn't there to receive them and queue them).
-
-        if !peerIsRunning(thisPeerNum,MyNetwork) { return }
-
-	// Our current height count actually represents the actual height count.
-	// We have NOT already incremented our height counter for these transactions; we will do that now
-	// IF we are running WITH consensus working - otherwise queue the transactions and defer the job
-	// of computing and incrementing the heightcount until later when we empty the queue when Consensus resumes.
-	// Depending on the number of transactions now, versus those in the queue later, they could be
-	// bundled/batched differently later than they would be now, so we cannot calulate reliably now.
-
-	if enoughPeersRunningForConsensus() {
-		countChainBlocks(numInvokesOnThisPeer)
-	} else {
-		qtransPerPeerForCH[thisPeerNum] += numInvokesOnThisPeer
-	}
-}
-
-func countChainBlocks(numInvokesOnThisPeer int) {
-        // NOTE: to be called ONLY from incrHeightCount
-        // PRECONDITION: THIS peer (which received these invokes) is running, and consensus is working.
-
-        newBlocks := 0
-        queuedBlocks := 0
-
-        // Calculate the number of blocks that we will need for all the given "new" transactions
-        newBlocks += numInvokesOnThisPeer / batchsize   //  = # full batches
-        if (numInvokesOnThisPeer % batchsize > 0) {     //  = Extra transactions are sent in one batch when
-                newBlocks += 1                          //    bundle/batchtimeout expires (default every 2 secs)
-        }
-
-        // Since consensus is working, there is no need for any queued transactions on any running peer.
-        // If this is the handling of the first transactions since Consensus resumed, and/or
-        // since a peer node rejoined the network, then we need to count all the queued CH transactions of running nodes.
-        // (This may not be precisely correct in all crazy scenarios, but should serve most cases.)
-
-// ### NOTE: this code may be simplified and this LOOP would not be needed if we call this function incrHeightCount for every running peer already...
-
-        peerNum := 0
-        for peerNum < NumberOfPeersInNetwork {
-                if peerIsRunning(peerNum,MyNetwork) {
-                        if (qtransPerPeerForCH[peerNum] > 0) {
-                                // Calculate the number of chain blocks needed for all these transactions
-                                // on this peer's queue. Since the queue was not empty, the peer probably
-                                // recently rejoined the network (or the consensus network itself resumed
-                                // operation). This means it would have recently bundled up (or "batched")
-                                // all the queued transactions, so let's count them now.
-
-                                queuedBlocks += qtransPerPeerForCH[peerNum] / batchsize
-                                if (qtransPerPeerForCH[peerNum] % batchsize) > 0 {
-                                        queuedBlocks += 1
-                                }
-                                qtransPerPeerForCH[peerNum] = 0         // and clear the counter
-                        }
-                }
-                peerNum++
-        }
-        if (Verbose) { fmt.Println("Increment current ChainHeightBlockCount (" + strconv.Itoa(currCH) + "): + newBlocks(" + strconv.Itoa(newBlocks) + ") + queuedBlocks(" + strconv.Itoa(queuedBlocks) + ")" ) }
-        currCH += newBlocks + queuedBlocks
-}
-
-func setQueuedTransactionCounter(numTrans int) {
-	// Our current A and B counters do not always exactly correspond to the actual A & B chaincode values.
-	// Our currA and currB could be higher than the actual ones, because we count those that
+func (s *Scenario) setQueuedTransactionCounter(numTrans int) {
+	// Our oracle does not always exactly correspond to the actual chaincode values.
+	// It could be ahead of the actual ones, because it counts the transactions that
 	// are queued up whenever Consensus is not working.
 	// "qtrans" is the running total NumberOfTotalTransactionsSinceWeHadEnoughPeersForConsensus,
-	// and thus represents the difference between currA/currB and the actual chaincode values of A/B.
+	// and thus represents the difference between the oracle and the actual chaincode values.
 	
-	if enoughPeersRunningForConsensus() {
-		if qtrans > 0 {
-			if (Verbose) { fmt.Println("Sleep extra to allow processing queued transactions...") }
-			time.Sleep(sleepTimeForTrans(qtrans))
+	if s.enoughPeersRunningForConsensus() {
+		if s.qtrans > 0 {
+			if (s.Verbose) { fmt.Println("Sleep extra to allow processing queued transactions...") }
+			time.Sleep(s.sleepTimeForTrans(s.qtrans))
 		}
         	// Since we have enough nodes running to provide consensus, then reset qtrans to 0 because
 		// our transactions will be processed immediately by the peer and network.
-		qtrans = 0
+		s.qtrans = 0
+		s.chain.Processed()
 	} else {
 		// Otherwise increase qtrans by the new number of transactions
-		qtrans += numTrans
+		s.qtrans += numTrans
 	}
 }
 
@@ -725,7 +733,7 @@ func SleepTimeMinutes(mins int) time.Duration {
 	return ( time.Duration(mins) * SleepTimeSeconds(60) )
 }
 
-func sleepTimeForTrans(nTrans int) time.Duration {
+func (s *Scenario) sleepTimeForTrans(nTrans int) time.Duration {
 	// Given the number of transactions to be processed, determine the sleep for an amount of time (seconds)
 	// based on a predetermined processing rate, e.g.:
 	//     if expected rate is 2 transactions per second, and we receive 20 transactions, then we will sleep 10 secs
@@ -733,16 +741,16 @@ func sleepTimeForTrans(nTrans int) time.Duration {
 	numSecs := nTrans / TransPerSecRate
 
 	// If there are transactions queued, then add sleep time for processing them too
-	if qtrans > 0 {
-		numSecs += qtrans / TransPerSecRate
+	if s.qtrans > 0 {
+		numSecs += s.qtrans / TransPerSecRate
 	}
 
 	// To enable some deterministic testing in low-volume testcases ... let's
 	// ensure all are batched and processed.
 	// The timer is short (default 2 secs), so this shouldn't have a big impact on test duration.
 
-	if numSecs < batchtimeout {
-		numSecs = batchtimeout
+	if numSecs < s.batchtimeout {
+		numSecs = s.batchtimeout
 		//numSecs = 1 	//reduce sleep to only 1 sec, just for some of our "catchup" tests
 	}
 
@@ -756,29 +764,51 @@ func sleepTimeForTrans(nTrans int) time.Duration {
 
 func peerIsRunning(peerNum int, mynetwork peernetwork.PeerNetwork) bool {
 	if peerNum < len(mynetwork.Peers) {
-		if (mynetwork.Peers[peerNum].State == peernetwork.RUNNING) {
+		if (peernetwork.PeerStateOf(mynetwork, peerNum) == peernetwork.RUNNING) {
 			return true
 		}
 	}
 	return false
 }
 
-func getNumberOfPeersRunning() int {
+// Only validating peers take part in consensus; NVPs are queried and their values printed, but not counted.
+
+func (s *Scenario) peerIsValidating(peerNum int) bool {
+	return peerNum < len(s.MyNetwork.Peers) && peernetwork.IsValidatingPeer(peernetwork.PeerCopy(&s.MyNetwork.Peers[peerNum]))
+}
+
+func (s *Scenario) peerCountsForConsensus(peerNum int) bool {
+	return peerIsRunning(peerNum,s.MyNetwork) && s.peerIsValidating(peerNum)
+}
+
+func (s *Scenario) getNumberOfPeersRunning() int {
+	numPeersRunning := 0
+	for i:=0; i < s.NumberOfPeersInNetwork; i++ {		//  NumberOfPeersInNetwork is len(MyNetwork.Peers)
+		if peerIsRunning(i,s.MyNetwork) { numPeersRunning++ }
+	}
+	return numPeersRunning
+}
+
+func (s *Scenario) getNumberOfValidatingPeersRunning() int {
 	numPeersRunning := 0
-	for i:=0; i < NumberOfPeersInNetwork; i++ {		//  NumberOfPeersInNetwork is len(MyNetwork.Peers)
-		if peerIsRunning(i,MyNetwork) { numPeersRunning++ }
+	for i:=0; i < s.NumberOfPeersInNetwork; i++ {
+		if s.peerCountsForConsensus(i) { numPeersRunning++ }
 	}
 	return numPeersRunning
 }
 
-func enoughPeersRunningForConsensus() bool {
-	if (getNumberOfPeersRunning() >= NumberOfPeersNeededForConsensus) { 		// or MinNumberOfPeersNeededForConsensus ???
+func (s *Scenario) enoughPeersRunningForConsensus() bool {
+	if (s.getNumberOfValidatingPeersRunning() >= s.NumberOfPeersNeededForConsensus) { 		// or MinNumberOfPeersNeededForConsensus ???
 		return true
 	}
 	return false
 }
 
-func QueryAllPeers(stepName string) {
+func (s *Scenario) QueryAllPeers(stepName string) {
+	if !s.beginStep("QueryAllPeers") { return }
+	defer s.endStep()
+	s.LogStep("QUERY all peers: " + stepName)
+	s.beginStepResult(stepName)
 
 	// SIDE NOTE: After starting a peer node, if EnforceQueryTestsPass is enabled/true, then
 	// hopefully we sent enough invoke transactions to ensure all are in sync before querying.
@@ -791,17 +821,14 @@ func QueryAllPeers(stepName string) {
 	// CORE_PBFT_GENERAL_LOGMULTIPLIER=4
 	// CORE_PBFT_GENERAL_BATCHSIZE=2
 
-	fmt.Println("\nPOST/Chaincode: QUERY all running peers for a and b, and chainheight\n" + stepName)
-        qArgsa := []string{"a"}
-        qArgsb := []string{"b"}
-	qAPIArgs := []string{"example02", "query", threadutil.GetPeer(0) }
+	keys := s.oracle.Keys(s.qtrans)
+	label := strings.Join(keys, "/")
+	fmt.Println("\nPOST/Chaincode: QUERY all running peers for " + label + ", and chainheight\n" + stepName)
 	n := 0
-	for n=0; n < NumberOfPeersInNetwork; n++ {
-		qData[n].resA = 0
-		qData[n].resB = 0
-		if peerIsRunning(n,MyNetwork) {
-			qAPIArgs = []string{ "example02", "query", threadutil.GetPeer(n) }
-			chco2_QueryOnHost(qAPIArgs, qArgsa, qArgsb, &qData[n].resA, &qData[n].resB)
+	for n=0; n < s.NumberOfPeersInNetwork; n++ {
+		s.qData[n] = nil
+		if peerIsRunning(n,s.MyNetwork) {
+			s.qData[n] = s.queryPeer(s.oracle.Chaincode(), threadutil.GetPeer(n), keys)
 		}
 	}
 
@@ -815,236 +842,274 @@ func QueryAllPeers(stepName string) {
 
 	// Validate all the query results obtained from all the peers; are they what is needed for success?
 
-	if QsMustMatchExpected {
-		// the queried result values (A & B) must match the internal counters - also known as the
-		// "expected" values (currA & currB), plus-or-minus the queued transactions counters
+	// the queried values must match the oracle - also known as the "expected" values - minus the queued transactions
+	expected := s.oracle.Expected(s.qtrans)
+
+	if s.QsMustMatchExpected {
 		passedCount := 0
-		for n=0; n < NumberOfPeersInNetwork; n++ {
-			if peerIsRunning(n,MyNetwork) {
-				if validPeerQueryResults(currA+qtrans, currB-qtrans, qData[n].resA, qData[n].resB, threadutil.GetPeer(n)) {passedCount++}
+		for n=0; n < s.NumberOfPeersInNetwork; n++ {
+			if peerIsRunning(n,s.MyNetwork) {
+				if s.validPeerQueryResults(keys, expected, s.qData[n], threadutil.GetPeer(n)) && s.peerIsValidating(n) {passedCount++}
 			}
 		}
-		printQtrans()
+		s.printQtrans()
 
-		if enoughPeersRunningForConsensus(){
-			if ((passedCount < NumberOfPeersNeededForConsensus) || (AllRunningNodesMustMatch && (passedCount < getNumberOfPeersRunning()))) {
+		if s.enoughPeersRunningForConsensus(){
+			if ((passedCount < s.NumberOfPeersNeededForConsensus) || (s.AllRunningNodesMustMatch && (passedCount < s.getNumberOfValidatingPeersRunning()))) {
 				// FAILURE
-               			myStr := fmt.Sprintf("FAILED QUERY TEST: the required peers do NOT match!!!!!!!!!!\nEXPECTED A/B: %9d %9d.\nACTUALs:", currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("\nPeer%2d        %9d %9d", n, qData[n].resA, qData[n].resB) }
+               			myStr := fmt.Sprintf("FAILED QUERY TEST: the required peers do NOT match!!!!!!!!!!\nEXPECTED %s: %s.\nACTUALs:", label, valueColumns(expected))
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("\nPeer%2d        %s", n, valueColumns(s.qData[n])) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationFailed, myStr, keys, expected)
 
-				handleQueryFailure(stepName)
+				s.handleQueryFailure(stepName)
 			} else {
 				// PASS, Match Expected
-				myStr := fmt.Sprintf("PASSED QUERY TEST: Expected A/B (%d/%d) MATCHED on enough/appropriate Peers. ACTUALs (node:A/B): ", currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("%d:%d/%d ", n, qData[n].resA, qData[n].resB) }
+				myStr := fmt.Sprintf("PASSED QUERY TEST: Expected %s (%s) MATCHED on enough/appropriate Peers. ACTUALs (node:%s): ", label, strings.Join(expected, "/"), label)
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("%d:%s ", n, strings.Join(s.qData[n], "/")) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationPassed, myStr, keys, expected)
 			}
 		} else {
-				myStr := fmt.Sprintf("SKIPPED QUERY VALIDATION: not enough peer nodes running for consensus. Expected A/B (%d/%d). ACTUALs (node:A/B): ", currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("%d:%d/%d ", n, qData[n].resA, qData[n].resB) }
+				myStr := fmt.Sprintf("SKIPPED QUERY VALIDATION: not enough peer nodes running for consensus. Expected %s (%s). ACTUALs (node:%s): ", label, strings.Join(expected, "/"), label)
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("%d:%s ", n, strings.Join(s.qData[n], "/")) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationSkipped, myStr, keys, expected)
 		}
 
 	} else {
 		// validate that enoughPeersRunningForConsensus() contain the same values (which are now stored in qData[]) -
-		// which would mean that they are in sync - but it does not have to equal the internal "expected" values currA and currB
+		// which would mean that they are in sync - but it does not have to equal the internal "expected" values
 
-		if enoughPeersRunningForConsensus() {
+		if s.enoughPeersRunningForConsensus() {
 			// we probably could restructure this section, putting all logic into validPeerQueryResults or other function.
 
 			foundEnoughInConsensus := false
-			consensusValueA := 0
-			consensusValueB := 0
+			var consensusValues []string
 			consensusValueCount := 0
-			for n=0; n < NumberOfPeersInNetwork && !foundEnoughInConsensus; n++ {
-				currentPeerValueOfA := qData[n].resA
-				currentPeerValueOfB := qData[n].resB
-				if currentPeerValueOfA != 0 || currentPeerValueOfB != 0 {
+			for n=0; n < s.NumberOfPeersInNetwork && !foundEnoughInConsensus; n++ {
+				currentPeerValues := s.qData[n]
+				if currentPeerValues != nil && s.peerIsValidating(n) {
 					currentCount := 1
-					for p := n+1; p < NumberOfPeersInNetwork; p++ {
-						if qData[p].resA == currentPeerValueOfA && qData[p].resB == currentPeerValueOfB { currentCount++ } 
+					for p := n+1; p < s.NumberOfPeersInNetwork; p++ {
+						if s.qData[p] != nil && s.oracle.Matches(currentPeerValues, s.qData[p]) && s.peerIsValidating(p) { currentCount++ }
 					}
-					if currentCount >= NumberOfPeersNeededForConsensus  {
+					if currentCount >= s.NumberOfPeersNeededForConsensus  {
 						consensusValueCount = currentCount
-						consensusValueA = currentPeerValueOfA
-						consensusValueB = currentPeerValueOfB
+						consensusValues = currentPeerValues
 						foundEnoughInConsensus = true
 					}
 				}
 			}
 			if foundEnoughInConsensus {
 				// PASS, Consensus
-				myStr := fmt.Sprintf("PASSED QUERY TEST: Enough (%d) peers agree for Consensus (required=%d) with values A/B %d/%d. It is not required to match expected values A/B %d/%d. ACTUALs (node:A/B): ", consensusValueCount, NumberOfPeersNeededForConsensus, consensusValueA, consensusValueB, currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("%d:%d/%d ", n, qData[n].resA, qData[n].resB) }
+				myStr := fmt.Sprintf("PASSED QUERY TEST: Enough (%d) peers agree for Consensus (required=%d) with values %s %s. It is not required to match expected values %s %s. ACTUALs (node:%s): ", consensusValueCount, s.NumberOfPeersNeededForConsensus, label, strings.Join(consensusValues, "/"), label, strings.Join(expected, "/"), label)
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("%d:%s ", n, strings.Join(s.qData[n], "/")) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationPassed, myStr, keys, consensusValues)
 			} else {
 				// FAILURE
-               			myStr := fmt.Sprintf("FAILED QUERY TEST: peers do not agree!!!!!!!!!! (even though it is NOT required to match Expected A/B %d/%d.\nACTUALs:", currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("\nPeer%2d        %9d %9d", n, qData[n].resA, qData[n].resB) }
+               			myStr := fmt.Sprintf("FAILED QUERY TEST: peers do not agree!!!!!!!!!! (even though it is NOT required to match Expected %s %s.\nACTUALs:", label, strings.Join(expected, "/"))
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("\nPeer%2d        %s", n, valueColumns(s.qData[n])) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationFailed, myStr, keys, expected)
 
-				handleQueryFailure(stepName)
+				s.handleQueryFailure(stepName)
 			}
 
 		} else {
-				myStr := fmt.Sprintf("SKIPPED QUERY VALIDATION: not enough peer nodes running for consensus. It is not required to match expected values A/B %d/%d. ACTUALs (node:A/B): ", currA, currB)
-        			for n = 0; n < NumberOfPeersInNetwork; n++ {
-        				if peerIsRunning(n,MyNetwork) { myStr += fmt.Sprintf("%d:%d/%d ", n, qData[n].resA, qData[n].resB) }
+				myStr := fmt.Sprintf("SKIPPED QUERY VALIDATION: not enough peer nodes running for consensus. It is not required to match expected values %s %s. ACTUALs (node:%s): ", label, strings.Join(expected, "/"), label)
+        			for n = 0; n < s.NumberOfPeersInNetwork; n++ {
+        				if peerIsRunning(n,s.MyNetwork) { myStr += fmt.Sprintf("%d:%s ", n, strings.Join(s.qData[n], "/")) }
 				}
                			fmt.Println(myStr)
+				s.recordQueryResult(ValidationSkipped, myStr, keys, expected)
 		}
 	}
 
 	// here we do the same checks for chainheight, but wrapped in a function...
 	// if (!validChainHeights()) {
-	if (!validateAllChainHeights()) {
-		 handleChainHeightFailure(stepName)
+	if (!s.validateAllChainHeights()) {
+		 s.handleChainHeightFailure(stepName)
 	}
 }
 
-func printQtrans() {
-        if (Verbose) {
-                myOutStr := fmt.Sprintf(" qtrans (total) = %5d", qtrans)
-                for n:=0; n < NumberOfPeersInNetwork; n++ {
-                        myOutStr += fmt.Sprintf("\n qtransPerPeerForCH[%2d] = %5d", n, qtransPerPeerForCH[n])
-                }
-                fmt.Println(myOutStr)
+// the values in columns, like the A and B of example02
+func valueColumns(values []string) string {
+	str := ""
+	for _, v := range values { str += fmt.Sprintf(" %9s", v) }
+	return str
+}
+
+func (s *Scenario) printQtrans() {
+        if (s.Verbose) {
+                fmt.Println(fmt.Sprintf(" qtrans (total) = %5d", s.qtrans))
         }
 }
 
-func handleQueryFailure(stepName string) {
-	queryTestsPass = false
-	if ( Stop_on_error && EnforceQueryTestsPass ) {
-		myOutStr := CurrentTestName + " FAILURE during QUERY : " + stepName
-		fmt.Fprintln(Writer, myOutStr)		// write to the output results file
-		Writer.Flush()
+func (s *Scenario) handleQueryFailure(stepName string) {
+	if s.Interrupted() { return }		// the failure may come from the interrupt
+	s.queryTestsPass = false
+	s.LogStep("FAILURE during QUERY : " + stepName)
+	s.recordFailure("FAILURE during QUERY : " + stepName)
+	s.diagnose(stepName)
+	if ( s.Stop_on_error && s.EnforceQueryTestsPass ) {
+		myOutStr := s.CurrentTestName + " FAILURE during QUERY : " + stepName
+		fmt.Fprintln(s.Writer, myOutStr)		// write to the output results file
+		s.Writer.Flush()
+		if s.ArtifactsMode != "NEVER" { s.CaptureArtifacts() }	// log.Fatal exits without running the deferred TimeTrack
+		s.TestResult = "FAILED"
+		s.WriteResults(s.TestResult)
 		log.Fatal (myOutStr)			// write to stdout, and stop the test
 	}
 }
 
-func handleChainHeightFailure(stepName string) {
-	chainHeightTestsPass = false
-	if ( Stop_on_error && EnforceChainHeightTestsPass ) {
-		myOutStr := CurrentTestName + " FAILURE with CHAINHEIGHT : " + stepName
-		fmt.Fprintln(Writer, myOutStr)		// write to the output results file
-		Writer.Flush()
+func (s *Scenario) handleChainHeightFailure(stepName string) {
+	if s.Interrupted() { return }
+	s.chainHeightTestsPass = false
+	s.LogStep("FAILURE with CHAINHEIGHT : " + stepName)
+	s.recordFailure("FAILURE with CHAINHEIGHT : " + stepName)
+	s.diagnose(stepName)
+	if ( s.Stop_on_error && s.EnforceChainHeightTestsPass ) {
+		myOutStr := s.CurrentTestName + " FAILURE with CHAINHEIGHT : " + stepName
+		fmt.Fprintln(s.Writer, myOutStr)		// write to the output results file
+		s.Writer.Flush()
+		if s.ArtifactsMode != "NEVER" { s.CaptureArtifacts() }	// log.Fatal exits without running the deferred TimeTrack
+		s.TestResult = "FAILED"
+		s.WriteResults(s.TestResult)
 		log.Fatal (myOutStr)			// write to stdout, and stop test
 	}
 }
 
-func StopPeers(peerNumsToStopStart []int) {
+func (s *Scenario) StopPeers(peerNumsToStopStart []int) {
+	s.StopPeersWithMode(peerNumsToStopStart, s.DisruptionMode)
+}
+
+// Stops the peers with the given disruption mode (DisruptStop, DisruptPause, DisruptKill or DisruptKillWipe),
+// regardless of the STOP_OR_PAUSE mode used by StopPeers; RestartPeers remembers how each peer was stopped.
+func (s *Scenario) StopPeersWithMode(peerNumsToStopStart []int, mode string) {
+	if !s.beginStep("StopPeersWithMode") { return }
+	defer s.endStep()
 	rootPeer := false
+	switch mode {
+	case DisruptStop, DisruptPause, DisruptKill, DisruptKillWipe:
+	default:
+		fmt.Println("\nStopPeersWithMode(): INVALID disruption mode (" + mode + ")! Returning without touching any peer nodes!")
+		return
+	}
 	if (len(peerNumsToStopStart) == 0) {
-		if pauseInsteadOfStop { fmt.Println("\nPAUSE Peers:  [none requested]")
-		} else {                fmt.Println("\nSTOP Peers:  [none requested]") }
+		fmt.Println("\n" + mode + " Peers:  [none requested]")
 	} else {
 		myOutStr := fmt.Sprintf("\n")
-		if pauseInsteadOfStop { myOutStr += fmt.Sprintf("PAUSE Peers():")
-		} else {                myOutStr += fmt.Sprintf("STOP Peers():") }
+		myOutStr += mode + " Peers():"
 
 		var peersToStopStart []string
-		peersToStopStart = make([]string, NumberOfPeersInNetwork)
+		peersToStopStart = make([]string, s.NumberOfPeersInNetwork)
 
 		//  if !buildPeersList(peerNumsToStopStart, &peersToStopStart, &myOutStr) { return }
+		primary := s.PrimaryPeer()
 		i:= 0
 		for i < len(peerNumsToStopStart) {
 			peerNum := peerNumsToStopStart[i]
-			peerName := fmt.Sprintf(threadutil.GetPeer(peerNum))
+			peerName := threadutil.GetPeer(peerNum)
 			myOutStr += "  " + peerName
-			if peerNum >= len(MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
+			if peerNum >= len(s.MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
 				myOutStr += fmt.Sprintf(" --> Peer NOT FOUND! Returning without touching any peer nodes!")
 				fmt.Println(myOutStr)
 				return 
 			} else {
-				if (MyNetwork.Peers[peerNum].State != peernetwork.RUNNING) {
+				if (peernetwork.PeerStateOf(s.MyNetwork, peerNum) != peernetwork.RUNNING) {
 					myOutStr += fmt.Sprintf("(alreadyNotRUNNING)")
 				} else {
-					if (peerNum == 0) || ((peerNum==1) && !peerIsRunning(0,MyNetwork) && !rootPeer) {
-						// TODO: enhance for larger networks when more nodes could be down.
-
-						rootPeer = true	// we are impacting the primary/root peer
-
-						// incr is needed here for CAT_07 and others - but NOT in all similar scenarios where rootPeer is stop/restarted,
-						// so THIS COULD USE more analysis, if we are going to try to validate CH in testcases
-						//   incrHeightCount(1, peerNum)
+					if peerNum == primary {
+						rootPeer = true	// we are impacting the primary peer, which causes a view change
+						myOutStr += fmt.Sprintf("(PRIMARY)")
+						s.stoppedWhilePrimary[peerNum] = true
 					}
 				}
 			}
-			peersToStopStart[i] = peerName
+			peersToStopStart[i] = s.container(peerNum)
 			i++
 		}
 		fmt.Println(myOutStr)
+		s.LogStep(myOutStr)
 
 		//peernetwork.StopPeersLocal(MyNetwork, peersToStopStart)
 
 		for j:=0; j < i; j++ {
-			if pauseInsteadOfStop {
-				peernetwork.PausePeerLocal(MyNetwork, peersToStopStart[j]) 	// includes sleeping 5 secs after each Pause
-			} else {
-				peernetwork.StopPeerLocal(MyNetwork, peersToStopStart[j]) 	// includes sleeping 5 secs after each Stop
+			switch mode {
+			case DisruptPause:
+				peernetwork.PausePeerLocal(s.MyNetwork, peersToStopStart[j]) 	// waits until the container is paused
+			case DisruptKill:
+				peernetwork.KillPeerLocal(s.MyNetwork, peersToStopStart[j]) 	// waits until the container exited
+			case DisruptKillWipe:
+				if err := s.nodeController().KillAndWipe(s.MyNetwork, peersToStopStart[j]); err != nil { fmt.Println("ERROR: " + err.Error()) }	// with the helper image of this network
+				s.chain.Forget(threadutil.GetPeer(peerNumsToStopStart[j]))
+			default:
+				peernetwork.StopPeerLocal(s.MyNetwork, peersToStopStart[j]) 	// waits until the container exited
 			}
+			s.peerDisruptedBy[peerNumsToStopStart[j]] = mode
 		}
+		// Rather than sleeping extra (30 secs when stopping the primary, else 10), wait until the
+		// remaining peers moved to a new view with another primary, and every peer that should
+		// still be running answers /chain again.
 		if (rootPeer) {
-			// sleep extra when stopping/starting primary/root peer0
-			fmt.Println("Sleep extra 30 secs because stopping primary") 
-			time.Sleep(30000 * time.Millisecond) 
-		} else {
-			fmt.Println("Sleep extra 10 secs")
-			time.Sleep(10000 * time.Millisecond) 
+			fmt.Println("Stopped primary; wait for the view change")
+			s.waitForViewChange(primary, SleepTimeSeconds(30))
 		}
+		s.waitForRunningPeersReady(SleepTimeSeconds(10))
 	}
 }
 
-func RestartPeers(peerNumsToStopStart []int) {
+func (s *Scenario) RestartPeers(peerNumsToStopStart []int) {
+	if !s.beginStep("RestartPeers") { return }
+	defer s.endStep()
 	rootPeer := false
 	if (len(peerNumsToStopStart) == 0) {
-		if pauseInsteadOfStop { fmt.Println("\nUNPAUSE Peers:  [none requested]")
-		} else {                fmt.Println("\nRESTART Peers:  [none requested]") }
+		if s.DisruptionMode == DisruptPause { fmt.Println("\nUNPAUSE Peers:  [none requested]")
+		} else {                            fmt.Println("\nRESTART Peers:  [none requested]") }
 	} else {
 		myOutStr := fmt.Sprintf("\n")
-		if pauseInsteadOfStop { myOutStr += fmt.Sprintf("UNPAUSE Peers():")
-		} else {                myOutStr += fmt.Sprintf("RESTART Peers():") }
+		if s.DisruptionMode == DisruptPause { myOutStr += fmt.Sprintf("UNPAUSE Peers():")
+		} else {                            myOutStr += fmt.Sprintf("RESTART Peers():") }
 
 		var peersToStopStart []string
-		peersToStopStart = make([]string, NumberOfPeersInNetwork)
+		peersToStopStart = make([]string, s.NumberOfPeersInNetwork)
 
 		//  if !buildPeersList(peerNumsToStopStart, &peersToStopStart, &myOutStr) { return }
 		i:= 0
 		for i < len(peerNumsToStopStart) {
 			peerNum := peerNumsToStopStart[i]
-			peerName := fmt.Sprintf(threadutil.GetPeer(peerNum))
+			peerName := threadutil.GetPeer(peerNum)
 			myOutStr += "  " + peerName
-			if peerNum >= len(MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
+			if peerNum >= len(s.MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
 				myOutStr += fmt.Sprintf(" --> Peer NOT FOUND! Returning without touching any peer nodes!")
 				fmt.Println(myOutStr)
 				return 
 			}
-			if (MyNetwork.Peers[peerNum].State == peernetwork.RUNNING) {
+			if (peernetwork.PeerStateOf(s.MyNetwork, peerNum) == peernetwork.RUNNING) {
 				myOutStr += fmt.Sprintf("(alreadyRUNNING)")
+			} else if mode, ok := s.peerDisruptedBy[peerNum]; ok && mode != s.DisruptionMode {
+				myOutStr += "(" + mode + ")"
 			}
-			if (peerNum == 0) || ((peerNum==1) && !peerIsRunning(0,MyNetwork)) {
-					// TODO: enhance "if" check for larger networks when more nodes could be down.
-
-					rootPeer = true		// we are impacting the primary/root peer0
-
-					// TODO incrHeightCount(1, peerNum) 	// is incr needed here? Observed no consistency in all similar
-										// scenarios where rootPeer is stop/restarted, so THIS COULD USE IMPROVEMENT
+			if s.stoppedWhilePrimary[peerNum] {
+					rootPeer = true		// we are restarting the peer that was primary when it was stopped
 			}
-			peersToStopStart[i] = peerName
+			peersToStopStart[i] = s.container(peerNum)
 			i++
 		}
 		fmt.Println(myOutStr)
+		s.LogStep(myOutStr)
 		//peernetwork.StartPeersLocal(MyNetwork, peersToStopStart)
 		for j:= 0; j < i; j++ {
 			// Once we stop and restart at least one peer node, (assuming StartPeerLocal() was successful),
@@ -1052,40 +1117,85 @@ func RestartPeers(peerNumsToStopStart []int) {
 			// Set false because the testcases shouldn't fail as long as we maintain consensus -
 			// but only when the node we are restarting is extra (more than the minimum required for consensus).
 
-			if enoughPeersRunningForConsensus() {
+			if s.enoughPeersRunningForConsensus() {
 				// We already have enough peer nodes running for consensus, so
 				// this one will be extra and therefore does not have to sync up exactly.
 
-				AllRunningNodesMustMatch = false
+				s.AllRunningNodesMustMatch = false
 			}
 
-			if pauseInsteadOfStop {
-				peernetwork.UnpausePeerLocal(MyNetwork, peersToStopStart[j]) 	// includes sleeping 5 secs after each Unpause
+			mode, ok := s.peerDisruptedBy[peerNumsToStopStart[j]]
+			if !ok { mode = s.DisruptionMode }
+			if mode == DisruptPause {
+				peernetwork.UnpausePeerLocal(s.MyNetwork, peersToStopStart[j]) 	// waits until the peer answers /chain
 			} else {
-				peernetwork.StartPeerLocal(MyNetwork, peersToStopStart[j]) 	// includes sleeping 5 secs after each Restart
+				peernetwork.StartPeerLocal(s.MyNetwork, peersToStopStart[j]) 	// waits until the peer answers /chain
 			}
+			delete(s.peerDisruptedBy, peerNumsToStopStart[j])
+			delete(s.stoppedWhilePrimary, peerNumsToStopStart[j])
 		}
-		if (rootPeer) {
-			// sleep extra when stopping/starting primary/root peer
-			fmt.Println("Sleep extra 60 secs because restarting potential primary") 
-			time.Sleep(60000 * time.Millisecond) 
-		} else {
-			fmt.Println("Sleep extra 30 secs")
-			time.Sleep(30000 * time.Millisecond) 
+		// The restarted peers are already known to answer /chain. Instead of sleeping extra
+		// (60 secs when restarting a potential primary, else 30), wait for them to catch up.
+		catchUpTimeout := SleepTimeSeconds(30)
+		if (rootPeer) { catchUpTimeout = SleepTimeSeconds(60) }
+		if s.CatchUpBlocks >= 0 {
+			for j:= 0; j < i; j++ {
+				err := peernetwork.WaitForPeerCaughtUp(s.MyNetwork, peersToStopStart[j], s.CatchUpBlocks, catchUpTimeout)
+				if err != nil { fmt.Println("RestartPeers(): WARNING: " + err.Error()) }
+			}
+		}
+	}
+}
+
+// Waits until the peers moved to a new view whose primary is not oldPrimary, or the timeout expires.
+// When the peer logs cannot tell the view (e.g. CORE_LOGGING_LEVEL=error), sleeps the timeout as before.
+func (s *Scenario) waitForViewChange(oldPrimary int, timeout time.Duration) {
+	if _, _, known := peernetwork.DiscoverPrimary(s.MyNetwork); !known {
+		fmt.Println("WARNING: view unknown from peer logs (set CORE_LOGGING_LEVEL=info); sleep " + timeout.String())
+		time.Sleep(timeout)
+		return
+	}
+	elapsed, err := peernetwork.WaitUntil("a view change away from primary " + threadutil.GetPeer(oldPrimary), timeout, func() bool {
+		_, replica, known := peernetwork.DiscoverPrimary(s.MyNetwork)
+		return known && peernetwork.PeerOfReplica(s.MyNetwork, replica) != oldPrimary
+	})
+	if err != nil {
+		fmt.Println("WARNING: " + err.Error())
+	} else {
+		fmt.Println("New view after " + elapsed.String() + ", primary " + threadutil.GetPeer(s.PrimaryPeer()))
+	}
+}
+
+// The docker container of peer n: its name in MyNetwork, chosen by the profile of a registered network
+// (e.g. netB_PEER0). threadutil.GetPeer(n) names the peer in the messages and the chaincode requests.
+func (s *Scenario) container(peerNum int) string {
+	if peerNum < len(s.MyNetwork.Peers) {
+		if name := s.MyNetwork.Peers[peerNum].PeerDetails["name"]; name != "" { return name }
+	}
+	return threadutil.GetPeer(peerNum)
+}
+
+// Waits until every peer we believe is running answers /chain, or the timeout expires.
+func (s *Scenario) waitForRunningPeersReady(timeout time.Duration) {
+	for n := 0; n < s.NumberOfPeersInNetwork && n < len(s.MyNetwork.Peers); n++ {
+		if peerIsRunning(n,s.MyNetwork) {
+			if err := peernetwork.WaitForPeerReady(s.MyNetwork, s.container(n), timeout); err != nil {
+				fmt.Println("WARNING: " + err.Error())
+			}
 		}
 	}
 }
 
 // currently unused:
 // to call:  if !buildPeersList(peerNumsToStopStart, &peersToStopStart, &myOutStr) { return }
-func buildPeersList(peerNumsToStopStart []int, peersToStopStart *[]string, myOutStr *string) bool {
+func (s *Scenario) buildPeersList(peerNumsToStopStart []int, peersToStopStart *[]string, myOutStr *string) bool {
 		i := 0
 		for i < len(peerNumsToStopStart) {
 			peerNum := peerNumsToStopStart[i]
 			peerName := fmt.Sprintf(threadutil.GetPeer(peerNum))
 			*myOutStr = *myOutStr + "  " + peerName
 			(*peersToStopStart)[i] = peerName
-			if peerNum >= len(MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
+			if peerNum >= len(s.MyNetwork.Peers) { 	// if peerName is not in (MyNetwork.Peers)
 				*myOutStr = *myOutStr + fmt.Sprintf(" --> Peer NOT FOUND! Returning without touching any peer nodes!")
 				fmt.Println(myOutStr)
 				return false
@@ -1096,47 +1206,449 @@ func buildPeersList(peerNumsToStopStart []int, peersToStopStart *[]string, myOut
 		return true
 }
 
-func StopMemberServices() {
+// Returns the peer number of the current PBFT primary, found from the view changes in the peer logs
+// (see peernetwork.DiscoverPrimary). When the logs cannot tell (e.g. CORE_LOGGING_LEVEL=error),
+// falls back to guessing: peer 0, or peer 1 if peer 0 is not running.
+func (s *Scenario) PrimaryPeer() int {
+	view, replica, known := peernetwork.DiscoverPrimary(s.MyNetwork)
+	primary := peernetwork.PeerOfReplica(s.MyNetwork, replica)
+	if known && primary >= 0 {
+		if s.Verbose { fmt.Println("PrimaryPeer(): view " + strconv.Itoa(view) + ", primary " + threadutil.GetPeer(primary)) }
+		return primary
+	}
+	primary = 0
+	if !peerIsRunning(0,s.MyNetwork) { primary = 1 }
+	fmt.Println("PrimaryPeer(): WARNING: view unknown from peer logs (set CORE_LOGGING_LEVEL=info); guessing primary " + threadutil.GetPeer(primary))
+	return primary
+}
+
+// Returns the peer numbers of the running validating peers that are not the primary.
+func (s *Scenario) BackupPeers() []int {
+	primary := s.PrimaryPeer()
+	var backups []int
+	for n := 0; n < s.NumberOfPeersInNetwork && n < len(s.MyNetwork.Peers); n++ {
+		if n != primary && s.peerCountsForConsensus(n) { backups = append(backups, n) }
+	}
+	return backups
+}
+
+// Stops the current primary, whichever peer that is, and returns its peer number (for RestartPeers).
+func (s *Scenario) StopPrimary() int {
+	primary := s.PrimaryPeer()
+	s.StopPeers( []int{ primary } )
+	return primary
+}
+
+// Stops numBackups running backup peers (the highest numbered ones), and returns their peer numbers (for RestartPeers).
+func (s *Scenario) StopBackups(numBackups int) []int {
+	backups := s.BackupPeers()
+	if numBackups > len(backups) { numBackups = len(backups) }
+	if numBackups < 0 { numBackups = 0 }
+	stopped := backups[len(backups)-numBackups:]
+	s.StopPeers(stopped)
+	return stopped
+}
+
+// Launches a new peer that joins the running network as peer number NumberOfPeersInNetwork (container PEER<n>),
+// registers its user, and returns its peer number; or -1 if it could not be added. role is peernetwork.RoleVP or
+// RoleNVP. PBFT has a fixed N, so a new validating peer must take the replica id of a decommissioned one, e.g.
+// DecommissionPeer(2) and then AddPeer(peernetwork.RoleVP, "vp2"); with peerID "" it takes the first one free.
+// The new node then starts with an empty ledger and must catch up by state transfer. An NVP defaults to nvp<n>.
+func (s *Scenario) AddPeer(role string, peerID string) int {
+	if !s.beginStep("AddPeer") { return -1 }
+	defer s.endStep()
+	peerNum := s.NumberOfPeersInNetwork
+	if strings.ToUpper(role) != peernetwork.RoleNVP {
+		// a replica id is free when it is below N and no peer but a decommissioned one has it
+		if peerID == "" {
+			for i := range s.MyNetwork.Peers {
+				replica := peernetwork.ReplicaID(s.MyNetwork, i)
+				if replica >= 0 && replica < s.NumberOfValidatingPeers && peernetwork.PeerOfReplica(s.MyNetwork, replica) < 0 {
+					peerID = "vp" + strconv.Itoa(replica)
+					break
+				}
+			}
+			if peerID == "" {
+				fmt.Println("AddPeer(): ERROR: the network has N=" + strconv.Itoa(s.NumberOfValidatingPeers) + " replicas and none is decommissioned; a new validating peer could not take part in consensus")
+				return -1
+			}
+		} else {
+			replica, err := strconv.Atoi(strings.TrimPrefix(peerID, "vp"))
+			if err != nil || !strings.HasPrefix(peerID, "vp") || replica < 0 || replica >= s.NumberOfValidatingPeers {
+				fmt.Println("AddPeer(): ERROR: the network has N=" + strconv.Itoa(s.NumberOfValidatingPeers) + " replicas; " + peerID + " could not take part in consensus")
+				return -1
+			}
+			if holder := peernetwork.PeerOfReplica(s.MyNetwork, replica); holder >= 0 {
+				fmt.Println("AddPeer(): ERROR: replica " + peerID + " is " + threadutil.GetPeer(holder) + "; decommission it first")
+				return -1
+			}
+		}
+	}
+	fmt.Println("\nADD Peer " + threadutil.GetPeer(peerNum) + " (" + role + " " + peerID + ")")
+	spec := peernetwork.NewPeer{Container: threadutil.GetPeer(peerNum), PeerID: peerID, Role: role}
+	newNetwork, err := s.nodeController().AddPeer(s.MyNetwork, spec)
+	if err != nil {
+		fmt.Println("AddPeer(): ERROR: " + err.Error())
+		return -1
+	}
+	s.LogStep("ADD Peer " + threadutil.GetPeer(peerNum) + " (" + role + " " + peerID + ")")
+
+	// every holder of the old network must see the new peer, including the health monitor
+	s.MyNetwork = newNetwork
+	if s.cc != nil { s.cc.Network = s.MyNetwork }
+	if s.Network == "" { chaincode.ThisNetwork = s.MyNetwork }	// the package functions work on the local network too
+	if s.healthMonitor != nil {
+		s.healthMonitor.Stop()
+		s.healthMonitor = peernetwork.StartHealthMonitor(s.MyNetwork, SleepTimeSeconds(s.healthInterval))
+	}
+	s.NumberOfPeersInNetwork++
+	s.qData = append(s.qData, nil)
+	chaincode.RegisterUsersOnPeer(peernetwork.PeerCopy(&s.MyNetwork.Peers[peerNum]))
+	return peerNum
+}
+
+// Removes a peer from the network for good: its container and ledger are deleted. It keeps its peer number, and
+// no longer counts as running; a decommissioned validating peer is one of the F peers the network can lose.
+func (s *Scenario) DecommissionPeer(peerNum int) {
+	if !s.beginStep("DecommissionPeer") { return }
+	defer s.endStep()
+	fmt.Println("\nDECOMMISSION Peer " + threadutil.GetPeer(peerNum))
+	s.LogStep("DECOMMISSION Peer " + threadutil.GetPeer(peerNum))
+	if err := s.nodeController().Decommission(s.MyNetwork, s.container(peerNum)); err != nil {
+		fmt.Println("DecommissionPeer(): ERROR: " + err.Error())
+		return
+	}
+	delete(s.peerDisruptedBy, peerNum)
+	delete(s.stoppedWhilePrimary, peerNum)
+	s.chain.Forget(threadutil.GetPeer(peerNum))
+	fmt.Println("DecommissionPeer(): " + strconv.Itoa(s.getNumberOfPeersRunning()) + " peers running; " + strconv.Itoa(s.NumberOfPeersNeededForConsensus) + " needed for consensus")
+}
+
+func (s *Scenario) StopMemberServices() {
+	if !s.beginStep("StopMemberServices") { return }
+	defer s.endStep()
 	fmt.Println("\n\n\n\nSTOP MemberServices (caserver)!\n\n\n")
+	s.LogStep("STOP MemberServices (caserver)")
 	//peernetwork.StopMemberServices(MyNetwork)
-	peernetwork.StopPeerLocal(MyNetwork, "caserver")
+	peernetwork.StopPeerLocal(s.MyNetwork, peernetwork.CAContainer(s.MyNetwork))
 }
 
-func RestartMemberServices() {
+func (s *Scenario) RestartMemberServices() {
+	if !s.beginStep("RestartMemberServices") { return }
+	defer s.endStep()
 	fmt.Println("\n\n\n\nRESTART MemberServices (caserver)!\n\n\n")
-	peernetwork.StartPeerLocal(MyNetwork, "caserver")
+	s.LogStep("RESTART MemberServices (caserver)")
+	peernetwork.StartPeerLocal(s.MyNetwork, peernetwork.CAContainer(s.MyNetwork))
+}
+
+// Splits the network into groups of peers that cannot reach each other, e.g. PartitionPeers([][]int{{0,1,2},{3}}).
+// The containers keep running, so until HealPartitions the peers cut off from a consensus quorum will lag behind;
+// as when restarting a peer, only enough peers for consensus are then required to match.
+func (s *Scenario) PartitionPeers(peerGroups [][]int) {
+	if !s.beginStep("PartitionPeers") { return }
+	defer s.endStep()
+	groups := make([][]string, len(peerGroups))
+	myOutStr := "\nPARTITION Peers():"
+	for g, peerNums := range peerGroups {
+		myOutStr += "  {"
+		for _, peerNum := range peerNums {
+			if peerNum >= len(s.MyNetwork.Peers) {
+				fmt.Println(myOutStr + " " + threadutil.GetPeer(peerNum) + " --> Peer NOT FOUND! Returning without touching any peer nodes!")
+				return
+			}
+			groups[g] = append(groups[g], s.container(peerNum))
+			myOutStr += " " + threadutil.GetPeer(peerNum)
+		}
+		myOutStr += " }"
+	}
+	fmt.Println(myOutStr)
+	s.LogStep(myOutStr)
+	s.AllRunningNodesMustMatch = false
+	if err := s.nodeController().Partition(s.MyNetwork, groups...); err != nil {
+		fmt.Println("PartitionPeers(): ERROR: " + err.Error())
+	}
+}
+
+// Drops the link between two peers; both keep talking to all the others.
+func (s *Scenario) DropLink(peerNumA int, peerNumB int) {
+	if !s.beginStep("DropLink") { return }
+	defer s.endStep()
+	fmt.Println("\nDROP LINK between Peers():  " + threadutil.GetPeer(peerNumA) + "  " + threadutil.GetPeer(peerNumB))
+	s.LogStep("DROP LINK between Peers():  " + threadutil.GetPeer(peerNumA) + "  " + threadutil.GetPeer(peerNumB))
+	if peerNumA >= len(s.MyNetwork.Peers) || peerNumB >= len(s.MyNetwork.Peers) {
+		fmt.Println("DropLink(): Peer NOT FOUND! Returning without touching any peer nodes!")
+		return
+	}
+	s.AllRunningNodesMustMatch = false
+	if err := s.nodeController().DropLink(s.MyNetwork, s.container(peerNumA), s.container(peerNumB)); err != nil {
+		fmt.Println("DropLink(): ERROR: " + err.Error())
+	}
+}
+
+// Restores every link dropped by PartitionPeers or DropLink, and waits for the peers to answer again.
+func (s *Scenario) HealPartitions() {
+	if !s.beginStep("HealPartitions") { return }
+	defer s.endStep()
+	fmt.Println("\nHEAL Partitions():  ", s.nodeController().DroppedLinks())
+	s.LogStep("HEAL Partitions()")
+	if err := s.nodeController().Heal(s.MyNetwork); err != nil {
+		fmt.Println("HealPartitions(): ERROR: " + err.Error())
+	}
+	s.waitForRunningPeersReady(SleepTimeSeconds(30))
+}
+
+// Makes the given peers slow or lossy replicas, e.g. DegradePeers([]int{3}, peernetwork.SlowLink)
+// or DegradePeers([]int{2,3}, peernetwork.LinkProfile{Delay: SleepTimeSeconds(3)}) to exceed the batch timeout.
+// The peers keep running but may fall behind, so only enough peers for consensus are then required to match.
+func (s *Scenario) DegradePeers(peerNums []int, profile peernetwork.LinkProfile) {
+	if !s.beginStep("DegradePeers") { return }
+	defer s.endStep()
+	myOutStr := "\nDEGRADE Peers():"
+	for _, peerNum := range peerNums { myOutStr += "  " + threadutil.GetPeer(peerNum) }
+	fmt.Println(myOutStr + "  [" + profile.String() + "]")
+	s.LogStep(myOutStr + "  [" + profile.String() + "]")
+	for _, peerNum := range peerNums {
+		if peerNum >= len(s.MyNetwork.Peers) {
+			fmt.Println("DegradePeers(): " + threadutil.GetPeer(peerNum) + " --> Peer NOT FOUND!")
+			continue
+		}
+		s.AllRunningNodesMustMatch = false
+		if err := s.nodeController().Degrade(s.MyNetwork, s.container(peerNum), profile); err != nil {
+			fmt.Println("DegradePeers(): ERROR: " + err.Error())
+		}
+	}
+}
+
+// Clears the degraded links of the given peers.
+func (s *Scenario) RestorePeersLinks(peerNums []int) {
+	if !s.beginStep("RestorePeersLinks") { return }
+	defer s.endStep()
+	myOutStr := "\nRESTORE Peers() links:"
+	for _, peerNum := range peerNums { myOutStr += "  " + threadutil.GetPeer(peerNum) }
+	fmt.Println(myOutStr)
+	s.LogStep(myOutStr)
+	for _, peerNum := range peerNums {
+		if peerNum >= len(s.MyNetwork.Peers) { continue }
+		if err := s.nodeController().Restore(s.MyNetwork, s.container(peerNum)); err != nil {
+			fmt.Println("RestorePeersLinks(): ERROR: " + err.Error())
+		}
+	}
 }
 
-func TimeTrack(start time.Time, name string) {
+// Limits the CPU and/or memory of the given peers, e.g. ThrottlePeers([]int{2}, peernetwork.ResourceLimits{CPUPercent: 10}).
+func (s *Scenario) ThrottlePeers(peerNums []int, limits peernetwork.ResourceLimits) {
+	if !s.beginStep("ThrottlePeers") { return }
+	defer s.endStep()
+	myOutStr := "\nTHROTTLE Peers():"
+	for _, peerNum := range peerNums { myOutStr += "  " + threadutil.GetPeer(peerNum) }
+	fmt.Println(myOutStr + "  [" + limits.String() + "]")
+	s.LogStep(myOutStr + "  [" + limits.String() + "]")
+	for _, peerNum := range peerNums {
+		if peerNum >= len(s.MyNetwork.Peers) {
+			fmt.Println("ThrottlePeers(): " + threadutil.GetPeer(peerNum) + " --> Peer NOT FOUND!")
+			continue
+		}
+		s.AllRunningNodesMustMatch = false
+		if err := s.nodeController().Throttle(s.MyNetwork, s.container(peerNum), limits); err != nil {
+			fmt.Println("ThrottlePeers(): ERROR: " + err.Error())
+		}
+	}
+}
+
+// Gives the given peers back the CPU and memory limits they had before ThrottlePeers.
+func (s *Scenario) UnthrottlePeers(peerNums []int) {
+	if !s.beginStep("UnthrottlePeers") { return }
+	defer s.endStep()
+	myOutStr := "\nUNTHROTTLE Peers():"
+	for _, peerNum := range peerNums { myOutStr += "  " + threadutil.GetPeer(peerNum) }
+	fmt.Println(myOutStr)
+	s.LogStep(myOutStr)
+	for _, peerNum := range peerNums {
+		if peerNum >= len(s.MyNetwork.Peers) { continue }
+		if err := s.nodeController().Unthrottle(s.MyNetwork, s.container(peerNum)); err != nil {
+			fmt.Println("UnthrottlePeers(): ERROR: " + err.Error())
+		}
+	}
+}
+
+// Throttles the given peers for the duration while invokes continue, e.g. peer 2 at 10% CPU for 5 minutes:
+//	InvokesWhileThrottled([]int{2}, peernetwork.ResourceLimits{CPUPercent: 10}, SleepTimeMinutes(5), 10)
+// Every round sends invokesPerRound invokes and then checks the chain height of the other running peers grew,
+// i.e. consensus keeps progressing. Afterwards the peers are unthrottled and must catch up with the others
+// (within CHCO2_WAIT_CATCHUP_BLOCKS blocks, or fully if negative). Failures are recorded as chain height failures.
+func (s *Scenario) InvokesWhileThrottled(peerNums []int, limits peernetwork.ResourceLimits, duration time.Duration, invokesPerRound int) {
+	if !s.beginStep("InvokesWhileThrottled") { return }
+	defer s.endStep()
+	roundTime := SleepTimeSeconds(30)
+	stepName := fmt.Sprintf("INVOKES while %v throttled (%s) for %s", peerNums, limits.String(), duration)
+	fmt.Println("\n" + stepName)
+	s.ThrottlePeers(peerNums, limits)
+	lastHeight := s.chainHeightOfOtherPeers(peerNums)
+	for deadline := time.Now().Add(duration); time.Now().Before(deadline); {
+		s.Invokes(invokesPerRound)
+		if remaining := deadline.Sub(time.Now()); remaining < roundTime {
+			time.Sleep(remaining)
+		} else {
+			time.Sleep(roundTime)
+		}
+		height := s.chainHeightOfOtherPeers(peerNums)
+		fmt.Println("InvokesWhileThrottled(): chain height of the other peers: " + strconv.Itoa(height))
+		if height <= lastHeight {
+			fmt.Println("InvokesWhileThrottled(): ERROR: consensus did not progress while peers were throttled")
+			s.handleChainHeightFailure(stepName + ": consensus did not progress")
+		}
+		lastHeight = height
+	}
+	s.UnthrottlePeers(peerNums)
+
+	withinBlocks := s.CatchUpBlocks
+	if withinBlocks < 0 { withinBlocks = 0 }
+	for _, peerNum := range peerNums {
+		if peerNum >= len(s.MyNetwork.Peers) { continue }
+		err := peernetwork.WaitForPeerCaughtUp(s.MyNetwork, s.container(peerNum), withinBlocks, SleepTimeMinutes(2))
+		if err != nil {
+			fmt.Println("InvokesWhileThrottled(): ERROR: " + err.Error())
+			s.handleChainHeightFailure(stepName + ": " + threadutil.GetPeer(peerNum) + " did not catch up")
+		}
+	}
+}
+
+// Returns the highest chain height of the running peers that are not in peerNums.
+func (s *Scenario) chainHeightOfOtherPeers(peerNums []int) int {
+	maxHeight := 0
+	for n := 0; n < s.NumberOfPeersInNetwork && n < len(s.MyNetwork.Peers); n++ {
+		excluded := false
+		for _, peerNum := range peerNums { if n == peerNum { excluded = true } }
+		if excluded || !peerIsRunning(n, s.MyNetwork) { continue }
+		if ht, ok := peernetwork.ProbeChainHeight(peernetwork.PeerCopy(&s.MyNetwork.Peers[n])); ok && ht > maxHeight {
+			maxHeight = ht
+		}
+	}
+	return maxHeight
+}
+
+func (s *Scenario) TimeTrack(start time.Time, name string) {
 	//fmt.Println("+++ENTERED_TIMETRACK+++")
+	s.reportMutex.Lock()
+	defer s.reportMutex.Unlock()
+	if !s.TestInProgress() { return }		// already reported, e.g. when the test was interrupted
         elapsed := time.Since(start)
         preStr := ""
         postStr := ""
         myOutStr := fmt.Sprintf(" %s (Q_Pass=%t CH_Pass=%t, Enforce Q=%t CH=%t, MustMatch Q=%t CH=%t AllVP=%t) [%s]  ",
-			 		name, queryTestsPass, chainHeightTestsPass, EnforceQueryTestsPass, EnforceChainHeightTestsPass,
-					QsMustMatchExpected, CHsMustMatchExpected, AllRunningNodesMustMatch, elapsed)
-	if !RanToCompletion {
+			 		name, s.queryTestsPass, s.chainHeightTestsPass, s.EnforceQueryTestsPass, s.EnforceChainHeightTestsPass,
+					s.QsMustMatchExpected, s.CHsMustMatchExpected, s.AllRunningNodesMustMatch, elapsed)
+	if s.Interrupted() {
+		reached := s.lastStep()
+		myOutStr += "INTERRUPTED (" + s.interruptedBy + ") at: " + reached + "  "
+		s.recordFailure("INTERRUPTED (" + s.interruptedBy + ") at: " + reached)
+		s.testReport.Interrupted = s.interruptedBy + " at: " + reached
+	}
+	if !s.RanToCompletion || s.Interrupted() {
 			// NOTE: If the user types ^C to abort the script and stop running the test,
 			// execution should still get here and report a result.
 			// A good indicator of an interrupted test is a run time much shorter than usual.
         		preStr += fmt.Sprintf("ABORTED")
         		postStr = fmt.Sprintf("--------------------")
 	} else {
-		if ( (!queryTestsPass && EnforceQueryTestsPass) || (!chainHeightTestsPass && EnforceChainHeightTestsPass) ) {
+		if ( (!s.queryTestsPass && s.EnforceQueryTestsPass) || (!s.chainHeightTestsPass && s.EnforceChainHeightTestsPass) ) {
         		preStr += fmt.Sprintf("FAILED")
         		postStr = fmt.Sprintf("!!!!!!!!!!!!!!!!!!!!")
 		} else {
         		preStr += fmt.Sprintf("PASSED")
 		}
 	}
+	s.TestResult = preStr
 	fmt.Println("\n" + preStr + myOutStr + postStr + "\n")
-	fmt.Fprintln(Writer, preStr + myOutStr + postStr)
-	Writer.Flush()
+	fmt.Fprintln(s.Writer, preStr + myOutStr + postStr)
+	s.Writer.Flush()
+
+	// capture the artifacts before restoring the network, while it is still in the state that failed
+	s.LogStep("END " + preStr + " " + name)
+	if s.ArtifactsMode == "ALWAYS" || (s.ArtifactsMode == "ONFAIL" && preStr != "PASSED") {
+		s.CaptureArtifacts()
+	}
+	s.WriteResults(preStr)
 
-	restore_all()
+	s.restore_all()
+	s.healthMonitor.Stop()
+	s.healthMonitor = nil
+
+	s.endTest()
+}
+
+// Opens the output summary file (creating it if needed), to which the tests append their results, and sets
+// Writer to it. The caller closes the file when the test ends.
+func (s *Scenario) OpenOutputSummary() *os.File {
+	osFile, err := os.OpenFile(OutputSummaryFileName, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	Check(err)
+	s.Writer = bufio.NewWriter(osFile)
+	return osFile
 }
 
-func restore_all() {
+// Records a test step with its time, to line it up with the peer logs in the artifacts.
+func (s *Scenario) LogStep(description string) {
+	s.testMutex.Lock()
+	s.stepLog = append(s.stepLog, peernetwork.ArtifactTime(time.Now()) + "  " + strings.TrimSpace(description))
+	s.testMutex.Unlock()
+}
+
+// Captures the peer and caserver logs, container inspect output, chain heights, peer state history and the
+// test steps into a new directory ArtifactsDir/<testname>_<time>, and sets ArtifactsPath to it.
+func (s *Scenario) CaptureArtifacts() {
+	testName := strings.TrimSuffix(filepath.Base(s.CurrentTestName), ".go")
+	s.ArtifactsPath = filepath.Join(s.ArtifactsDir, testName + "_" + time.Now().Format("20060102_150405"))
+	fmt.Println("CaptureArtifacts(): saving artifacts in " + s.ArtifactsPath)
+	if _, err := peernetwork.CaptureArtifacts(s.MyNetwork, s.ArtifactsPath); err != nil {
+		fmt.Println("CaptureArtifacts(): WARNING: " + err.Error())
+		if _, statErr := os.Stat(s.ArtifactsPath); statErr != nil { return }
+	}
+	s.testMutex.Lock()
+	steps := strings.Join(s.stepLog, "\n") + "\n"
+	s.testMutex.Unlock()
+	if err := ioutil.WriteFile(filepath.Join(s.ArtifactsPath, "steps.log"), []byte(steps), 0644); err != nil {
+		fmt.Println("CaptureArtifacts(): WARNING: could not write steps.log: " + err.Error())
+	}
+	if len(s.diagnoses) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(s.ArtifactsPath, "diagnosis.txt"), []byte(strings.Join(s.diagnoses, "\n")), 0644); err != nil {
+			fmt.Println("CaptureArtifacts(): WARNING: could not write diagnosis.txt: " + err.Error())
+		}
+	}
+	s.analyzePeerLogs(s.ArtifactsPath)
+}
+
+// Extracts the consensus events from the captured peer logs, lines them up with the test steps, writes them
+// to consensus-events.txt in the artifacts directory, and prints which peer was primary and when state transfer happened.
+func (s *Scenario) analyzePeerLogs(dir string) {
+	var eventLists [][]peerlogs.Event
+	for i := range s.MyNetwork.Peers {
+		name := s.MyNetwork.Peers[i].PeerDetails["name"]
+		events, err := peerlogs.ParseFile(name, filepath.Join(dir, name + ".log"), s.NumberOfValidatingPeers)
+		if err != nil { continue }
+		eventLists = append(eventLists, events)
+	}
+	if len(eventLists) == 0 { return }
+	events := peerlogs.Merge(eventLists...)
+	steps, _ := peerlogs.ParseStepsFile(filepath.Join(dir, "steps.log"))
+	f, err := os.Create(filepath.Join(dir, "consensus-events.txt"))
+	if err != nil {
+		fmt.Println("CaptureArtifacts(): WARNING: could not write consensus-events.txt: " + err.Error())
+		return
+	}
+	defer f.Close()
+	peerlogs.WriteReport(f, steps, events)
+
+	for _, ev := range peerlogs.Filter(events, peerlogs.NewView, peerlogs.StateTransferStart, peerlogs.StateTransferDone, peerlogs.Panic) {
+		fmt.Println("CONSENSUS EVENT: " + ev.Time.UTC().Format("15:04:05.000") + " " + ev.Peer + " " + ev.Kind + " " + ev.Line)
+	}
+	if view, primary, ok := peerlogs.CurrentView(events); ok {
+		primaryName := "replica " + strconv.Itoa(primary)
+		if peerNum := peernetwork.PeerOfReplica(s.MyNetwork, primary); peerNum >= 0 { primaryName = threadutil.GetPeer(peerNum) }
+		fmt.Println("CONSENSUS EVENT: last view " + strconv.Itoa(view) + ", primary " + primaryName)
+	}
+}
+
+func (s *Scenario) restore_all() {
 
 //	// This is what we really want to do:    docker ps -aq -f status=paused | xargs docker unpause  1>/dev/null 2>&1
 //	// because docker cannot stop or kill or rm containers that are paused, for some reason.
@@ -1149,60 +1661,95 @@ func restore_all() {
 //		// log.Fatal(err)
 //	}
 
-	for i :=0 ; i < NumberOfPeersInNetwork ; i++ {
-		if (MyNetwork.Peers[i].State == peernetwork.PAUSED) {
+	for i :=0 ; i < s.NumberOfPeersInNetwork ; i++ {
+		if (peernetwork.PeerStateOf(s.MyNetwork, i) == peernetwork.PAUSED) || peernetwork.ContainerStatus(s.container(i)) == "paused" {
 			// DO NOT leave any nodes paused
 			// fmt.Println("restore_all(): unpause " + strconv.Itoa(i)) 
 			// peernetwork.UnpausePeerLocal(MyNetwork, strconv.Itoa(i))
 			// fmt.Println("restore_all(): unpause peer" + strconv.Itoa(i)) 
 			// peernetwork.UnpausePeerLocal(MyNetwork, "peer" + strconv.Itoa(i))
-			fmt.Println("restore_all(): unpause peer " + threadutil.GetPeer(i)) 
-			peernetwork.UnpausePeerLocal(MyNetwork, threadutil.GetPeer(i))
+			fmt.Println("restore_all(): unpause peer " + s.container(i)) 
+			peernetwork.UnpausePeerLocal(s.MyNetwork, s.container(i))
+		}
+	}
+
+	// After unpausing (a paused container cannot be entered), DO NOT leave any links dropped or degraded, or peers throttled
+	if len(s.nodeController().DroppedLinks()) > 0 {
+		fmt.Println("restore_all(): heal partitions")
+		s.nodeController().Heal(s.MyNetwork)
+	}
+	for _, peer := range s.nodeController().DegradedPeers() {
+		fmt.Println("restore_all(): restore link quality of " + peer)
+		s.nodeController().Restore(s.MyNetwork, peer)
+	}
+	for _, peer := range s.nodeController().ThrottledPeers() {
+		fmt.Println("restore_all(): unthrottle " + peer)
+		s.nodeController().Unthrottle(s.MyNetwork, peer)
+	}
+
+	// DO NOT leave any peers (stopped, killed, or with a ledger wiped) or the caserver down, so the next test of a
+	// suite starts from a healthy network, even after an interrupted test. Decommissioned peers stay removed.
+	for i := 0; i < s.NumberOfPeersInNetwork && i < len(s.MyNetwork.Peers); i++ {
+		peer := s.container(i)
+		if peernetwork.PeerStateOf(s.MyNetwork, i) == peernetwork.DECOMMISSIONED { continue }
+		status := peernetwork.ContainerStatus(peer)
+		if status == "exited" || status == "created" {
+			fmt.Println("restore_all(): restart peer " + peer)
+			peernetwork.StartPeerLocal(s.MyNetwork, peer)
+		}
+		delete(s.peerDisruptedBy, i)
+		delete(s.stoppedWhilePrimary, i)
+	}
+	if s.Security {
+		ca := peernetwork.CAContainer(s.MyNetwork)
+		status := peernetwork.ContainerStatus(ca)
+		if status == "exited" || status == "created" {
+			fmt.Println("restore_all(): restart the caserver " + ca)
+			peernetwork.StartPeerLocal(s.MyNetwork, ca)
 		}
-//		if (MyNetwork.Peers[i].State == peernetwork.STOPPED) {
-//			// do not leave any nodes stopped
-//			peernetwork.StartPeerLocal(MyNetwork, strconv.Itoa(i))
-//		}
 	}
 }
 
 func clean_up() {
 }
 
-func chco2_QueryOnHost(apiArgs00 []string, argsA []string, argsB []string, resAI *int, resBI *int)  {
-	resA, _ := chaincode.QueryOnHost(apiArgs00, argsA)
-	resB, _ := chaincode.QueryOnHost(apiArgs00, argsB)
-	*resAI, _ = strconv.Atoi(resA)
-	*resBI, _ = strconv.Atoi(resB)
+// Returns the values of the keys on the peer.
+func (s *Scenario) queryPeer(ccName string, nodename string, keys []string) []string {
+	apiArgs := []string{ccName, "query", nodename}
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i], _ = s.client().QueryOnHost(apiArgs, []string{key})
+	}
+	return values
 }
 
 // PREcondition: peer node must be running
-func doInvoke(currA *int, currB *int, num_invokes int, nodename string)  {
+func (s *Scenario) doInvoke(num_invokes int, nodename string)  {
 
-        if Verbose { fmt.Println("doInvoke() calling chaincode.InvokeOnPeer " + strconv.Itoa(num_invokes) + " times on peer " + nodename) }
+        if s.Verbose { fmt.Println("doInvoke() calling chaincode.InvokeOnPeer " + strconv.Itoa(num_invokes) + " times on peer " + nodename) }
 
 	// We sleep now only if we have consensus and the invokes can be processed now;
 	// otherwise we will sleep when we empty the queue later when consensus is resumed...
 	// Get the sleep time based on number of transactions.
 
-	mustSleep := enoughPeersRunningForConsensus()
+	mustSleep := s.enoughPeersRunningForConsensus()
 
 	// However, when in Z (not Local) network, the messaging delays (REST handshakes) are more than enough
 	// to slow down our transaction rate to something between 1 and 5 per sec, which is
 	// something the peer network can easily handle...
 
-	if !localNetwork { mustSleep = false }
+	if !s.localNetwork { mustSleep = false }
 
   // 9/15/2016: For now, since performance timing of theses tests indicates I cannot run more than 11 transactions per second
   // on local environment (and 2 tps on Z/HSBN), let's just skip the sleeps because the peers network will certainly be able to keep up with that!
   mustSleep = false
 
-	invArgs := []string{"a", "b", "1"}
-	iAPIArgs := []string{"example02", "invoke", nodename}
+	iAPIArgs := []string{s.oracle.Chaincode(), "invoke", nodename}
 	for j:=1; j <= num_invokes; j++ {
-		_, _ = chaincode.InvokeOnPeer(iAPIArgs, invArgs)
-		(*currA)--
-		(*currB)++
+		if s.Interrupted() { return }		// stop issuing transactions
+		invArgs := s.oracle.Invoke()		// the model counts the invoke as sent, like the peer queues it
+		txId, _ := s.client().InvokeOnPeer(iAPIArgs, invArgs)
+		s.chain.Submitted(txId, false)	// an invoke the peer did not accept has no UUID, and is not expected in the chain
 		// if Verbose {
 			// Show some progress...
 			// Print . for 10 invokes; Print + for 100 invokes; Print newline after 1000 invokes on this peer.
@@ -1221,40 +1768,41 @@ func doInvoke(currA *int, currB *int, num_invokes int, nodename string)  {
 
 	//If we don't sleep above, as we go, then sleep just once here (for the full/longer time)
 	if mustSleep {
-		if (Verbose) { fmt.Println("Sleep approx " + strconv.Itoa(num_invokes/TransPerSecRate) + "secs after sending " + strconv.Itoa(num_invokes) + " invokes ...") }
-		time.Sleep( sleepTimeForTrans(num_invokes) )
-	} else { time.Sleep( time.Duration(batchtimeout)*time.Second ) } 	// sleep at least 2 secs, to give time for the transactions to be batched
+		if (s.Verbose) { fmt.Println("Sleep approx " + strconv.Itoa(num_invokes/TransPerSecRate) + "secs after sending " + strconv.Itoa(num_invokes) + " invokes ...") }
+		time.Sleep( s.sleepTimeForTrans(num_invokes) )
+	} else { time.Sleep( time.Duration(s.batchtimeout)*time.Second ) } 	// sleep at least 2 secs, to give time for the transactions to be batched
 									// and sent through (so any queries following immediately would be more likely to work)
 }
 
-func validPeerQueryResults(a int, b int, resA int, resB int, nodename string) bool {
+func (s *Scenario) validPeerQueryResults(keys []string, expected []string, actual []string, nodename string) bool {
 	var passfail bool
 	passfail = true
 	valueStr := ""
-	if !enoughPeersRunningForConsensus() {
-		if ( EnforceQueryTestsPass ) {	// if we care, print status
-        		if (Verbose) {
-				valueStr = fmt.Sprintf("SKIPPED QUERY VALIDATION on %s: not enough peer nodes running for consensus. EXPECTED/ACTUAL: A=%d/%d, B=%d/%d.", nodename, a, resA, b, resB)
+	label := strings.Join(keys, "/")
+	if !s.enoughPeersRunningForConsensus() {
+		if ( s.EnforceQueryTestsPass ) {	// if we care, print status
+        		if (s.Verbose) {
+				valueStr = fmt.Sprintf("SKIPPED QUERY VALIDATION on %s: not enough peer nodes running for consensus. EXPECTED/ACTUAL: %s=%s / %s.", nodename, label, strings.Join(expected, "/"), strings.Join(actual, "/"))
 				fmt.Println(valueStr)	// print to stdout only
 			}
 		}
-	} else if (a == resA) && (b == resB) {
-		if ( EnforceQueryTestsPass ) {	// if we care, print status
-        		if (Verbose) {
-				valueStr = fmt.Sprintf("PASS on %s: QUERY RESULTS MATCH expected values: A=%d, B=%d.", nodename, a, b)
+	} else if s.oracle.Matches(expected, actual) {
+		if ( s.EnforceQueryTestsPass ) {	// if we care, print status
+        		if (s.Verbose) {
+				valueStr = fmt.Sprintf("PASS on %s: QUERY RESULTS MATCH expected values: %s=%s.", nodename, label, strings.Join(expected, "/"))
 				fmt.Println(valueStr)	// print to stdout only
 			}
 		}
 	} else {
 		passfail = false
-		if ( EnforceQueryTestsPass ) {	// if we care, print status
-			valueStr = fmt.Sprintf("FAIL on %s: QUERY RESULTS: EXPECTED/ACTUAL: A=%d/%d, B=%d/%d. *****FAIL*****", nodename, a, resA, b, resB)
-        		if (Verbose) {
+		if ( s.EnforceQueryTestsPass ) {	// if we care, print status
+			valueStr = fmt.Sprintf("FAIL on %s: QUERY RESULTS: EXPECTED/ACTUAL: %s=%s / %s. *****FAIL*****", nodename, label, strings.Join(expected, "/"), strings.Join(actual, "/"))
+        		if (s.Verbose) {
 				fmt.Println(valueStr)
 			}
-			if ( Stop_on_error ) {
-                		fmt.Fprintln(Writer, valueStr)	// print to results file too, since this is the reason we will be stopping shortly
-        			Writer.Flush()
+			if ( s.Stop_on_error ) {
+                		fmt.Fprintln(s.Writer, valueStr)	// print to results file too, since this is the reason we will be stopping shortly
+        			s.Writer.Flush()
 			}
 		}
 	}
@@ -1346,85 +1894,73 @@ func Check(e error) {
         }
 }
 
-func validChainHeights() bool {
-
-	// The expected block chain height is always the same as our currHeightCount.
-	// Note: We increment our currCH only when consensus and transactions are
-	// processed and bundled/batched into the network. Otherwise, we queue transactions
-	// and defer calculation/incrementation of our currCH until later.
-	// So our currHeightCount should always match whatever we get/query, even if
-	// some more invokes had been sent to a good peer while the network lacked enough
-	// nodes for Consensus and therefore could not process them.
-
-	testStatus  := true
-	matchedCount := 0
-	var ht []int
-	ht = make([]int, NumberOfPeersInNetwork)
-
-	runningPeerCounter := 0
-        for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        	if peerIsRunning(peerNum,MyNetwork) {
-			ht[peerNum], _ = chaincode.GetChainHeight(threadutil.GetPeer(peerNum))
-			if (ht[peerNum] == currCH)  { matchedCount++ }
-			runningPeerCounter++
-		} else { ht[peerNum] = 0 }
-	}
+// Reads the transactions of a block of a peer chain, for the chain oracle.
+func (s *Scenario) blockTransactions(peer string, block int) ([]string, error) {
+	b, err := s.readBlock(peer, block)
+	if err != nil { return nil, err }
+	uuids := make([]string, len(b.TransactionList))
+	for i, tx := range b.TransactionList { uuids[i] = tx.Uuid }
+	return uuids, nil
+}
 
-	if (runningPeerCounter >= NumberOfPeersNeededForConsensus) && ((matchedCount < NumberOfPeersNeededForConsensus) || (AllRunningNodesMustMatch && (matchedCount < getNumberOfPeersRunning()))) {
-		//handle failure
-		testStatus = false
-               	myStr := fmt.Sprintf("FAILED CHAIN HEIGHT TEST: required peers do NOT match expected ChainHeight (%d).  Actual CH: ", currCH)
-        	for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        		if peerIsRunning(peerNum,MyNetwork) { myStr += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum]) }
-		}
-               	myStr += fmt.Sprintf("!!!!!!!!!!")
-               	fmt.Println(myStr)					// always print to stdout
-		if (Stop_on_error && EnforceChainHeightTestsPass) {	// if we care, print to results file too
-                	fmt.Fprintln(Writer, myStr)
-			Writer.Flush()
-		}
-	} else {
-		testStatus = true
-		myStr := fmt.Sprintf("PASSED CHAIN HEIGHT TEST: Expected height (%d) matched on enough/appropriate Peers. Actual CH: ", currCH)
-        	for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        		if peerIsRunning(peerNum,MyNetwork) { myStr += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum]) }
-		}
-                fmt.Println(myStr)					// always print to stdout
-		if (Stop_on_error && EnforceChainHeightTestsPass) {	// if we care, print status in results file too
-                	fmt.Fprintln(Writer, myStr)
-			Writer.Flush()
+// the chain height of each running peer, with the problems the chain oracle found in its chain, e.g. "vp1(12) vp2(11: 2 missing) "
+func (s *Scenario) chainHeightsString(ht []int, checks []ChainCheck) string {
+	str := ""
+	for peerNum := 0; peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+		if !peerIsRunning(peerNum,s.MyNetwork) { continue }
+		if problems := checks[peerNum].Problems(); problems != "" {
+			str += fmt.Sprintf("%s(%d: %s) ", threadutil.GetPeer(peerNum), ht[peerNum], problems)
+		} else {
+			str += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum])
 		}
-        }
-	return testStatus
+	}
+	return str
 }
 
-func validateAllChainHeights() bool {
+func (s *Scenario) validateAllChainHeights() bool {
 	testStatus 		:= true
 	enoughMatchExpectedCH 	:= true
 	allMatchExpectedCH 	:= true
 	allMatchEachOther 	:= true
 	consensusPossible 	:= true
 	consensusFound 		:= false
+	chainsValid 		:= true
 
 	//====================================================================================================================
-	// first get the chainheight from each peer node
+	// first get the chainheight from each peer node, and check its chain against the transactions we submitted:
+	// the expected chainheight (currCH) is the height of a chain with all the transactions processed so far
 
 	var ht []int
-	ht = make([]int, NumberOfPeersInNetwork)
+	ht = make([]int, s.NumberOfPeersInNetwork)
+	peers := make([]string, s.NumberOfPeersInNetwork)
+        for peerNum := 0; peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+        	if peerIsRunning(peerNum,s.MyNetwork) {
+			ht[peerNum], _ = s.chainHeight(peerNum)
+			peers[peerNum] = threadutil.GetPeer(peerNum)
+		} else { ht[peerNum] = 0 }
+	}
+	checks := s.chain.Check(peers, ht, s.blockTransactions)
+	for peerNum := range checks {
+		if peers[peerNum] != "" && checks[peerNum].Err == nil && checks[peerNum].Expected > s.currCH { s.currCH = checks[peerNum].Expected }
+	}
+
 	countMatchingExpectedValue := 0
 	runningPeerCounter := 0
-        for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        	if peerIsRunning(peerNum,MyNetwork) {
-			ht[peerNum], _ = chaincode.GetChainHeight(threadutil.GetPeer(peerNum))
-			if (ht[peerNum] == currCH)  { countMatchingExpectedValue++ } 
+        for peerNum := 0; peerNum < s.NumberOfPeersInNetwork; peerNum++ {
+        	if peers[peerNum] == "" { continue }
+		c := checks[peerNum]
+		// a chain may lag behind, missing its latest transactions; but it must not have wrong blocks
+		if c.Err != nil || len(c.Duplicates) > 0 || len(c.Oversized) > 0 || c.Unknown > 0 || c.Diverged > 0 { chainsValid = false }
+		if s.peerIsValidating(peerNum) {
+			if (ht[peerNum] == s.currCH && c.Missing == 0)  { countMatchingExpectedValue++ } 
 			runningPeerCounter++
-		} else { ht[peerNum] = 0 }
+		}
 	}
 
 	// Do the chainheights of all the running peers match the EXPECTED value? (STRICT mode, AllRunningNodesMustMatch)
 
 	if countMatchingExpectedValue < runningPeerCounter { allMatchExpectedCH = false }
-	if countMatchingExpectedValue < NumberOfPeersNeededForConsensus { enoughMatchExpectedCH = false }
+	if countMatchingExpectedValue < s.NumberOfPeersNeededForConsensus { enoughMatchExpectedCH = false }
 
 	//====================================================================================================================
 	// Determine whether "consensusFound" or "allMatchEachOther"
@@ -1436,22 +1972,22 @@ func validateAllChainHeights() bool {
 	//	(and may but not necessarily match the expected value)
 	//	(and there may be more or less than enough running nodes to reach consensus - although they ALL match)
 
-	numPeersRunning := getNumberOfPeersRunning() 
-	if (numPeersRunning < NumberOfPeersNeededForConsensus) {
+	numPeersRunning := s.getNumberOfValidatingPeersRunning() 
+	if (numPeersRunning < s.NumberOfPeersNeededForConsensus) {
 		consensusPossible = false
 	} else {
 		matchCounter := 0
-		matchStartPoints := numPeersRunning - NumberOfPeersNeededForConsensus + 1
-		for n := 0 ; (n <= NumberOfPeersInNetwork - NumberOfPeersNeededForConsensus) && (matchStartPoints > 0) && !consensusFound; n++ {
-        		if peerIsRunning(n,MyNetwork) {
+		matchStartPoints := numPeersRunning - s.NumberOfPeersNeededForConsensus + 1
+		for n := 0 ; (n < s.NumberOfPeersInNetwork) && (matchStartPoints > 0) && !consensusFound; n++ {
+        		if s.peerCountsForConsensus(n) {
 				// we will try n times to start and compare
 				matchCounter = 1
-				for i := n+1 ; (i < NumberOfPeersInNetwork) ; i++ {
-        				if peerIsRunning(i,MyNetwork) {
+				for i := n+1 ; (i < s.NumberOfPeersInNetwork) ; i++ {
+        				if s.peerCountsForConsensus(i) {
 						if (ht[n] == ht[i]) { matchCounter++ } else { allMatchEachOther = false }
 					}
 				}
-				if (matchCounter >= NumberOfPeersNeededForConsensus) { consensusFound = true }
+				if (matchCounter >= s.NumberOfPeersNeededForConsensus) { consensusFound = true }
 				matchStartPoints--
 			}
 		}
@@ -1461,10 +1997,8 @@ func validateAllChainHeights() bool {
 
 	myStr := fmt.Sprintf("")
 	if (!consensusPossible) {
-		myStr += fmt.Sprintf("SKIPPED CHAINHEIGHT VALIDATION: Only %d peer nodes running, but %d are required for consensus in this network of %d. Expected CH (%d). Actual CHs: ", numPeersRunning, NumberOfPeersNeededForConsensus, NumberOfPeersInNetwork, currCH)
-        	for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        		if peerIsRunning(peerNum,MyNetwork) { myStr += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum]) }
-		}
+		myStr += fmt.Sprintf("SKIPPED CHAINHEIGHT VALIDATION: Only %d peer nodes running, but %d are required for consensus in this network of %d. Expected CH (%d). Actual CHs: ", numPeersRunning, s.NumberOfPeersNeededForConsensus, s.NumberOfValidatingPeers, s.currCH)
+		myStr += s.chainHeightsString(ht, checks)
                 fmt.Println(myStr)					// always print to stdout
 	} else
 		// We have enough nodes for consensus. Use cases are the following.
@@ -1478,29 +2012,34 @@ func validateAllChainHeights() bool {
 		//   B. They do need to match expected CH value AND all do match, or,
 		//   C. They do need to match expected CH value AND enough for consensus match expected value (which is all that is required), or,
 		//   D. They do need to match expected CH value, but their value doesn't match the expected value.
+		// And in any case, no chain may have duplicate, oversized, unknown or diverging blocks.
 
-	if (allMatchEachOther || (!AllRunningNodesMustMatch && consensusFound)) && (!CHsMustMatchExpected || (allMatchExpectedCH || (enoughMatchExpectedCH && !AllRunningNodesMustMatch))) {
+	if chainsValid && (allMatchEachOther || (!s.AllRunningNodesMustMatch && consensusFound)) && (!s.CHsMustMatchExpected || (allMatchExpectedCH || (enoughMatchExpectedCH && !s.AllRunningNodesMustMatch))) {
 			// SUCCESS
-			myStr += fmt.Sprintf("PASSED CHAIN HEIGHT TEST: matches on enough/appropriate Peers. Expected CH (%d). Actual CHs: ", currCH)
-        		for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        			if peerIsRunning(peerNum,MyNetwork) { myStr += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum]) }
-			}
+			myStr += fmt.Sprintf("PASSED CHAIN HEIGHT TEST: matches on enough/appropriate Peers. Expected CH (%d). Actual CHs: ", s.currCH)
+			myStr += s.chainHeightsString(ht, checks)
                 	fmt.Println(myStr)					// always print to stdout
 
 	} else {
 			// FAILURE
 			testStatus = false
-               		myStr += fmt.Sprintf("FAILED CHAIN HEIGHT TEST: enough required peers do NOT match. Expected ChainHeight (%d). Actual CHs: ", currCH)
-        		for peerNum := 0; peerNum < NumberOfPeersInNetwork; peerNum++ {
-        			if peerIsRunning(peerNum,MyNetwork) { myStr += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht[peerNum]) }
+			if chainsValid {
+               			myStr += fmt.Sprintf("FAILED CHAIN HEIGHT TEST: enough required peers do NOT match. Expected ChainHeight (%d). Actual CHs: ", s.currCH)
+			} else {
+               			myStr += fmt.Sprintf("FAILED CHAIN HEIGHT TEST: the chains do NOT hold the submitted transactions exactly once. Expected ChainHeight (%d). Actual CHs: ", s.currCH)
 			}
+			myStr += s.chainHeightsString(ht, checks)
                		myStr += fmt.Sprintf("!!!!!!!!!!")
                		fmt.Println(myStr)					// always print to stdout
 	}
 
-	if (Stop_on_error && EnforceChainHeightTestsPass) {	// if we care, print status in results file too
-		fmt.Fprintln(Writer, myStr)
-		Writer.Flush()
+	status := ValidationPassed
+	if !consensusPossible { status = ValidationSkipped } else if !testStatus { status = ValidationFailed }
+	s.recordChainHeightResult(status, myStr, ht, checks)
+
+	if (s.Stop_on_error && s.EnforceChainHeightTestsPass) {	// if we care, print status in results file too
+		fmt.Fprintln(s.Writer, myStr)
+		s.Writer.Flush()
 	}
 	return testStatus
 }
@@ -0,0 +1,212 @@
+package chco2
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"obcsdk/chaincode"
+	"obcsdk/threadutil"
+)
+
+/*
+  When a query or chain height validation fails, chco2 diagnoses how the peers diverge: it reads the height
+  and the last DiagnosisBlocks blocks (state hash and transaction UUIDs) of each running peer, finds the first
+  block where a peer differs from the tallest chain, and which processed transactions are missing on which
+  peers, and tells for each peer whether it is in sync, lagging, lost transactions or forked. The diagnosis is
+  printed, in the diagnosis of each peer in the JSON results, and in diagnosis.txt with the artifacts: one
+  per failed step, e.g.
+
+	DIAGNOSIS of STEP 3: 84 transactions submitted, 84 processed; expected height 44; reference chain vp0
+	  vp0  height 44  in sync
+	  vp1  height 41  LAGGING 3 blocks behind vp0 (6 missing transactions are in other chains)
+	  vp2  height 44  FORKED at block 42 (state hash 1a2b3c4d, vp0 has 9f8e7d6c)
+	  vp3  height 43  LOST 2 transactions: 3f2a1b0c 77aa0e1d
+	  last blocks (#block state hash [transactions]):
+	  vp0  #42 9f8e7d6c [0f3e2d1c 8899aabb] #43 ...
+*/
+
+// the number of blocks, at the end of each chain, shown in a diagnosis
+const DiagnosisBlocks = 5
+
+// the first characters of a hash or UUID, enough to tell them apart in a diagnosis
+func short(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// Reads a block of a peer chain.
+func (s *Scenario) readBlock(peer string, block int) (chaincode.Block, error) {
+	return s.client().GetBlockByHost(peer, block)
+}
+
+/*
+  Diagnoses the divergence of the peers after the failure of a step, once per step (both the query and the
+  chain height validations of a step may fail).
+*/
+func (s *Scenario) diagnose(stepName string) {
+	if s.Interrupted() || s.chain == nil || stepName == s.diagnosedStep {
+		return
+	}
+	s.diagnosedStep = stepName
+
+	ht := make([]int, s.NumberOfPeersInNetwork)
+	peers := make([]string, s.NumberOfPeersInNetwork)
+	reference := -1
+	for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+		if peerIsRunning(n, s.MyNetwork) {
+			ht[n], _ = s.chainHeight(n)
+			peers[n] = threadutil.GetPeer(n)
+			if reference < 0 || ht[n] > ht[reference] {
+				reference = n
+			}
+		}
+	}
+	if reference < 0 {
+		return
+	}
+	checks := s.chain.Check(peers, ht, s.blockTransactions)
+
+	// the last blocks of each peer, and the same blocks of the reference chain to compare them
+	blocks := make(map[int]map[int]chaincode.Block)
+	read := func(n int, from int, to int) {
+		if blocks[n] == nil {
+			blocks[n] = make(map[int]chaincode.Block)
+		}
+		if from < 1 {
+			from = 1
+		}
+		for k := from; k < to; k++ {
+			if _, found := blocks[n][k]; found {
+				continue
+			}
+			if b, err := s.readBlock(peers[n], k); err == nil {
+				blocks[n][k] = b
+			}
+		}
+	}
+	for n := range peers {
+		if peers[n] != "" {
+			read(n, ht[n]-DiagnosisBlocks, ht[n])
+			read(reference, ht[n]-DiagnosisBlocks, ht[n])
+		}
+	}
+
+	submitted, processed := s.chain.Counts()
+	text := fmt.Sprintf("DIAGNOSIS of %s: %d transactions submitted, %d processed; expected height %d; reference chain %s\n",
+		stepName, submitted, processed, s.currCH, peers[reference])
+	var summary []string
+	for n := range peers {
+		if peers[n] == "" {
+			continue
+		}
+		verdict := s.peerVerdict(n, reference, peers, ht, checks[n], blocks)
+		text += fmt.Sprintf("  %-4s height %d  %s\n", peers[n], ht[n], verdict)
+		if verdict != "in sync" {
+			summary = append(summary, peers[n]+" "+strings.ToLower(strings.SplitN(verdict, " (", 2)[0]))
+		}
+		if step := s.currentStepResult(); step != nil && step.Name == stepName && n < len(step.Peers) {
+			step.Peers[n].Diagnosis = verdict
+		}
+	}
+	text += "  last blocks (#block state hash [transactions]):\n"
+	for n := range peers {
+		if peers[n] == "" {
+			continue
+		}
+		var nums []int
+		for k := range blocks[n] {
+			if k >= ht[n]-DiagnosisBlocks {
+				nums = append(nums, k)
+			}
+		}
+		sort.Ints(nums)
+		line := fmt.Sprintf("  %-4s", peers[n])
+		for _, k := range nums {
+			var uuids []string
+			for _, tx := range blocks[n][k].TransactionList {
+				uuids = append(uuids, short(tx.Uuid))
+			}
+			line += fmt.Sprintf(" #%d %s [%s]", k, short(blocks[n][k].StateHash), strings.Join(uuids, " "))
+		}
+		text += line + "\n"
+	}
+
+	fmt.Println(text)
+	s.diagnoses = append(s.diagnoses, text)
+	if len(summary) == 0 {
+		summary = append(summary, "all peers in sync")
+	}
+	s.LogStep("DIAGNOSIS: " + strings.Join(summary, ", "))
+}
+
+// What happened to the chain of peer n: in sync, lagging, lost transactions or forked (and other chain problems).
+func (s *Scenario) peerVerdict(n int, reference int, peers []string, ht []int, check ChainCheck, blocks map[int]map[int]chaincode.Block) string {
+	if check.Err != nil {
+		return "UNREADABLE: " + check.Err.Error()
+	}
+	var verdicts []string
+
+	forked := check.Diverged
+	hashes := ""
+	if n != reference {
+		var nums []int
+		for k := range blocks[n] {
+			nums = append(nums, k)
+		}
+		sort.Ints(nums)
+		for _, k := range nums {
+			refBlock, found := blocks[reference][k]
+			if !found {
+				continue
+			}
+			differs := k
+			if blocks[n][k].PreviousBlockHash != refBlock.PreviousBlockHash {
+				differs = k - 1 // the hash of the block before is in this one
+			} else if blocks[n][k].StateHash == refBlock.StateHash {
+				continue
+			}
+			if forked == 0 || differs < forked {
+				forked = differs
+				hashes = fmt.Sprintf(" (state hash %s, %s has %s)", short(blocks[n][k].StateHash), peers[reference], short(refBlock.StateHash))
+			}
+			break
+		}
+	}
+	if forked > 0 {
+		verdicts = append(verdicts, "FORKED at block "+strconv.Itoa(forked)+hashes)
+	}
+
+	var lost []string
+	elsewhere := 0
+	for _, uuid := range check.MissingIDs {
+		if peer, _ := s.chain.Locate(uuid); peer != "" {
+			elsewhere++
+		} else {
+			lost = append(lost, short(uuid))
+		}
+	}
+	if len(lost) > 0 {
+		more := ""
+		if len(lost) > 5 {
+			more = " ..."
+			lost = lost[:5]
+		}
+		verdicts = append(verdicts, fmt.Sprintf("LOST %d transactions: %s%s", check.Missing-elsewhere, strings.Join(lost, " "), more))
+	}
+	if forked == 0 && (ht[n] < ht[reference] || elsewhere > 0) {
+		verdicts = append(verdicts, fmt.Sprintf("LAGGING %d blocks behind %s (%d missing transactions are in other chains)", ht[reference]-ht[n], peers[reference], elsewhere))
+	}
+
+	other := ChainCheck{Duplicates: check.Duplicates, Oversized: check.Oversized, Unknown: check.Unknown}
+	if problems := other.Problems(); problems != "" {
+		verdicts = append(verdicts, problems)
+	}
+	if len(verdicts) == 0 {
+		return "in sync"
+	}
+	return strings.Join(verdicts, "; ")
+}
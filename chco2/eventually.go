@@ -0,0 +1,184 @@
+package chco2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/peernetwork"
+	"obcsdk/threadutil"
+)
+
+/*
+  Eventually assertions poll the peers until a condition holds or the timeout expires, rather than sleeping
+  a computed time and querying once: a scenario asserts the convergence it expects, e.g. after restarting a
+  peer
+
+	chco2.RestartPeers([]int{3})
+	chco2.Invokes(chco2.InvokesRequiredForCatchUp)
+	chco2.EventuallyHeightsEqual(nil, chco2.SleepTimeMinutes(2))
+
+  and learns how long it took: each assertion prints it, and records it in the convergence of the JSON
+  results (a testcase of the JUnit ones). An assertion that times out fails the test, like a failed query
+  step; one that cannot hold, because not enough peers are running for consensus, is skipped.
+*/
+
+// Polls until the chain heights of the peers (all the running peers if peerNums is empty) are equal.
+func (s *Scenario) EventuallyHeightsEqual(peerNums []int, timeout time.Duration) bool {
+	if len(peerNums) == 0 {
+		for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+			if peerIsRunning(n, s.MyNetwork) {
+				peerNums = append(peerNums, n)
+			}
+		}
+	}
+	var names []string
+	for _, peerNum := range peerNums {
+		names = append(names, threadutil.GetPeer(peerNum))
+	}
+	assertion := "EventuallyHeightsEqual(" + strings.Join(names, ",") + ")"
+	return s.eventually(assertion, timeout, false, func() (bool, string) {
+		equal := true
+		state := ""
+		height := -1
+		for _, peerNum := range peerNums {
+			ht, err := s.chainHeight(peerNum)
+			if err != nil || ht <= 0 {
+				equal = false
+			} else if height < 0 {
+				height = ht
+			} else if ht != height {
+				equal = false
+			}
+			state += fmt.Sprintf("%s(%d) ", threadutil.GetPeer(peerNum), ht)
+		}
+		return equal, state
+	})
+}
+
+// Polls until the query of the key of the chaincode on the peer returns the value.
+func (s *Scenario) EventuallyQueryEquals(peerNum int, key string, value string, timeout time.Duration) bool {
+	peer := threadutil.GetPeer(peerNum)
+	assertion := "EventuallyQueryEquals(" + peer + ", " + key + "=" + value + ")"
+	return s.eventually(assertion, timeout, true, func() (bool, string) {
+		actual := s.queryPeer(s.oracle.Chaincode(), peer, []string{key})
+		return matchValues([]string{value}, actual), fmt.Sprintf("%s: %s=%s", peer, key, actual[0])
+	})
+}
+
+/*
+  Polls until enough validating peers for consensus agree: they have the same chain height, and the same
+  values of the oracle keys, which must be the expected ones if QsMustMatchExpected. Skipped if not enough
+  peers for consensus are running.
+*/
+func (s *Scenario) EventuallyAtLeastConsensusAgree(timeout time.Duration) bool {
+	assertion := "EventuallyAtLeastConsensusAgree(" + strconv.Itoa(s.NumberOfPeersNeededForConsensus) + " of " + strconv.Itoa(s.getNumberOfValidatingPeersRunning()) + ")"
+	if !s.enoughPeersRunningForConsensus() {
+		myStr := fmt.Sprintf("SKIPPED %s: Only %d peer nodes running, but %d are required for consensus in this network of %d.", assertion, s.getNumberOfValidatingPeersRunning(), s.NumberOfPeersNeededForConsensus, s.NumberOfValidatingPeers)
+		fmt.Println(myStr)
+		s.LogStep(myStr)
+		s.recordConvergence(ValidationSkipped, assertion, 0, timeout, myStr)
+		return false
+	}
+	return s.eventually(assertion, timeout, true, func() (bool, string) {
+		agreeing, state := s.agreeingPeers()
+		return agreeing >= s.NumberOfPeersNeededForConsensus, state
+	})
+}
+
+/*
+  Polls cond until it holds or the timeout expires, and reports the outcome: a failure is a query failure if
+  query, else a chain height failure. cond returns whether it holds, and what it found, for the messages.
+*/
+func (s *Scenario) eventually(assertion string, timeout time.Duration, query bool, cond func() (bool, string)) bool {
+	if !s.beginStep(assertion) {
+		return false
+	}
+	defer s.endStep()
+	fmt.Println("\n" + assertion + ": polling the peers, for up to " + timeout.String())
+	s.LogStep(assertion + ", timeout " + timeout.String())
+	holds := false
+	state := ""
+	elapsed, err := peernetwork.WaitUntil(assertion, timeout, func() bool {
+		if s.Interrupted() {
+			return true // stop polling; the test is being aborted
+		}
+		holds, state = cond()
+		return holds
+	})
+	if s.Interrupted() {
+		return false
+	}
+	if err == nil && holds {
+		myStr := fmt.Sprintf("PASSED %s after %s: %s", assertion, elapsed.String(), state)
+		fmt.Println(myStr)
+		s.LogStep(myStr)
+		s.recordConvergence(ValidationPassed, assertion, elapsed, timeout, myStr)
+		return true
+	}
+	myStr := fmt.Sprintf("FAILED %s: timed out after %s: %s!!!!!!!!!!", assertion, timeout.String(), state)
+	fmt.Println(myStr)
+	s.recordConvergence(ValidationFailed, assertion, elapsed, timeout, myStr)
+	if query {
+		s.handleQueryFailure(assertion)
+	} else {
+		s.handleChainHeightFailure(assertion)
+	}
+	return false
+}
+
+/*
+  Returns the number of running validating peers in the largest group that agree on the chain height and the
+  values of the oracle keys (the expected values, if QsMustMatchExpected), and what each running peer has.
+*/
+func (s *Scenario) agreeingPeers() (int, string) {
+	keys := s.oracle.Keys(s.qtrans)
+	expected := s.oracle.Expected(s.qtrans)
+	groups := make(map[string]int)
+	var states []string
+	for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+		if !peerIsRunning(n, s.MyNetwork) {
+			continue
+		}
+		peer := threadutil.GetPeer(n)
+		values := s.queryPeer(s.oracle.Chaincode(), peer, keys)
+		ht, _ := s.chainHeight(n)
+		states = append(states, fmt.Sprintf("%s(%s, %d)", peer, strings.Join(values, "/"), ht))
+		if !s.peerIsValidating(n) || ht <= 0 || (s.QsMustMatchExpected && !s.oracle.Matches(expected, values)) {
+			continue
+		}
+		groups[strings.Join(values, "/")+"@"+strconv.Itoa(ht)]++
+	}
+	agreeing := 0
+	for _, count := range groups {
+		if count > agreeing {
+			agreeing = count
+		}
+	}
+	return agreeing, strings.Join(states, " ")
+}
+
+/*
+  Waits, for up to timeout, until the running peers agree (all of them if AllRunningNodesMustMatch, else enough
+  for consensus), without asserting it: the QueryAllPeers that follows validates them. Returns how long it took.
+*/
+func (s *Scenario) waitForAgreement(timeout time.Duration) time.Duration {
+	needed := s.NumberOfPeersNeededForConsensus
+	if s.AllRunningNodesMustMatch {
+		needed = s.getNumberOfValidatingPeersRunning()
+	}
+	elapsed, err := peernetwork.WaitUntil("the peers to agree", timeout, func() bool {
+		if s.Interrupted() {
+			return true
+		}
+		agreeing, _ := s.agreeingPeers()
+		return agreeing >= needed
+	})
+	if err != nil {
+		fmt.Println("WARNING: " + err.Error())
+	} else {
+		fmt.Println("The peers agree after " + elapsed.String())
+	}
+	return elapsed
+}
@@ -0,0 +1,179 @@
+package chco2
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+  Interrupts: on SIGINT (^C) or SIGTERM during a test, chco2 stops issuing transactions and disrupting
+  peers, reports the test ABORTED with the step it reached (GO_TESTS_SUMMARY, results and artifacts),
+  restores every peer and the caserver to running (restore_all), and exits; so the next test of a suite
+  starts from a healthy network. A second interrupt exits at once, without restoring the network.
+
+  The test goes on in its own goroutine: the signal handler marks it interrupted, waits for the test step
+  running to return (the steps that poll or loop stop early; the next ones are skipped), and only then
+  reports and restores it, while the next step waits. A step that hangs delays the report: interrupt again.
+
+  Every scenario running in the process is interrupted. A runner that runs tests in its own process
+  sets OnInterrupt, which is called instead of exiting.
+*/
+
+var OnInterrupt func()		// called, instead of os.Exit, once the interrupted tests have been reported
+
+var runningMutex sync.Mutex	// guards running and signalsHandled
+var running = make(map[*Scenario]bool)	// the scenarios between Setup and TimeTrack
+var signalsHandled bool
+
+// Starts handling SIGINT and SIGTERM, once per process; called by Setup, with runningMutex held.
+func handleSignals() {
+	if signalsHandled { return }
+	signalsHandled = true
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)		// so a second interrupt kills the tests at once
+		runningMutex.Lock()
+		var interrupted []*Scenario
+		for s := range running { interrupted = append(interrupted, s) }
+		signalsHandled = false		// an in-process runner goes on: handle the next interrupt afresh
+		runningMutex.Unlock()
+
+		// report and restore the scenarios running in parallel at the same time, each with its own network
+		var wg sync.WaitGroup
+		for _, s := range interrupted {
+			wg.Add(1)
+			go func(s *Scenario) {
+				defer wg.Done()
+				s.interrupt(sig)
+			}(s)
+		}
+		wg.Wait()
+		if OnInterrupt != nil {
+			OnInterrupt()
+			return
+		}
+		os.Exit(1)
+	}()
+}
+
+func (s *Scenario) interrupt(sig os.Signal) {
+	s.testMutex.Lock()
+	if !s.testInProgress {
+		s.testMutex.Unlock()
+		return
+	}
+	s.interruptedBy = strings.ToUpper(sig.String())
+	s.reporting = true
+	if s.steps > 0 { fmt.Println("\nchco2: INTERRUPTED (" + sig.String() + "): waiting for the test step to return") }
+	for s.steps > 0 { s.stepsDone().Wait() }
+	inProgress := s.testInProgress	// the step may have been TimeTrack
+	s.testMutex.Unlock()
+	defer func() {
+		s.testMutex.Lock()
+		s.reporting = false
+		s.stepsDone().Broadcast()
+		s.testMutex.Unlock()
+	}()
+	if !inProgress { return }
+
+	if s == Default {
+		s.load()	// the test may have changed the package variables since its last chco2 call
+		defer s.store()
+	}
+	fmt.Println("\nchco2: INTERRUPTED (" + sig.String() + ") " + s.CurrentTestName + " at: " + s.lastStep())
+	s.TimeTrack(s.testStarted, s.CurrentTestName)
+}
+
+// Whether the current test was interrupted: then the test steps do nothing more.
+func (s *Scenario) Interrupted() bool {
+	s.testMutex.Lock()
+	defer s.testMutex.Unlock()
+	return s.interruptedBy != ""
+}
+
+// Whether a test is between Setup and TimeTrack.
+func (s *Scenario) TestInProgress() bool {
+	s.testMutex.Lock()
+	defer s.testMutex.Unlock()
+	return s.testInProgress
+}
+
+func (s *Scenario) beginTest(started time.Time) {
+	s.testMutex.Lock()
+	s.testInProgress = true
+	s.testStarted = started
+	s.interruptedBy = ""
+	s.testMutex.Unlock()
+	runningMutex.Lock()
+	running[s] = true
+	handleSignals()
+	runningMutex.Unlock()
+}
+
+func (s *Scenario) endTest() {
+	s.testMutex.Lock()
+	s.testInProgress = false
+	s.testMutex.Unlock()
+	runningMutex.Lock()
+	delete(running, s)
+	runningMutex.Unlock()
+}
+
+// the last test step logged, without its time
+func (s *Scenario) lastStep() string {
+	s.testMutex.Lock()
+	defer s.testMutex.Unlock()
+	if len(s.stepLog) == 0 { return "" }
+	step := s.stepLog[len(s.stepLog)-1]
+	if i := strings.Index(step, "  "); i >= 0 { step = step[i+2:] }
+	return step
+}
+
+// The condition on testMutex that the signal handler waits on for the running steps to return.
+func (s *Scenario) stepsDone() *sync.Cond {
+	if s.stepDone == nil { s.stepDone = sync.NewCond(&s.testMutex) }
+	return s.stepDone
+}
+
+// Starts a test step, unless the test was interrupted: then prints that the step is skipped and returns false.
+// A step begun must call endStep when it returns.
+func (s *Scenario) beginStep(step string) bool {
+	s.testMutex.Lock()
+	defer s.testMutex.Unlock()
+	if s.interruptedBy != "" {
+		fmt.Println(step + ": skipped, the test was interrupted")
+		return false
+	}
+	s.steps++
+	return true
+}
+
+func (s *Scenario) endStep() {
+	s.testMutex.Lock()
+	s.steps--
+	s.stepsDone().Broadcast()
+	s.testMutex.Unlock()
+}
+
+// Starts a package function on Default, as a step: waits while the signal handler reports an interrupted
+// test (unless a step is running, which this one is part of), and copies the package variables to Default.
+func (s *Scenario) enter() {
+	s.testMutex.Lock()
+	for s.reporting && s.steps == 0 { s.stepsDone().Wait() }
+	s.steps++
+	s.testMutex.Unlock()
+	s.load()
+}
+
+// Ends a package function on Default: copies Default back to the package variables.
+func (s *Scenario) leave() {
+	s.store()
+	s.endStep()
+}
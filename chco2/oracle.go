@@ -0,0 +1,172 @@
+package chco2
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+  An Oracle models the state of the chaincode a scenario runs: chco2 deploys it with DeployArgs, applies each
+  invoke it sends to the model (Invoke), and at each QueryAllPeers queries the Keys on every running peer and
+  checks the values against the Expected ones (Matches). So the CAT scenarios run over any chaincode that
+  has a model: set Scenario.Oracle (or chco2.SetOracle) before Setup, or choose a built-in one by name with
+  CHCO2_ORACLE (example02, the default, or addrecs).
+
+  When consensus is lost, the invokes chco2 sends are queued by the peers and not yet processed: Keys and
+  Expected take the number of these queued invokes, the latest ones applied to the model, and return the
+  state the peers have before processing them.
+*/
+type Oracle interface {
+	Chaincode() string                               // the chaincode name, as in CC_Collection.json
+	Reset()                                          // back to the state of a new deployment
+	DeployArgs() []string                            // the arguments of the deploy (init) of the chaincode
+	Invoke() []string                                // applies one more invoke to the model, and returns its arguments
+	Keys(queued int) []string                        // the keys to query
+	Expected(queued int) []string                    // the expected values of the keys
+	Matches(expected []string, actual []string) bool // whether the queried values of a peer are the expected ones
+	Adopt(values []string)                           // resets the model to the values queried from the network, e.g. to reuse an existing deployment
+}
+
+// Returns the built-in oracle with this name: example02 or addrecs.
+func NewOracle(name string) (Oracle, error) {
+	switch strings.ToLower(name) {
+	case "", "example02":
+		return NewExample02Oracle(1000000, 1000000), nil // start with ONE MILLION
+	case "addrecs":
+		return NewAddRecsOracle("mycc"), nil
+	}
+	return nil, errors.New("unknown oracle " + name + ": expecting example02 or addrecs")
+}
+
+// Sets the oracle of the Default scenario, used from the next Setup.
+func SetOracle(oracle Oracle) {
+	Default.Oracle = oracle
+}
+
+// Compares the values as integers, else as strings.
+func matchValues(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		e, a := strings.TrimSpace(expected[i]), strings.TrimSpace(actual[i])
+		ei, errE := strconv.Atoi(e)
+		ai, errA := strconv.Atoi(a)
+		if errE == nil && errA == nil {
+			if ei != ai {
+				return false
+			}
+		} else if e != a {
+			return false
+		}
+	}
+	return true
+}
+
+//==============================================================================================================
+// example02: each invoke moves 1 from a to b.
+
+type Example02Oracle struct {
+	InitA, InitB int // the values deployed
+	A, B         int // the values once all the invokes are processed
+}
+
+func NewExample02Oracle(initA int, initB int) *Example02Oracle {
+	o := &Example02Oracle{InitA: initA, InitB: initB}
+	o.Reset()
+	return o
+}
+
+func (o *Example02Oracle) Chaincode() string { return "example02" }
+
+func (o *Example02Oracle) Reset() { o.A, o.B = o.InitA, o.InitB }
+
+func (o *Example02Oracle) DeployArgs() []string {
+	return []string{"a", strconv.Itoa(o.InitA), "b", strconv.Itoa(o.InitB)}
+}
+
+func (o *Example02Oracle) Invoke() []string {
+	o.A--
+	o.B++
+	return []string{"a", "b", "1"}
+}
+
+func (o *Example02Oracle) Keys(queued int) []string { return []string{"a", "b"} }
+
+func (o *Example02Oracle) Expected(queued int) []string {
+	return []string{strconv.Itoa(o.A + queued), strconv.Itoa(o.B - queued)}
+}
+
+func (o *Example02Oracle) Matches(expected []string, actual []string) bool {
+	return matchValues(expected, actual)
+}
+
+func (o *Example02Oracle) Adopt(values []string) {
+	if len(values) < 2 {
+		return
+	}
+	o.A, _ = strconv.Atoi(values[0])
+	o.B, _ = strconv.Atoi(values[1])
+}
+
+//==============================================================================================================
+// addrecs (ledgerstresstest/example02_addRecordsToLedger): each invoke adds a record aN, and counts it in counter.
+
+type AddRecsOracle struct {
+	Name     string // the name of the chaincode in CC_Collection.json (mycc, or concurrency)
+	InitData string // the data of the record a, deployed
+	Counter  int    // the number of records added, once all the invokes are processed
+}
+
+func NewAddRecsOracle(name string) *AddRecsOracle {
+	return &AddRecsOracle{Name: name, InitData: "chco2"}
+}
+
+func (o *AddRecsOracle) Chaincode() string { return o.Name }
+
+func (o *AddRecsOracle) Reset() { o.Counter = 0 }
+
+func (o *AddRecsOracle) DeployArgs() []string {
+	return []string{"a", o.InitData, "counter", "0"}
+}
+
+func (o *AddRecsOracle) Invoke() []string {
+	o.Counter++
+	return []string{o.record(o.Counter), o.data(o.Counter), "counter"}
+}
+
+// counter, and the latest record
+func (o *AddRecsOracle) Keys(queued int) []string {
+	return []string{"counter", o.record(o.Counter - queued)}
+}
+
+func (o *AddRecsOracle) Expected(queued int) []string {
+	return []string{strconv.Itoa(o.Counter - queued), o.data(o.Counter - queued)}
+}
+
+func (o *AddRecsOracle) Matches(expected []string, actual []string) bool {
+	return matchValues(expected, actual)
+}
+
+func (o *AddRecsOracle) Adopt(values []string) {
+	if len(values) < 1 {
+		return
+	}
+	o.Counter, _ = strconv.Atoi(values[0])
+}
+
+// the key of record n: the deploy writes a, the invokes a1, a2...
+func (o *AddRecsOracle) record(n int) string {
+	if n <= 0 {
+		return "a"
+	}
+	return "a" + strconv.Itoa(n)
+}
+
+func (o *AddRecsOracle) data(n int) string {
+	if n <= 0 {
+		return o.InitData
+	}
+	return "record" + strconv.Itoa(n)
+}
@@ -0,0 +1,351 @@
+package chco2
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/threadutil"
+)
+
+/*
+  Structured results of a test, for dashboards and CI viewers: the GO_TESTS_SUMMARY lines say whether a
+  test passed, these say what every query step found. At the end of each test, TimeTrack writes to ResultsDir
+	<testname>.json		the TestReport: every step, its query and chain height validation, and
+				the expected and actual values on each peer
+	TEST-<testname>.xml	the same as a JUnit testsuite, a testcase per step plus one for the test result
+*/
+
+// validation outcomes of a step
+const (
+	ValidationPassed  = "PASSED"
+	ValidationFailed  = "FAILED"
+	ValidationSkipped = "SKIPPED" // not enough peers running for consensus
+)
+
+type PeerResult struct {
+	Peer        string   `json:"peer"`
+	Running     bool     `json:"running"`
+	Validating  bool     `json:"validating"`
+	Values      []string `json:"values"` // the queried values of the oracle keys
+	ChainHeight int      `json:"chainHeight"`
+	QueryMatch  bool     `json:"queryMatch"`           // the values are the expected ones
+	HeightMatch bool     `json:"heightMatch"`          // the chain height is the expected one
+	ChainCheck  string   `json:"chainCheck,omitempty"` // what the chain oracle found wrong in the chain, e.g. "2 missing"
+	Diagnosis   string   `json:"diagnosis,omitempty"`  // when the step failed: in sync, lagging, lost transactions or forked
+}
+
+type StepResult struct {
+	Number              int          `json:"number"`
+	Name                string       `json:"name"`
+	Started             time.Time    `json:"started"`
+	Seconds             float64      `json:"seconds"`
+	Query               string       `json:"query"` // ValidationPassed, ValidationFailed or ValidationSkipped
+	QueryMessage        string       `json:"queryMessage"`
+	ChainHeight         string       `json:"chainHeight"`
+	ChainHeightMessage  string       `json:"chainHeightMessage"`
+	Keys                []string     `json:"keys"`     // the keys queried, e.g. a and b for example02
+	Expected            []string     `json:"expected"` // the values of the oracle, but for the queued transactions
+	ExpectedHeight      int          `json:"expectedHeight"`
+	MustMatchExpected   bool         `json:"mustMatchExpected"` // QsMustMatchExpected: else the peers only have to agree
+	AllRunningMustMatch bool         `json:"allRunningMustMatch"`
+	Peers               []PeerResult `json:"peers"`
+}
+
+// An Eventually assertion, and how long the peers took to converge
+type ConvergenceResult struct {
+	Assertion string    `json:"assertion"` // e.g. EventuallyHeightsEqual(vp0,vp1,vp2,vp3)
+	Status    string    `json:"status"`    // ValidationPassed, ValidationFailed (timed out) or ValidationSkipped
+	Started   time.Time `json:"started"`
+	Seconds   float64   `json:"seconds"` // until the condition held, or the timeout expired
+	Timeout   float64   `json:"timeout"`
+	Message   string    `json:"message"`
+}
+
+type TestReport struct {
+	Name                 string              `json:"name"`
+	Result               string              `json:"result"` // PASSED, FAILED or ABORTED
+	Started              time.Time           `json:"started"`
+	Seconds              float64             `json:"seconds"`
+	QueryPass            bool                `json:"queryPass"`
+	ChainHeightPass      bool                `json:"chainHeightPass"`
+	EnforceQuery         bool                `json:"enforceQuery"`
+	EnforceChainHeight   bool                `json:"enforceChainHeight"`
+	QsMustMatchExpected  bool                `json:"qsMustMatchExpected"`
+	CHsMustMatchExpected bool                `json:"chsMustMatchExpected"`
+	Network              map[string]string   `json:"network"`
+	Steps                []StepResult        `json:"steps"`
+	Convergence          []ConvergenceResult `json:"convergence,omitempty"` // the Eventually assertions
+	Chaos                *ChaosReport        `json:"chaos,omitempty"`       // the seed and actions of a chaos run
+	Failures             []string            `json:"failures,omitempty"`
+	Interrupted          string              `json:"interrupted,omitempty"` // the signal, and the step the test reached
+	ArtifactsPath        string              `json:"artifactsPath,omitempty"`
+}
+
+// JUnit XML, as read by Jenkins and most CI viewers
+type JUnitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr,omitempty"`
+	Suites  []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	XMLName    xml.Name         `xml:"testsuite"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties *JUnitProperties `xml:"properties,omitempty"`
+	TestCases  []JUnitTestCase  `xml:"testcase"`
+}
+
+type JUnitProperties struct {
+	Properties []JUnitProperty `xml:"property"`
+}
+
+type JUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Error     *JUnitFailure `xml:"error,omitempty"`
+	Skipped   *JUnitFailure `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func JUnitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// Starts the report of a new test.
+func (s *Scenario) beginTestReport(testName string, started time.Time) {
+	s.ResultsPath = ""
+	s.testReport = TestReport{Name: testName, Started: started, Network: make(map[string]string)}
+}
+
+// Starts the record of a query step; the validations fill it in.
+func (s *Scenario) beginStepResult(stepName string) {
+	step := StepResult{Number: len(s.testReport.Steps) + 1, Name: stepName, Started: time.Now(),
+		MustMatchExpected: s.QsMustMatchExpected, AllRunningMustMatch: s.AllRunningNodesMustMatch}
+	for n := 0; n < s.NumberOfPeersInNetwork; n++ {
+		step.Peers = append(step.Peers, PeerResult{Peer: threadutil.GetPeer(n), Running: peerIsRunning(n, s.MyNetwork), Validating: s.peerIsValidating(n)})
+	}
+	s.testReport.Steps = append(s.testReport.Steps, step)
+}
+
+func (s *Scenario) currentStepResult() *StepResult {
+	if len(s.testReport.Steps) == 0 {
+		return nil
+	}
+	return &s.testReport.Steps[len(s.testReport.Steps)-1]
+}
+
+// Records the outcome of the query validation of the current step, with the queried values in qData.
+func (s *Scenario) recordQueryResult(status string, message string, keys []string, expected []string) {
+	step := s.currentStepResult()
+	if step == nil {
+		return
+	}
+	step.Query = status
+	step.QueryMessage = strings.TrimSpace(message)
+	step.Keys = keys
+	step.Expected = expected
+	for n := 0; n < len(step.Peers) && n < len(s.qData); n++ {
+		step.Peers[n].Values = s.qData[n]
+		step.Peers[n].QueryMatch = step.Peers[n].Running && s.qData[n] != nil && s.oracle.Matches(expected, s.qData[n])
+	}
+}
+
+// Records the outcome of the chain height validation of the current step, with the chain height of each peer
+// and the problems the chain oracle found in its chain.
+func (s *Scenario) recordChainHeightResult(status string, message string, ht []int, checks []ChainCheck) {
+	step := s.currentStepResult()
+	if step == nil {
+		return
+	}
+	step.ChainHeight = status
+	step.ChainHeightMessage = strings.TrimSpace(message)
+	step.ExpectedHeight = s.currCH
+	for n := 0; n < len(step.Peers) && n < len(ht); n++ {
+		step.Peers[n].ChainHeight = ht[n]
+		step.Peers[n].HeightMatch = step.Peers[n].Running && ht[n] == s.currCH && checks[n].ok()
+		if step.Peers[n].Running {
+			step.Peers[n].ChainCheck = checks[n].Problems()
+		}
+	}
+	step.Seconds = time.Since(step.Started).Seconds()
+}
+
+// Records the outcome of an Eventually assertion, which took elapsed.
+func (s *Scenario) recordConvergence(status string, assertion string, elapsed time.Duration, timeout time.Duration, message string) {
+	s.testReport.Convergence = append(s.testReport.Convergence, ConvergenceResult{Assertion: assertion, Status: status,
+		Started: time.Now().Add(-elapsed), Seconds: elapsed.Seconds(), Timeout: timeout.Seconds(), Message: strings.TrimSpace(message)})
+}
+
+// Records a failure: of a query step, or of a check outside of them, such as a catch up.
+func (s *Scenario) recordFailure(description string) {
+	s.testReport.Failures = append(s.testReport.Failures, description)
+}
+
+/*
+  Writes the JSON and JUnit XML results of the current test to ResultsDir, and sets ResultsPath.
+  Called by TimeTrack, and before a test stops at its first failure (Stop_on_error).
+*/
+func (s *Scenario) WriteResults(result string) {
+	if s.ResultsDir == "" || strings.ToUpper(s.ResultsDir) == "NONE" {
+		return
+	}
+	s.testReport.Result = result
+	s.testReport.Seconds = time.Since(s.testReport.Started).Seconds()
+	s.testReport.QueryPass = s.queryTestsPass
+	s.testReport.ChainHeightPass = s.chainHeightTestsPass
+	s.testReport.EnforceQuery = s.EnforceQueryTestsPass
+	s.testReport.EnforceChainHeight = s.EnforceChainHeightTestsPass
+	s.testReport.QsMustMatchExpected = s.QsMustMatchExpected
+	s.testReport.CHsMustMatchExpected = s.CHsMustMatchExpected
+	s.testReport.ArtifactsPath = s.ArtifactsPath
+	s.testReport.Network["N"] = strconv.Itoa(s.NumberOfValidatingPeers)
+	s.testReport.Network["F"] = strconv.Itoa(s.NumberOfPeersOkToFail)
+	s.testReport.Network["peers"] = strconv.Itoa(s.NumberOfPeersInNetwork)
+	s.testReport.Network["security"] = strconv.FormatBool(s.Security)
+	s.testReport.Network["consensus"] = s.ConsensusMode + "/" + s.PbftMode
+	s.testReport.Network["batchsize"] = strconv.Itoa(s.batchsize)
+	s.testReport.Network["disruption"] = s.DisruptionMode
+
+	if err := os.MkdirAll(s.ResultsDir, 0755); err != nil {
+		fmt.Println("WriteResults(): WARNING: " + err.Error())
+		return
+	}
+	testName := strings.TrimSuffix(filepath.Base(s.testReport.Name), ".go")
+	jsonPath := filepath.Join(s.ResultsDir, testName+".json")
+	data, err := json.MarshalIndent(s.testReport, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(jsonPath, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Println("WriteResults(): WARNING: could not write " + jsonPath + ": " + err.Error())
+		return
+	}
+	s.ResultsPath = jsonPath
+
+	xmlPath := filepath.Join(s.ResultsDir, "TEST-"+testName+".xml")
+	data, err = xml.MarshalIndent(s.testReport.JUnit(), "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(xmlPath, append([]byte(xml.Header), append(data, '\n')...), 0644)
+	}
+	if err != nil {
+		fmt.Println("WriteResults(): WARNING: could not write " + xmlPath + ": " + err.Error())
+	}
+	fmt.Println("chco2 results: " + jsonPath) // catrunner finds the results of a child process with this line
+}
+
+/*
+  The report as a JUnit testsuite: a testcase for each query step, failed if a validation failed, skipped if
+  none could be done; one for each Eventually assertion, failed if it timed out; and a last testcase for the
+  result of the test, failed if FAILED, an error if ABORTED.
+*/
+func (report TestReport) JUnit() JUnitTestSuite {
+	testName := strings.TrimSuffix(filepath.Base(report.Name), ".go")
+	suite := JUnitTestSuite{Name: testName, Time: strconv.FormatFloat(report.Seconds, 'f', 3, 64), Timestamp: report.Started.Format("2006-01-02T15:04:05")}
+	for _, key := range []string{"N", "F", "peers", "security", "consensus", "batchsize", "disruption"} {
+		if value := report.Network[key]; value != "" {
+			if suite.Properties == nil {
+				suite.Properties = &JUnitProperties{}
+			}
+			suite.Properties.Properties = append(suite.Properties.Properties, JUnitProperty{Name: key, Value: value})
+		}
+	}
+	for _, step := range report.Steps {
+		tc := JUnitTestCase{Name: fmt.Sprintf("%02d %s", step.Number, step.Name), ClassName: testName, Time: strconv.FormatFloat(step.Seconds, 'f', 3, 64)}
+		tc.SystemOut = strings.TrimSpace(step.QueryMessage + "\n" + step.ChainHeightMessage)
+		switch {
+		case step.Query == ValidationFailed || step.ChainHeight == ValidationFailed:
+			what := "QUERY"
+			if step.Query != ValidationFailed {
+				what = "CHAINHEIGHT"
+			} else if step.ChainHeight == ValidationFailed {
+				what = "QUERY and CHAINHEIGHT"
+			}
+			tc.Failure = &JUnitFailure{Message: "FAILURE with " + what, Text: step.peersTable()}
+			suite.Failures++
+		case step.Query == ValidationSkipped && step.ChainHeight == ValidationSkipped:
+			tc.Skipped = &JUnitFailure{Message: "not enough peers running for consensus"}
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	for _, c := range report.Convergence {
+		tc := JUnitTestCase{Name: c.Assertion, ClassName: testName, Time: strconv.FormatFloat(c.Seconds, 'f', 3, 64), SystemOut: c.Message}
+		switch c.Status {
+		case ValidationFailed:
+			tc.Failure = &JUnitFailure{Message: "timed out after " + strconv.FormatFloat(c.Timeout, 'f', 0, 64) + "s", Text: c.Message}
+			suite.Failures++
+		case ValidationSkipped:
+			tc.Skipped = &JUnitFailure{Message: "not enough peers running for consensus"}
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	tc := JUnitTestCase{Name: "result", ClassName: testName, Time: suite.Time}
+	switch report.Result {
+	case "FAILED":
+		tc.Failure = &JUnitFailure{Message: "FAILED", Text: strings.Join(report.Failures, "\n")}
+		suite.Failures++
+	case "ABORTED":
+		tc.Error = &JUnitFailure{Message: "ABORTED: the test did not run to completion", Text: strings.Join(report.Failures, "\n")}
+		suite.Errors++
+	}
+	if report.ArtifactsPath != "" {
+		tc.SystemOut = "artifacts: " + report.ArtifactsPath
+	}
+	suite.TestCases = append(suite.TestCases, tc)
+	suite.Tests = len(suite.TestCases)
+	return suite
+}
+
+// Expected and actual values of each running peer, like the FAILED QUERY TEST output.
+func (step StepResult) peersTable() string {
+	str := fmt.Sprintf("%-16s%s CH=%5d", "EXPECTED", step.keyValues(step.Expected), step.ExpectedHeight)
+	for _, p := range step.Peers {
+		if !p.Running {
+			continue
+		}
+		mark := ""
+		if !p.QueryMatch || !p.HeightMatch {
+			mark = "  *"
+		}
+		str += fmt.Sprintf("\n%-16s%s CH=%5d%s", p.Peer, step.keyValues(p.Values), p.ChainHeight, mark)
+	}
+	return str
+}
+
+func (step StepResult) keyValues(values []string) string {
+	str := ""
+	for i, key := range step.Keys {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		str += fmt.Sprintf(" %s=%9s", key, value)
+	}
+	return str
+}
@@ -0,0 +1,574 @@
+package chco2
+
+import (
+	"bufio"
+	"obcsdk/chaincode"
+	"obcsdk/peernetwork"
+	"obcsdk/threadutil"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+  A Scenario owns the state of one chco2 test: its configuration (the tuning flags set by Setup from the
+  environment), the network it runs on, the expected-state model (its Oracle, its ChainOracle and the queued
+  transactions) and the results it writes. Several scenarios can run in one process: one after another,
+  each starting afresh from NewScenario, or in parallel, each in its own goroutine on its own network registered
+  in peernetwork (set Network before Setup). Each scenario drives its network with its own chaincode.Client and
+  node controller.
+
+	s := chco2.NewScenario()
+	s.Network = "netB"
+	s.Setup("CAT_200_B", time.Now())
+	defer s.TimeTrack(time.Now(), "CAT_200_B")
+	s.Invokes(10)
+	s.QueryAllPeers("STEP 1")
+
+  The package functions (chco2.Setup, chco2.Invokes, ...) run the Default scenario; its exported fields are
+  bound to the package variables of the same names, so the existing tests go on setting and reading
+  chco2.Stop_on_error, chco2.CurrentTestName, etc.
+*/
+type Scenario struct {
+	Network string		// the registered network (see peernetwork.AddPeerNetworkFromProfile) to use, instead of creating a local one
+	Oracle Oracle		// the model of the chaincode to run; nil for the built-in one named by CHCO2_ORACLE
+	oracle Oracle		// the model in use, from Setup
+
+	Writer *bufio.Writer
+	TestResult string		// PASSED, FAILED or ABORTED, once TimeTrack has reported the current test
+	url string
+	MyNetwork peernetwork.PeerNetwork
+	Verbose bool	// Another option: go edit  "verbose" in chaincode/const.go for more info about lower level functions operation
+	Stop_on_error bool
+	RanToCompletion bool
+	CurrentTestName string
+	currCH  int			// the height of the chains once all the processed transactions are in them
+	chain *ChainOracle		// the transactions submitted, to check the chains of the peers
+	queryTestsPass, chainHeightTestsPass bool
+
+	// Use slices, not an array. NumberOfPeersInNetwork is set after initialization, so leave size open-ended for now.
+	qData [][]string		// latest queried values of the oracle keys for each peer
+	qtrans int			// counts of transactions queued, for calculating expected values of A & B
+
+	// bools to control when to stop/abort test (and to print additional error msgs when that happens)
+
+	EnforceQueryTestsPass, EnforceChainHeightTestsPass bool
+
+	// bools ...MustMatchExpected... :
+	// True means strict mode: the values must be in consensus AND match an internal counter based on our testcase logic/expectations.
+	// False means lenient mode, or "Consensus only": the values must match each other but not an internal counter.
+	//    Use false when we can't fully understand or complete our own test code logic for the counters.
+
+	QsMustMatchExpected bool 	// Queried values (A & B) must match the internal counters = "expected" values (currA & currB) 
+	CHsMustMatchExpected bool 	// ChainHeight values (CH) must match the "expected" value (currCH) of the chain oracle
+
+	// AllRunningNodesMustMatch=true implies for ALL running nodes; false implies only just enough for consensus.
+	// Typically set false except at very beginning and in CatchUpAndConfirm() - after sending
+	// many many (how many?) invokes that guarantee every node catches up.
+
+	AllRunningNodesMustMatch bool
+
+	NetworkAlreadyRunning bool
+
+	LoggingLevel string
+	localNetwork bool
+	Security bool
+	ConsensusMode string
+	PbftMode string
+	NumberOfPeersInNetwork int		// number of peers in MyNetwork, including those added (AddPeer) or decommissioned later
+	NumberOfValidatingPeers int		// CORE_PBFT_GENERAL_N, the number of PBFT replicas; fixed once the network is started
+	NumberOfPeersOkToFail int
+	MaxNumberOfPeersThatCanFailWhileStillHaveConsensus int
+	MinNumberOfPeersNeededForConsensus int
+	NumberOfPeersNeededForConsensus int
+	InvokesRequiredForCatchUp int
+	K int
+	logmultiplier int
+	batchsize int	// Note: default CORE_PBFT_GENERAL_BATCHSIZE=500. If you change this to anything more than 5, then
+				//	set InvokesRequiredForCatchUp to 200 for a good effort to ensure catch up - but
+				//	be aware that this will be lower than the required number so some testcases that
+				//	may fail sometimes if they set CHsMustMatchExpected=true.
+	batchtimeout int							// default 2
+	batchTimeout string	// CORE_PBFT_GENERAL_TIMEOUT_BATCH=2s		// default 2s
+				// CORE_PBFT_GENERAL_TIMEOUT_REQUEST=10s	// default 2
+				// CORE_PBFT_GENERAL_TIMEOUT_VIEWCHANGE=2s	// default 2
+				// CORE_PBFT_GENERAL_TIMEOUT_NULLREQUEST=1s	// default 0 = disable keep-alive nullrequests
+
+	DisruptionMode string	// Set DisruptionMode to DisruptPause to run all tests using docker pause/unpause instead of
+					// docker stop/restart, or to DisruptKill or DisruptKillWipe. This allows tests to be reused, instead
+					// of duplicated. A step can also choose its own mode with StopPeersWithMode.
+	peerDisruptedBy map[int]string	// the mode each peer was stopped with, so RestartPeers brings it back the same way
+	stoppedWhilePrimary map[int]bool	// peers that were the PBFT primary when they were stopped
+
+	CatchUpBlocks int		// After restarting peers, wait until each is within this many blocks of the network height;
+					// a negative value waits only until the restarted peers answer /chain.
+
+	NumberOfNVPs int		// Non-validating peers added to a new network at setup, after the validating peers; route the
+					// requests through them with REQUEST_ROUTING=NVP, as in production. They do not count for consensus.
+
+	healthMonitoring bool	// Poll the peers in the background so MyNetwork peer states follow what really happens to them
+	healthInterval int		// seconds between two polls of the health monitor
+	healthMonitor *peernetwork.HealthMonitor
+
+	ArtifactsMode string	// When to capture peer logs, container inspect output and chain heights at the end of a test:
+					// ALWAYS, ONFAIL (when FAILED or ABORTED) or NEVER
+	ArtifactsDir string		// Parent directory of the per-test artifacts directories
+	ArtifactsPath string	// Artifacts directory of the current test, once captured
+	stepLog []string		// Timestamped test steps, written with the artifacts to line them up with the peer logs
+	diagnoses []string		// The diagnosis of each failed step (see diagnose), written with the artifacts
+	diagnosedStep string	// The last step diagnosed, so a step that fails both validations is diagnosed once
+
+	ResultsDir string		// Directory for the JSON and JUnit XML results of each test; NONE for none
+	ResultsPath string		// JSON results file of the current test, once written
+	testReport TestReport
+
+	testMutex sync.Mutex		// guards testInProgress, interruptedBy, steps, reporting and stepLog, shared with the signal handler
+	reportMutex sync.Mutex		// TimeTrack reports a test once, from the test or from the signal handler
+	testInProgress bool		// from Setup until TimeTrack
+	testStarted time.Time
+	interruptedBy string		// the signal that interrupted the current test
+	steps int			// the test steps running (nested ones included); the signal handler reports at none
+	reporting bool			// the signal handler reports the test: the next step waits until it is done
+	stepDone *sync.Cond		// signaled, on testMutex, when steps drops or reporting ends
+
+	cc *chaincode.Client		// the chaincode API on MyNetwork, with the chaincodes deployed on it, from Setup
+	controller peernetwork.NodeController	// the node controller of MyNetwork, from Setup
+}
+
+// Returns a scenario with no state: Setup configures it.
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+// The scenario run by the package functions.
+var Default = NewScenario()
+
+// The exported fields of Default, as package variables: the package functions copy them to Default
+// before running it, and back after.
+var (
+	Writer                                             *bufio.Writer
+	TestResult                                         string
+	MyNetwork                                          peernetwork.PeerNetwork
+	Verbose                                            bool
+	Stop_on_error                                      bool
+	RanToCompletion                                    bool
+	CurrentTestName                                    string
+	EnforceQueryTestsPass                              bool
+	EnforceChainHeightTestsPass                        bool
+	QsMustMatchExpected                                bool
+	CHsMustMatchExpected                               bool
+	AllRunningNodesMustMatch                           bool
+	NetworkAlreadyRunning                              bool
+	LoggingLevel                                       string
+	Security                                           bool
+	ConsensusMode                                      string
+	PbftMode                                           string
+	NumberOfPeersInNetwork                             int
+	NumberOfValidatingPeers                            int
+	NumberOfPeersOkToFail                              int
+	MaxNumberOfPeersThatCanFailWhileStillHaveConsensus int
+	MinNumberOfPeersNeededForConsensus                 int
+	NumberOfPeersNeededForConsensus                    int
+	InvokesRequiredForCatchUp                          int
+	K                                                  int
+	DisruptionMode                                     string
+	CatchUpBlocks                                      int
+	NumberOfNVPs                                       int
+	ArtifactsMode                                      string
+	ArtifactsDir                                       string
+	ArtifactsPath                                      string
+	ResultsDir                                         string
+	ResultsPath                                        string
+)
+
+func (s *Scenario) load() {
+	s.Writer = Writer
+	s.TestResult = TestResult
+	s.MyNetwork = MyNetwork
+	s.Verbose = Verbose
+	s.Stop_on_error = Stop_on_error
+	s.RanToCompletion = RanToCompletion
+	s.CurrentTestName = CurrentTestName
+	s.EnforceQueryTestsPass = EnforceQueryTestsPass
+	s.EnforceChainHeightTestsPass = EnforceChainHeightTestsPass
+	s.QsMustMatchExpected = QsMustMatchExpected
+	s.CHsMustMatchExpected = CHsMustMatchExpected
+	s.AllRunningNodesMustMatch = AllRunningNodesMustMatch
+	s.NetworkAlreadyRunning = NetworkAlreadyRunning
+	s.LoggingLevel = LoggingLevel
+	s.Security = Security
+	s.ConsensusMode = ConsensusMode
+	s.PbftMode = PbftMode
+	s.NumberOfPeersInNetwork = NumberOfPeersInNetwork
+	s.NumberOfValidatingPeers = NumberOfValidatingPeers
+	s.NumberOfPeersOkToFail = NumberOfPeersOkToFail
+	s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus = MaxNumberOfPeersThatCanFailWhileStillHaveConsensus
+	s.MinNumberOfPeersNeededForConsensus = MinNumberOfPeersNeededForConsensus
+	s.NumberOfPeersNeededForConsensus = NumberOfPeersNeededForConsensus
+	s.InvokesRequiredForCatchUp = InvokesRequiredForCatchUp
+	s.K = K
+	s.DisruptionMode = DisruptionMode
+	s.CatchUpBlocks = CatchUpBlocks
+	s.NumberOfNVPs = NumberOfNVPs
+	s.ArtifactsMode = ArtifactsMode
+	s.ArtifactsDir = ArtifactsDir
+	s.ArtifactsPath = ArtifactsPath
+	s.ResultsDir = ResultsDir
+	s.ResultsPath = ResultsPath
+}
+
+func (s *Scenario) store() {
+	Writer = s.Writer
+	TestResult = s.TestResult
+	MyNetwork = s.MyNetwork
+	Verbose = s.Verbose
+	Stop_on_error = s.Stop_on_error
+	RanToCompletion = s.RanToCompletion
+	CurrentTestName = s.CurrentTestName
+	EnforceQueryTestsPass = s.EnforceQueryTestsPass
+	EnforceChainHeightTestsPass = s.EnforceChainHeightTestsPass
+	QsMustMatchExpected = s.QsMustMatchExpected
+	CHsMustMatchExpected = s.CHsMustMatchExpected
+	AllRunningNodesMustMatch = s.AllRunningNodesMustMatch
+	NetworkAlreadyRunning = s.NetworkAlreadyRunning
+	LoggingLevel = s.LoggingLevel
+	Security = s.Security
+	ConsensusMode = s.ConsensusMode
+	PbftMode = s.PbftMode
+	NumberOfPeersInNetwork = s.NumberOfPeersInNetwork
+	NumberOfValidatingPeers = s.NumberOfValidatingPeers
+	NumberOfPeersOkToFail = s.NumberOfPeersOkToFail
+	MaxNumberOfPeersThatCanFailWhileStillHaveConsensus = s.MaxNumberOfPeersThatCanFailWhileStillHaveConsensus
+	MinNumberOfPeersNeededForConsensus = s.MinNumberOfPeersNeededForConsensus
+	NumberOfPeersNeededForConsensus = s.NumberOfPeersNeededForConsensus
+	InvokesRequiredForCatchUp = s.InvokesRequiredForCatchUp
+	K = s.K
+	DisruptionMode = s.DisruptionMode
+	CatchUpBlocks = s.CatchUpBlocks
+	NumberOfNVPs = s.NumberOfNVPs
+	ArtifactsMode = s.ArtifactsMode
+	ArtifactsDir = s.ArtifactsDir
+	ArtifactsPath = s.ArtifactsPath
+	ResultsDir = s.ResultsDir
+	ResultsPath = s.ResultsPath
+}
+
+// Returns the chaincode API on MyNetwork; the one of the package functions until Setup made the scenario its own.
+func (s *Scenario) client() *chaincode.Client {
+	if s.cc != nil { return s.cc }
+	return chaincode.NewClient(s.MyNetwork, chaincode.LibCC)
+}
+
+// Returns the node controller of MyNetwork.
+func (s *Scenario) nodeController() peernetwork.NodeController {
+	if s.controller != nil { return s.controller }
+	return peernetwork.NodeControllerOf(s.MyNetwork)
+}
+
+func (s *Scenario) chainHeight(peerNum int) (int, error) {
+	return s.client().GetChainHeight(threadutil.GetPeer(peerNum))
+}
+
+// Setup runs Scenario.Setup on Default.
+func Setup(testName string, started time.Time) {
+	Default.enter()
+	defer Default.leave()
+	Default.Setup(testName, started)
+}
+
+// SetupQuick runs Scenario.SetupQuick on Default.
+func SetupQuick(testName string, started time.Time) {
+	Default.enter()
+	defer Default.leave()
+	Default.SetupQuick(testName, started)
+}
+
+// QueryAllHostsToGetCurrentValues runs Scenario.QueryAllHostsToGetCurrentValues on Default.
+func QueryAllHostsToGetCurrentValues(mynetwork peernetwork.PeerNetwork, a *int, b *int, ch *int) bool {
+	Default.enter()
+	defer Default.leave()
+	return Default.QueryAllHostsToGetCurrentValues(mynetwork, a, b, ch)
+}
+
+// TestsCurrentlyPass runs Scenario.TestsCurrentlyPass on Default.
+func TestsCurrentlyPass() bool {
+	Default.enter()
+	defer Default.leave()
+	return Default.TestsCurrentlyPass()
+}
+
+// WaitAndConfirm runs Scenario.WaitAndConfirm on Default.
+func WaitAndConfirm(sleepExtra int) {
+	Default.enter()
+	defer Default.leave()
+	Default.WaitAndConfirm(sleepExtra)
+}
+
+// CatchUpAndConfirm runs Scenario.CatchUpAndConfirm on Default.
+func CatchUpAndConfirm() {
+	Default.enter()
+	defer Default.leave()
+	Default.CatchUpAndConfirm()
+}
+
+// RunChaos runs Scenario.RunChaos on Default.
+func RunChaos(c *Chaos) {
+	Default.enter()
+	defer Default.leave()
+	Default.RunChaos(c)
+}
+
+// EventuallyHeightsEqual runs Scenario.EventuallyHeightsEqual on Default.
+func EventuallyHeightsEqual(peerNums []int, timeout time.Duration) bool {
+	Default.enter()
+	defer Default.leave()
+	return Default.EventuallyHeightsEqual(peerNums, timeout)
+}
+
+// EventuallyQueryEquals runs Scenario.EventuallyQueryEquals on Default.
+func EventuallyQueryEquals(peerNum int, key string, value string, timeout time.Duration) bool {
+	Default.enter()
+	defer Default.leave()
+	return Default.EventuallyQueryEquals(peerNum, key, value, timeout)
+}
+
+// EventuallyAtLeastConsensusAgree runs Scenario.EventuallyAtLeastConsensusAgree on Default.
+func EventuallyAtLeastConsensusAgree(timeout time.Duration) bool {
+	Default.enter()
+	defer Default.leave()
+	return Default.EventuallyAtLeastConsensusAgree(timeout)
+}
+
+// DeployNew runs Scenario.DeployNew on Default.
+func DeployNew(a int, b int) {
+	Default.enter()
+	defer Default.leave()
+	Default.DeployNew(a, b)
+}
+
+// DeployNewOnPeer runs Scenario.DeployNewOnPeer on Default.
+func DeployNewOnPeer(a int, b int, peer int) {
+	Default.enter()
+	defer Default.leave()
+	Default.DeployNewOnPeer(a, b, peer)
+}
+
+// DeployInit runs Scenario.DeployInit on Default.
+func DeployInit(peerNum int) {
+	Default.enter()
+	defer Default.leave()
+	Default.DeployInit(peerNum)
+}
+
+// Invokes runs Scenario.Invokes on Default.
+func Invokes(totalNumInvokes int) {
+	Default.enter()
+	defer Default.leave()
+	Default.Invokes(totalNumInvokes)
+}
+
+// InvokeOnEachPeer runs Scenario.InvokeOnEachPeer on Default.
+func InvokeOnEachPeer(numInvokesPerPeer int) {
+	Default.enter()
+	defer Default.leave()
+	Default.InvokeOnEachPeer(numInvokesPerPeer)
+}
+
+// InvokesUniqueOnEveryPeer runs Scenario.InvokesUniqueOnEveryPeer on Default.
+func InvokesUniqueOnEveryPeer() {
+	Default.enter()
+	defer Default.leave()
+	Default.InvokesUniqueOnEveryPeer()
+}
+
+// InvokeOnThisPeer runs Scenario.InvokeOnThisPeer on Default.
+func InvokeOnThisPeer(totalNumInvokes int, peerNum int) {
+	Default.enter()
+	defer Default.leave()
+	Default.InvokeOnThisPeer(totalNumInvokes, peerNum)
+}
+
+// QueryAllPeers runs Scenario.QueryAllPeers on Default.
+func QueryAllPeers(stepName string) {
+	Default.enter()
+	defer Default.leave()
+	Default.QueryAllPeers(stepName)
+}
+
+// StopPeers runs Scenario.StopPeers on Default.
+func StopPeers(peerNumsToStopStart []int) {
+	Default.enter()
+	defer Default.leave()
+	Default.StopPeers(peerNumsToStopStart)
+}
+
+// StopPeersWithMode runs Scenario.StopPeersWithMode on Default.
+func StopPeersWithMode(peerNumsToStopStart []int, mode string) {
+	Default.enter()
+	defer Default.leave()
+	Default.StopPeersWithMode(peerNumsToStopStart, mode)
+}
+
+// RestartPeers runs Scenario.RestartPeers on Default.
+func RestartPeers(peerNumsToStopStart []int) {
+	Default.enter()
+	defer Default.leave()
+	Default.RestartPeers(peerNumsToStopStart)
+}
+
+// PrimaryPeer runs Scenario.PrimaryPeer on Default.
+func PrimaryPeer() int {
+	Default.enter()
+	defer Default.leave()
+	return Default.PrimaryPeer()
+}
+
+// BackupPeers runs Scenario.BackupPeers on Default.
+func BackupPeers() []int {
+	Default.enter()
+	defer Default.leave()
+	return Default.BackupPeers()
+}
+
+// StopPrimary runs Scenario.StopPrimary on Default.
+func StopPrimary() int {
+	Default.enter()
+	defer Default.leave()
+	return Default.StopPrimary()
+}
+
+// StopBackups runs Scenario.StopBackups on Default.
+func StopBackups(numBackups int) []int {
+	Default.enter()
+	defer Default.leave()
+	return Default.StopBackups(numBackups)
+}
+
+// AddPeer runs Scenario.AddPeer on Default.
+func AddPeer(role string, peerID string) int {
+	Default.enter()
+	defer Default.leave()
+	return Default.AddPeer(role, peerID)
+}
+
+// DecommissionPeer runs Scenario.DecommissionPeer on Default.
+func DecommissionPeer(peerNum int) {
+	Default.enter()
+	defer Default.leave()
+	Default.DecommissionPeer(peerNum)
+}
+
+// StopMemberServices runs Scenario.StopMemberServices on Default.
+func StopMemberServices() {
+	Default.enter()
+	defer Default.leave()
+	Default.StopMemberServices()
+}
+
+// RestartMemberServices runs Scenario.RestartMemberServices on Default.
+func RestartMemberServices() {
+	Default.enter()
+	defer Default.leave()
+	Default.RestartMemberServices()
+}
+
+// PartitionPeers runs Scenario.PartitionPeers on Default.
+func PartitionPeers(peerGroups [][]int) {
+	Default.enter()
+	defer Default.leave()
+	Default.PartitionPeers(peerGroups)
+}
+
+// DropLink runs Scenario.DropLink on Default.
+func DropLink(peerNumA int, peerNumB int) {
+	Default.enter()
+	defer Default.leave()
+	Default.DropLink(peerNumA, peerNumB)
+}
+
+// HealPartitions runs Scenario.HealPartitions on Default.
+func HealPartitions() {
+	Default.enter()
+	defer Default.leave()
+	Default.HealPartitions()
+}
+
+// DegradePeers runs Scenario.DegradePeers on Default.
+func DegradePeers(peerNums []int, profile peernetwork.LinkProfile) {
+	Default.enter()
+	defer Default.leave()
+	Default.DegradePeers(peerNums, profile)
+}
+
+// RestorePeersLinks runs Scenario.RestorePeersLinks on Default.
+func RestorePeersLinks(peerNums []int) {
+	Default.enter()
+	defer Default.leave()
+	Default.RestorePeersLinks(peerNums)
+}
+
+// ThrottlePeers runs Scenario.ThrottlePeers on Default.
+func ThrottlePeers(peerNums []int, limits peernetwork.ResourceLimits) {
+	Default.enter()
+	defer Default.leave()
+	Default.ThrottlePeers(peerNums, limits)
+}
+
+// UnthrottlePeers runs Scenario.UnthrottlePeers on Default.
+func UnthrottlePeers(peerNums []int) {
+	Default.enter()
+	defer Default.leave()
+	Default.UnthrottlePeers(peerNums)
+}
+
+// InvokesWhileThrottled runs Scenario.InvokesWhileThrottled on Default.
+func InvokesWhileThrottled(peerNums []int, limits peernetwork.ResourceLimits, duration time.Duration, invokesPerRound int) {
+	Default.enter()
+	defer Default.leave()
+	Default.InvokesWhileThrottled(peerNums, limits, duration, invokesPerRound)
+}
+
+// TimeTrack runs Scenario.TimeTrack on Default.
+func TimeTrack(start time.Time, name string) {
+	Default.enter()
+	defer Default.leave()
+	Default.TimeTrack(start, name)
+}
+
+// OpenOutputSummary runs Scenario.OpenOutputSummary on Default.
+func OpenOutputSummary() *os.File {
+	Default.enter()
+	defer Default.leave()
+	return Default.OpenOutputSummary()
+}
+
+// LogStep runs Scenario.LogStep on Default.
+func LogStep(description string) {
+	Default.enter()
+	defer Default.leave()
+	Default.LogStep(description)
+}
+
+// CaptureArtifacts runs Scenario.CaptureArtifacts on Default.
+func CaptureArtifacts() {
+	Default.enter()
+	defer Default.leave()
+	Default.CaptureArtifacts()
+}
+
+// WriteResults runs Scenario.WriteResults on Default.
+func WriteResults(result string) {
+	Default.enter()
+	defer Default.leave()
+	Default.WriteResults(result)
+}
+
+// Interrupted runs Scenario.Interrupted on Default.
+func Interrupted() bool {
+	return Default.Interrupted()	// called from other goroutines too, e.g. by a runner: no step
+}
+
+// TestInProgress runs Scenario.TestInProgress on Default.
+func TestInProgress() bool {
+	return Default.TestInProgress()	// called from other goroutines too, e.g. by a runner: no step
+}
@@ -0,0 +1,97 @@
+package main
+
+//
+// netprofile: validate a network profile, or convert an old NetworkCredentials.json into one.
+//
+//	go run netprofile.go validate ../util/NetworkProfile.json.local
+//	go run netprofile.go convert ../util/NetworkCredentials.json.local  > ../util/NetworkProfile.json.local
+//	go run netprofile.go convert ../util/NetworkCredentials.json.Z  ../util/NetworkProfile.json.Z
+//
+// Convert validates the result too, and says what to fill in by hand (e.g. the CA, which the old format lacks).
+// To run tests with a profile, set NETWORK_PROFILE=<profile file> and use an existing network (CHCO2_EXISTING_NETWORK).
+//
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"obcsdk/peernetwork"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:  netprofile validate <profile.json>...")
+	fmt.Fprintln(os.Stderr, "        netprofile convert <NetworkCredentials.json> [<profile.json>]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "validate":
+		failed := false
+		for _, path := range os.Args[2:] {
+			profile, err := peernetwork.LoadNetworkProfile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stdout, path+": "+err.Error())
+				failed = true
+				continue
+			}
+			if !report(os.Stdout, path, profile) {
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	case "convert":
+		if len(os.Args) > 4 {
+			usage()
+		}
+		in, err := os.Open(os.Args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+		profile, err := peernetwork.ConvertNetworkCredentials(in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, os.Args[2]+": "+err.Error())
+			os.Exit(1)
+		}
+		out := os.Stdout
+		if len(os.Args) == 4 {
+			if out, err = os.Create(os.Args[3]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			defer out.Close()
+		}
+		if err = profile.Write(out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		report(os.Stderr, os.Args[2]+" (converted)", profile)	// keep the converted profile alone on stdout
+		if profile.CA == nil {
+			fmt.Fprintln(os.Stderr, "NOTE: the old format has no CA; add \"ca\" by hand if the tests stop or restart it")
+		}
+	default:
+		usage()
+	}
+}
+
+func report(w io.Writer, name string, profile *peernetwork.NetworkProfile) bool {
+	problems := profile.Validate()
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: OK, network %s: %d peers, %d users, node controller %q\n",
+			name, profile.Name, len(profile.Peers), len(profile.Users), profile.Controller.Type)
+		return true
+	}
+	fmt.Fprintf(w, "%s: %d problems\n", name, len(problems))
+	for _, p := range problems {
+		fmt.Fprintln(w, "    "+p.Error())
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+// Package peerlogs parses fabric peer logs and extracts the PBFT consensus events in them:
+// view changes and the new primary, state transfers, checkpoints, batch executions, and errors
+// or panics. Events can be lined up with the steps of a test, as logged by chco2.
+//
+// The parser expects the logs as written by "docker logs -t", where each line starts with a
+// RFC3339Nano timestamp; that is how chco2 captures them in the test artifacts. Lines without it
+// get only the time of day printed by the peer, which is good enough to read but not to correlate.
+// Most consensus events are logged at INFO or DEBUG level (CORE_LOGGING_LEVEL), and none at ERROR.
+package peerlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ViewChange         = "VIEW_CHANGE"		// a replica sent a view-change, e.g. because its view change timer expired
+	NewView            = "NEW_VIEW"			// a replica accepted a new view; Primary is the new primary
+	StateTransferStart = "STATE_TRANSFER_START"
+	StateTransferDone  = "STATE_TRANSFER_DONE"
+	Checkpoint         = "CHECKPOINT"
+	BatchExecuted      = "BATCH_EXECUTED"
+	Error              = "ERROR"
+	Panic              = "PANIC"
+)
+
+type Event struct {
+	Time    time.Time
+	Peer    string	// the container (or log file) the event was found in
+	Kind    string
+	Replica int	// replica id that logged the event, -1 if unknown
+	View    int	// -1 if unknown
+	SeqNo   int	// -1 if unknown
+	Primary int	// for NEW_VIEW: View mod N; else -1
+	Line    string
+}
+
+/*
+  A Pattern recognizes one kind of event. The named groups replica, view and seqno, when present,
+  fill in the event fields. Patterns is exported so that a test can add the messages of another
+  fabric level; the first matching pattern wins.
+*/
+type Pattern struct {
+	Kind   string
+	Regexp *regexp.Regexp
+}
+
+var Patterns = []Pattern{
+	{ViewChange, regexp.MustCompile(`Replica (?P<replica>\d+) sending view-change, v:(?P<view>\d+)`)},
+	{ViewChange, regexp.MustCompile(`Replica (?P<replica>\d+) view change timer expired`)},
+	{NewView, regexp.MustCompile(`Replica (?P<replica>\d+) accepting new-view to view (?P<view>\d+)`)},
+	{NewView, regexp.MustCompile(`Replica (?P<replica>\d+) now in view (?P<view>\d+)`)},
+	{StateTransferStart, regexp.MustCompile(`Replica (?P<replica>\d+) (is initiating|is out of sync, pending) state transfer`)},
+	{StateTransferStart, regexp.MustCompile(`(?i)(initiating|starting) state transfer`)},
+	{StateTransferDone, regexp.MustCompile(`Replica (?P<replica>\d+) (received state transfer completion event|completed state transfer)`)},
+	{StateTransferDone, regexp.MustCompile(`(?i)state transfer (completed|complete|finished)`)},
+	{Checkpoint, regexp.MustCompile(`Replica (?P<replica>\d+) found checkpoint quorum for seqNo (?P<seqno>\d+)`)},
+	{Checkpoint, regexp.MustCompile(`Replica (?P<replica>\d+) moving low watermark to (?P<seqno>\d+)`)},
+	{BatchExecuted, regexp.MustCompile(`Replica (?P<replica>\d+) executing/committing request batch for view=(?P<view>\d+)/seqNo=(?P<seqno>\d+)`)},
+	{Panic, regexp.MustCompile(`^panic:|goroutine \d+ \[running\]`)},
+	{Error, regexp.MustCompile(` (ERRO|CRIT|ERROR|CRITICAL) `)},
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+var peerClock = regexp.MustCompile(`^(\d\d:\d\d:\d\d\.\d+) `)
+
+/*
+  parses a peer log; n is the number of replicas (CORE_PBFT_GENERAL_N), used to find the primary of a view.
+*/
+func ParseLog(peer string, r io.Reader, n int) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := ansiEscape.ReplaceAllString(scanner.Text(), "")
+		t, msg := splitTime(line)
+		for _, p := range Patterns {
+			match := p.Regexp.FindStringSubmatch(msg)
+			if match == nil {
+				continue
+			}
+			ev := Event{Time: t, Peer: peer, Kind: p.Kind, Replica: -1, View: -1, SeqNo: -1, Primary: -1, Line: strings.TrimSpace(msg)}
+			for i, name := range p.Regexp.SubexpNames() {
+				value, err := strconv.Atoi(match[i])
+				if err != nil {
+					continue
+				}
+				switch name {
+				case "replica":
+					ev.Replica = value
+				case "view":
+					ev.View = value
+				case "seqno":
+					ev.SeqNo = value
+				}
+			}
+			if ev.Kind == NewView && ev.View >= 0 && n > 0 {
+				ev.Primary = ev.View % n
+			}
+			events = append(events, ev)
+			break
+		}
+	}
+	return events, scanner.Err()
+}
+
+func ParseFile(peer string, path string, n int) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseLog(peer, f, n)
+}
+
+/*
+  parses the log of a running (or stopped) peer container, with docker logs -t.
+*/
+func ParseContainer(container string, n int) ([]Event, error) {
+	return ParseContainerSince(container, time.Time{}, n)
+}
+
+/*
+  parses the lines a peer container logged since the given time (all of them for the zero time),
+  with docker logs -t --since.
+*/
+func ParseContainerSince(container string, since time.Time, n int) ([]Event, error) {
+	args := []string{"logs", "-t"}
+	if !since.IsZero() {
+		args = append(args, "--since", fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()))
+	}
+	out, err := exec.Command("docker", append(args, container)...).CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	return ParseLog(container, strings.NewReader(string(out)), n)
+}
+
+/*
+  splits the docker timestamp off a line; without one, uses the time of day printed by the peer.
+*/
+func splitTime(line string) (time.Time, string) {
+	if i := strings.Index(line, " "); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return t, line[i+1:]
+		}
+	}
+	if m := peerClock.FindStringSubmatch(line); m != nil {
+		if t, err := time.Parse("15:04:05.000", m[1]); err == nil {
+			return t, line
+		}
+	}
+	return time.Time{}, line
+}
+
+/*
+  merges the events of several peers in time order.
+*/
+func Merge(eventLists ...[]Event) []Event {
+	var all []Event
+	for _, events := range eventLists {
+		all = append(all, events...)
+	}
+	sort.Stable(byTime(all))
+	return all
+}
+
+type byTime []Event
+
+func (e byTime) Len() int           { return len(e) }
+func (e byTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byTime) Less(i, j int) bool { return e[i].Time.Before(e[j].Time) }
+
+/*
+  returns the latest view (and its primary) that any replica accepted; ok is false if no
+  new view was seen, in which case the network is presumably still in view 0 with primary 0.
+*/
+func CurrentView(events []Event) (view int, primary int, ok bool) {
+	view, primary = 0, 0
+	for _, ev := range events {
+		if ev.Kind == NewView && ev.View >= view {
+			view, primary, ok = ev.View, ev.Primary, true
+		}
+	}
+	return view, primary, ok
+}
+
+func Filter(events []Event, kinds ...string) []Event {
+	var filtered []Event
+	for _, ev := range events {
+		for _, k := range kinds {
+			if ev.Kind == k {
+				filtered = append(filtered, ev)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,130 @@
+package peerlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+  Correlation of consensus events with the steps of a test.
+  chco2 writes its steps in the artifacts file steps.log, one per line: "<RFC3339Nano time>  <description>".
+*/
+
+type Step struct {
+	Time        time.Time
+	Description string
+}
+
+type StepEvents struct {
+	Step   Step
+	Events []Event	// the events after this step started and before the next one did
+}
+
+func ParseSteps(r io.Reader) ([]Step, error) {
+	var steps []Step
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, " ")
+		if i <= 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, line[:i])
+		if err != nil {
+			continue
+		}
+		steps = append(steps, Step{Time: t, Description: strings.TrimSpace(line[i:])})
+	}
+	return steps, scanner.Err()
+}
+
+func ParseStepsFile(path string) ([]Step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSteps(f)
+}
+
+/*
+  assigns every event to the step during which it happened. Events before the first step
+  (e.g. during network startup) are put in a step named "BEFORE FIRST STEP".
+*/
+func Correlate(steps []Step, events []Event) []StepEvents {
+	events = Merge(events)
+	result := []StepEvents{{Step: Step{Description: "BEFORE FIRST STEP"}}}
+	for _, s := range steps {
+		result = append(result, StepEvents{Step: s})
+	}
+	current := 0
+	for _, ev := range events {
+		for current+1 < len(result) && !ev.Time.Before(result[current+1].Step.Time) {
+			current++
+		}
+		result[current].Events = append(result[current].Events, ev)
+	}
+	return result
+}
+
+/*
+  writes a readable report: for every step, the view changes, state transfers, errors and panics
+  that happened during it (with the peer and the new primary), and how many batches and checkpoints
+  were executed. Ends with the last view and primary seen.
+*/
+func WriteReport(w io.Writer, steps []Step, events []Event) {
+	for _, se := range Correlate(steps, events) {
+		batches, checkpoints := 0, 0
+		var notable []Event
+		for _, ev := range se.Events {
+			switch ev.Kind {
+			case BatchExecuted:
+				batches++
+			case Checkpoint:
+				checkpoints++
+			default:
+				notable = append(notable, ev)
+			}
+		}
+		if se.Step.Time.IsZero() && len(se.Events) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s\n", formatTime(se.Step.Time), se.Step.Description)
+		for _, ev := range notable {
+			fmt.Fprintf(w, "    %s  %-8s %s\n", formatTime(ev.Time), ev.Peer, describe(ev))
+		}
+		if batches > 0 || checkpoints > 0 {
+			fmt.Fprintf(w, "    batches executed: %d, checkpoints: %d (all peers)\n", batches, checkpoints)
+		}
+	}
+	if view, primary, ok := CurrentView(events); ok {
+		fmt.Fprintf(w, "LAST VIEW: %d, PRIMARY: replica %d\n", view, primary)
+	} else {
+		fmt.Fprintf(w, "LAST VIEW: no view change seen, PRIMARY: presumably replica 0\n")
+	}
+}
+
+func describe(ev Event) string {
+	switch ev.Kind {
+	case NewView:
+		return fmt.Sprintf("%s replica %d entered view %d, new primary is replica %d", ev.Kind, ev.Replica, ev.View, ev.Primary)
+	case ViewChange:
+		if ev.View >= 0 {
+			return fmt.Sprintf("%s replica %d asks to leave view %d", ev.Kind, ev.Replica, ev.View)
+		}
+		return fmt.Sprintf("%s replica %d view change timer expired", ev.Kind, ev.Replica)
+	default:
+		return ev.Kind + " " + ev.Line
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "--------"
+	}
+	return t.UTC().Format("15:04:05.000")
+}
@@ -15,6 +15,7 @@ type peerHTTP struct {
 	NAME string `json:"name"`
 	IP   string `json:"api-host"`
 	PORT string `json:"api-port"`
+	ROLE string `json:"role,omitempty"`	// VP (default) or NVP
 }
 
 type peerGRPC struct {
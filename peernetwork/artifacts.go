@@ -0,0 +1,88 @@
+package peernetwork
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  Artifacts capture: saves what we need to analyze a test run after the fact, into a directory:
+	<container>.log		docker logs -t of every peer and the CA (caserver) (timestamps in UTC, RFC3339Nano)
+	<container>.inspect.json	docker inspect output of every peer and the caserver
+	chainheights.txt	the chain height each peer reports now
+	peerstates.txt		the state transitions recorded for each peer
+  On a remote network, where we cannot reach the containers, only the last two are written.
+*/
+
+// Timestamp format used in artifacts, the same as docker logs -t, so the lines of all files can be lined up.
+const ArtifactTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func ArtifactTime(t time.Time) string {
+	return t.UTC().Format(ArtifactTimeFormat)
+}
+
+/*
+  captures the logs, inspect output, chain heights and peer states of the network into dir,
+  creating it if needed. Returns the names of the files written; err lists what could not be captured.
+*/
+func CaptureArtifacts(thisNetwork PeerNetwork, dir string) (files []string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New("could not create artifacts directory " + dir + ": " + err.Error())
+	}
+	var failed []string
+	write := func(name string, contents []byte) {
+		if werr := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); werr != nil {
+			failed = append(failed, name)
+			return
+		}
+		files = append(files, name)
+	}
+
+	if LocalDockerNetwork() {
+		containers := []string{CAContainer(thisNetwork)}
+		for i := range thisNetwork.Peers {
+			containers = append(containers, thisNetwork.Peers[i].PeerDetails["name"])
+		}
+		for _, c := range containers {
+			if out, cerr := exec.Command("docker", "logs", "-t", c).CombinedOutput(); cerr == nil {
+				write(c+".log", out)
+			} else {
+				failed = append(failed, c+".log")
+			}
+			if out, cerr := exec.Command("docker", "inspect", c).Output(); cerr == nil {
+				write(c+".inspect.json", out)
+			} else {
+				failed = append(failed, c+".inspect.json")
+			}
+		}
+	}
+
+	heights := "# captured " + ArtifactTime(time.Now()) + "\n"
+	states := ""
+	for i := range thisNetwork.Peers {
+		name := thisNetwork.Peers[i].PeerDetails["name"]
+		if ht, ok := ProbeChainHeight(PeerCopy(&thisNetwork.Peers[i])); ok {
+			heights += name + " " + strconv.Itoa(ht) + "\n"
+		} else {
+			heights += name + " NOT RESPONDING\n"
+		}
+		for _, t := range GetPeerStateHistory(thisNetwork, name) {
+			states += ArtifactTime(t.At) + " " + name + " " + StateName(t.From) + " -> " + StateName(t.To) + " (" + t.Source + ")\n"
+		}
+	}
+	write("chainheights.txt", []byte(heights))
+	write("peerstates.txt", []byte(states))
+
+	fmt.Println("Captured " + strconv.Itoa(len(files)) + " artifacts in " + dir)
+	if len(failed) > 0 {
+		return files, errors.New("could not capture: " + strings.Join(failed, ", "))
+	}
+	return files, nil
+}
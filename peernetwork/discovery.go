@@ -0,0 +1,285 @@
+package peernetwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"obcsdk/peerrest"
+)
+
+/*
+  Topology discovery: the peers a running peer knows, from its /network/peers REST API.
+
+  The response lists the peers the asked peer is connected to (not itself):
+	{"peers":[{"ID":{"name":"vp1"},"address":"172.17.0.3:7051","type":1,"pkiID":"..."}, ...]}
+  where address is the gRPC endpoint and type is 1 (or "VALIDATOR") for a validating peer,
+  2 (or "NON_VALIDATOR") for a non-validating peer.
+*/
+
+const (
+	EndpointValidator    = "VALIDATOR"
+	EndpointNonValidator = "NON_VALIDATOR"
+	EndpointUndefined    = "UNDEFINED"
+)
+
+// REST port of the discovered peers that are not configured: /network/peers gives only their gRPC address.
+// Override with env var DISCOVERY_REST_PORT.
+var DiscoveryRestPort = envString("DISCOVERY_REST_PORT", "7050")
+
+type PeerEndpoint struct {
+	ID      string	// peer ID (CORE_PEER_ID), e.g. vp1
+	Address string	// gRPC address host:port
+	Type    string	// EndpointValidator, EndpointNonValidator or EndpointUndefined
+	PkiID   string	// base64 PKI ID of the peer; empty on a network without security
+}
+
+type peerEndpointJSON struct {
+	ID struct {
+		Name string `json:"name"`
+	} `json:"ID"`
+	Address string          `json:"address"`
+	Type    json.RawMessage `json:"type"`
+	PkiID   string          `json:"pkiID"`
+}
+
+type peersMessageJSON struct {
+	Peers []peerEndpointJSON `json:"peers"`
+}
+
+/*
+  What DiscoverNetwork found, compared with the configured network.
+*/
+type DiscoveryReport struct {
+	Seed          string		// REST URL of the peer that was asked
+	Endpoints     []PeerEndpoint	// the peers the seed peer knows
+	Matched       map[string]string	// configured peer name -> ID of the discovered peer it is
+	NotInView     []string		// configured peers that the seed peer does not know (nor is)
+	NotConfigured []PeerEndpoint	// known peers that are not in the configured network
+}
+
+func (report DiscoveryReport) Consistent() bool {
+	return len(report.NotInView) == 0 && len(report.NotConfigured) == 0
+}
+
+func (report DiscoveryReport) String() string {
+	str := fmt.Sprintf("Discovered %d peers from %s; %d match the configured network", len(report.Endpoints), report.Seed, len(report.Matched))
+	for _, name := range report.NotInView {
+		str += "\n  configured but not in the view of the seed peer: " + name
+	}
+	for _, ep := range report.NotConfigured {
+		str += "\n  in the view of the seed peer but not configured: " + ep.ID + " (" + ep.Address + ", " + ep.Type + ")"
+	}
+	return str
+}
+
+/*
+  parses a /network/peers response.
+*/
+func ParsePeerEndpoints(body string) ([]PeerEndpoint, error) {
+	var msg peersMessageJSON
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		return nil, errors.New("cannot parse /network/peers response: " + err.Error())
+	}
+	endpoints := make([]PeerEndpoint, 0, len(msg.Peers))
+	for _, p := range msg.Peers {
+		endpoints = append(endpoints, PeerEndpoint{ID: p.ID.Name, Address: p.Address, Type: endpointType(p.Type), PkiID: p.PkiID})
+	}
+	return endpoints, nil
+}
+
+/*
+  the type is a number in the protobuf JSON of v0.5 and v0.6, but a name with jsonpb.
+*/
+func endpointType(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return strings.ToUpper(name)
+	}
+	var number int
+	if json.Unmarshal(raw, &number) == nil {
+		switch number {
+		case 1:
+			return EndpointValidator
+		case 2:
+			return EndpointNonValidator
+		}
+	}
+	return EndpointUndefined
+}
+
+/*
+  asks a peer (url is http://IP:PORT) for the peers it knows.
+*/
+func GetNetworkPeers(url string) ([]PeerEndpoint, error) {
+	body, status := peerrest.GetChainInfo(url + "/network/peers")
+	if !strings.HasPrefix(status, "200") {
+		return nil, errors.New("GET " + url + "/network/peers: " + status + " " + body)
+	}
+	return ParsePeerEndpoints(body)
+}
+
+/*
+  builds the network as seen from a single reachable peer (seedURL is http://IP:PORT): the seed peer
+  and the peers it knows. The discovered peers take the REST address, name and users of the configured
+  peer they match (NETWORK_PROFILE, or NetworkCredentials.json if it exists), and the report lists the
+  configured peers that are not in the view of the seed peer, and the reverse.
+*/
+func DiscoverNetwork(seedURL string) (PeerNetwork, DiscoveryReport, error) {
+	var configured PeerNetwork
+	if os.Getenv("NETWORK_PROFILE") != "" || isFile(NetworkCredentialsFile()) {
+		configured = LoadNetwork()
+	}
+	return DiscoverNetworkFrom(seedURL, configured)
+}
+
+/*
+  same as DiscoverNetwork, comparing with the given configured network (which may have no peers).
+*/
+func DiscoverNetworkFrom(seedURL string, configured PeerNetwork) (PeerNetwork, DiscoveryReport, error) {
+	report := DiscoveryReport{Seed: seedURL, Matched: make(map[string]string)}
+	endpoints, err := GetNetworkPeers(seedURL)
+	if err != nil {
+		return PeerNetwork{}, report, err
+	}
+	report.Endpoints = endpoints
+
+	// the seed peer is not in its own view: find it among the configured peers by its REST address
+	seed := -1
+	seedHost, seedPort := splitURL(seedURL)
+	for i := range configured.Peers {
+		if configured.Peers[i].PeerDetails["ip"] == seedHost && configured.Peers[i].PeerDetails["port"] == seedPort {
+			seed = i
+		}
+	}
+
+	discovered := PeerNetwork{Name: configured.Name}
+	if discovered.Name == "" {
+		discovered.Name = "discovered"
+	}
+	used := make(map[int]bool)
+	if seed >= 0 {
+		used[seed] = true
+		discovered.Peers = append(discovered.Peers, copyPeer(&configured.Peers[seed]))
+		report.Matched[configured.Peers[seed].PeerDetails["name"]] = configured.Peers[seed].PeerDetails["peerid"]
+	} else {
+		details := map[string]string{"ip": seedHost, "port": seedPort, "name": seedHost + ":" + seedPort}
+		discovered.Peers = append(discovered.Peers, Peer{PeerDetails: details, UserData: make(map[string]string), State: RUNNING, StateSince: time.Now()})
+	}
+	for _, ep := range endpoints {
+		if seed >= 0 && isEndpointOf(configured.Peers[seed], ep) {
+			report.Matched[configured.Peers[seed].PeerDetails["name"]] = ep.ID	// the seed peer listed itself
+			discovered.Peers[0].PeerDetails["peerid"] = ep.ID
+			continue
+		}
+		i := matchEndpoint(configured, ep, used)
+		var aPeer Peer
+		if i >= 0 {
+			used[i] = true
+			aPeer = copyPeer(&configured.Peers[i])
+			report.Matched[aPeer.PeerDetails["name"]] = ep.ID
+		} else {
+			report.NotConfigured = append(report.NotConfigured, ep)
+			host, _, _ := net.SplitHostPort(ep.Address)
+			aPeer = Peer{PeerDetails: map[string]string{"ip": host, "port": DiscoveryRestPort, "name": ep.ID}, UserData: make(map[string]string)}
+		}
+		aPeer.PeerDetails["peerid"] = ep.ID
+		if host, port, err := net.SplitHostPort(ep.Address); err == nil {
+			aPeer.PeerDetails["grpc-host"] = host
+			aPeer.PeerDetails["grpc-port"] = port
+		}
+		if ep.Type == EndpointNonValidator {
+			aPeer.PeerDetails["role"] = RoleNVP
+		} else if ep.Type == EndpointValidator {
+			aPeer.PeerDetails["role"] = RoleVP
+		}
+		aPeer.State = RUNNING
+		aPeer.StateSince = time.Now()
+		discovered.Peers = append(discovered.Peers, aPeer)
+	}
+	for i := range configured.Peers {
+		if !used[i] && PeerStateOf(configured, i) != DECOMMISSIONED {
+			report.NotInView = append(report.NotInView, configured.Peers[i].PeerDetails["name"])
+		}
+	}
+	return discovered, report, nil
+}
+
+/*
+  returns the index of the configured peer that is this endpoint, by peer ID, gRPC address, or IP
+  (local peers use their container IP for both REST and gRPC); -1 if none.
+*/
+func matchEndpoint(configured PeerNetwork, ep PeerEndpoint, used map[int]bool) int {
+	host, port, _ := net.SplitHostPort(ep.Address)
+	byAddress, byIP := -1, -1
+	for i := range configured.Peers {
+		if used[i] {
+			continue
+		}
+		details := configured.Peers[i].PeerDetails
+		if ep.ID != "" && details["peerid"] == ep.ID {
+			return i
+		}
+		if details["grpc-host"] == host && details["grpc-port"] == port && byAddress < 0 {
+			byAddress = i
+		}
+		if details["ip"] == host && byIP < 0 {
+			byIP = i
+		}
+	}
+	if byAddress >= 0 {
+		return byAddress
+	}
+	return byIP
+}
+
+/*
+  returns true if the endpoint is this peer by peer ID or full gRPC address; not by IP alone, which
+  the other peers on the same host share.
+*/
+func isEndpointOf(aPeer Peer, ep PeerEndpoint) bool {
+	details := aPeer.PeerDetails
+	if ep.ID != "" && details["peerid"] == ep.ID {
+		return true
+	}
+	host, port, _ := net.SplitHostPort(ep.Address)
+	return details["grpc-host"] != "" && details["grpc-host"] == host && details["grpc-port"] == port
+}
+
+func copyPeer(aPeer *Peer) Peer {
+	details := make(map[string]string)
+	for k, v := range aPeer.PeerDetails {
+		details[k] = v
+	}
+	users := make(map[string]string)
+	for k, v := range aPeer.UserData {
+		users[k] = v
+	}
+	return Peer{PeerDetails: details, UserData: users, State: RUNNING, StateSince: time.Now()}
+}
+
+func splitURL(restURL string) (host string, port string) {
+	u, err := url.Parse(restURL)
+	if err != nil || u.Host == "" {
+		return restURL, ""
+	}
+	host, port, err = net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return host, port
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
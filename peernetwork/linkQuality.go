@@ -0,0 +1,120 @@
+package peernetwork
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+  Degraded links: delay, jitter, packet loss and bandwidth limits on the interface of a peer
+  container, applied with a netem queueing discipline (tc qdisc ... netem). The qdisc shapes the
+  traffic the peer sends, so every other peer sees it as a slow or lossy replica.
+*/
+
+// Interface of the peer containers on the docker network.
+var PeerInterface = envString("PEER_INTERFACE", "eth0")
+
+type LinkProfile struct {
+	Delay  time.Duration	// added to every packet sent
+	Jitter time.Duration	// random variation of Delay, +/-
+	Loss   float64		// percent of packets dropped, 0-100
+	Rate   string		// bandwidth limit in tc units, e.g. "1mbit" or "500kbit"; "" = no limit
+}
+
+// Some profiles useful with the PBFT timers (default batch timeout 2s, request timeout 10s).
+var (
+	SlowLink  = LinkProfile{Delay: 500 * time.Millisecond, Jitter: 100 * time.Millisecond}
+	LossyLink = LinkProfile{Loss: 10}
+	WANLink   = LinkProfile{Delay: 100 * time.Millisecond, Jitter: 20 * time.Millisecond, Loss: 1, Rate: "10mbit"}
+)
+
+func (p LinkProfile) String() string {
+	return fmt.Sprintf("delay=%s jitter=%s loss=%g%% rate=%s", p.Delay, p.Jitter, p.Loss, p.Rate)
+}
+
+/*
+  builds the netem arguments of the profile, e.g. "delay 100ms 20ms loss 1% rate 10mbit".
+*/
+func (p LinkProfile) netemArgs() (string, error) {
+	if p.Delay < 0 || p.Jitter < 0 || p.Loss < 0 || p.Loss > 100 {
+		return "", errors.New("invalid link profile: " + p.String())
+	}
+	if p.Jitter > 0 && p.Delay == 0 {
+		return "", errors.New("invalid link profile, jitter requires a delay: " + p.String())
+	}
+	args := ""
+	if p.Delay > 0 {
+		args += " delay " + tcTime(p.Delay)
+		if p.Jitter > 0 {
+			args += " " + tcTime(p.Jitter)
+		}
+	}
+	if p.Loss > 0 {
+		args += " loss " + strconv.FormatFloat(p.Loss, 'f', -1, 64) + "%"
+	}
+	if p.Rate != "" {
+		args += " rate " + p.Rate
+	}
+	if args == "" {
+		return "", errors.New("empty link profile; use Restore to clear a degraded link")
+	}
+	return args, nil
+}
+
+func tcTime(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Microsecond), 10) + "us"
+}
+
+func (d *DockerController) Degrade(thisNetwork PeerNetwork, peer string, profile LinkProfile) error {
+	if !LocalDockerNetwork() {
+		return errors.New("Degrade: link faults are only supported on a local docker network")
+	}
+	name, err := GetFullPeerName(thisNetwork, peer)
+	if err != nil {
+		return errors.New("Degrade: invalid peer " + peer)
+	}
+	args, err := profile.netemArgs()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Degrade: " + name + " " + profile.String())
+	if _, err = d.runInNetNamespace(name, "tc qdisc replace dev "+PeerInterface+" root netem"+args); err != nil {
+		return err
+	}
+	d.degradedPeers[name] = profile
+	return nil
+}
+
+func (d *DockerController) Restore(thisNetwork PeerNetwork, peer string) error {
+	name, err := GetFullPeerName(thisNetwork, peer)
+	if err != nil {
+		return errors.New("Restore: invalid peer " + peer)
+	}
+	if _, ok := d.degradedPeers[name]; !ok {
+		return nil
+	}
+	switch ContainerStatus(name) {
+	case "paused":
+		return errors.New("Restore: cannot enter paused container " + name + "; unpause it first")
+	case "running":
+	default:
+		delete(d.degradedPeers, name)	// a stopped container lost its qdisc already
+		return nil
+	}
+	delete(d.degradedPeers, name)
+	fmt.Println("Restore: " + name + " link quality")
+	_, err = d.runInNetNamespace(name, "tc qdisc del dev "+PeerInterface+" root 2>/dev/null; true")
+	return err
+}
+
+func (d *DockerController) DegradedPeers() []string {
+	peers := make([]string, 0, len(d.degradedPeers))
+	for name := range d.degradedPeers {
+		peers = append(peers, name)
+	}
+	sort.Strings(peers)
+	return peers
+}
@@ -0,0 +1,97 @@
+package peernetwork
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+  Locations of the files the SDK reads and writes:
+	NetworkCredentials.json		the network the tests use (see LoadNetwork)
+	CC_Collection.json		the library of chaincodes (see InitializeChainCodes)
+	automation directory		local_fabric scripts, and the networkcredentials file they write
+  Each one is, in this order of preference:
+	- set by the test with SetNetworkCredentialsFile, SetChainCodeCollectionFile, SetAutomationDir, or SetObcsdkHome
+	- given by env var NETWORK_CREDENTIALS, CC_COLLECTION, OBCSDK_AUTOMATION, or OBCSDK_HOME
+	- found in the obcsdk home: the first directory with util/ and automation/ subdirectories, searching
+	  the current directory and its parents, then <GOPATH>/src/obcsdk
+  so the SDK works from any directory (e.g. go test in another repository), not only from a test directory of obcsdk.
+*/
+
+var obcsdkHome, credentialsFile, ccCollectionFile, automationDir string
+
+func SetObcsdkHome(dir string)              { obcsdkHome = dir }
+func SetNetworkCredentialsFile(path string) { credentialsFile = path }
+func SetChainCodeCollectionFile(path string) { ccCollectionFile = path }
+func SetAutomationDir(dir string)           { automationDir = dir }
+
+/*
+  returns the obcsdk home directory, or "" if it cannot be found.
+*/
+func ObcsdkHome() string {
+	if obcsdkHome != "" {
+		return obcsdkHome
+	}
+	if dir := strings.TrimSpace(os.Getenv("OBCSDK_HOME")); dir != "" {
+		return dir
+	}
+	var candidates []string
+	if pwd, err := os.Getwd(); err == nil {
+		for dir := pwd; ; dir = filepath.Dir(dir) {
+			candidates = append(candidates, dir)
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
+		if gopath != "" {
+			candidates = append(candidates, filepath.Join(gopath, "src", "obcsdk"))
+		}
+	}
+	for _, dir := range candidates {
+		if isDir(filepath.Join(dir, "util")) && isDir(filepath.Join(dir, "automation")) {
+			return dir
+		}
+	}
+	return ""
+}
+
+func NetworkCredentialsFile() string {
+	return location(credentialsFile, "NETWORK_CREDENTIALS", "util", "NetworkCredentials.json")
+}
+
+func ChainCodeCollectionFile() string {
+	return location(ccCollectionFile, "CC_COLLECTION", "util", "CC_Collection.json")
+}
+
+func AutomationDir() string {
+	return location(automationDir, "OBCSDK_AUTOMATION", "automation")
+}
+
+/*
+  resolves one location; when the obcsdk home cannot be found, uses the old place relative to
+  the current directory (i.e. a test directory of obcsdk), so the error names the file looked for.
+*/
+func location(explicit string, envVar string, elem ...string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if path := strings.TrimSpace(os.Getenv(envVar)); path != "" {
+		return path
+	}
+	home := ObcsdkHome()
+	if home == "" {
+		pwd, _ := os.Getwd()
+		home = filepath.Join(pwd, "..")
+		fmt.Println("WARNING: cannot find the obcsdk directory; set OBCSDK_HOME or " + envVar + ". Trying " + filepath.Join(append([]string{home}, elem...)...))
+	}
+	return filepath.Join(append([]string{home}, elem...)...)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,237 @@
+package peernetwork
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  Dynamic membership of a local network: launch an extra peer that joins the running network,
+  or decommission a peer for good.
+
+  The new peer is configured like the peers started by local_fabric (same image, consensus and
+  security settings, discovery root node), from the docker inspect output of one of them.
+  A PBFT network has a fixed N (CORE_PBFT_GENERAL_N), and the replica id of a validating peer is
+  the number in its peer ID: a new validating peer must take a free replica id below N, e.g. that of
+  a decommissioned peer, so it joins as a brand-new node and gets the ledger by state transfer.
+  Non-validating peers do not count in N and can be added freely.
+
+  A decommissioned peer keeps its place in PeerNetwork.Peers (tests address peers by their index),
+  in state DECOMMISSIONED; its container is removed with its data.
+*/
+
+type NewPeer struct {
+	Container    string			// docker container name; default PEER<n>, n = the number of peers in the network
+	PeerID       string			// CORE_PEER_ID; default vp<n>, or nvp<n> for a non-validating peer
+	Role         string			// RoleVP (default) or RoleNVP
+	EnrollID     string			// on a secure network, CORE_SECURITY_ENROLLID; default test_<PeerID>
+	EnrollSecret string			// default: the secret of EnrollID in membersrvc.yaml in the automation directory
+	Users        map[string]string	// users (and secrets) of the tests, to register on the new peer; default test_user<n> on a secure network
+	RestPort     string			// host port published for REST; default 7050+10n
+	GrpcPort     string			// host port published for gRPC; default 30001+2n
+}
+
+type containerConfig struct {
+	Image string
+	Env   []string
+	Cmd   []string
+}
+
+/*
+  launches a new peer that joins the network, waits until it answers, and returns the network
+  with the new peer added at the end of Peers. The network is updated in the registry too.
+*/
+func AddPeerLocal(thisNetwork PeerNetwork, spec NewPeer) (PeerNetwork, error) {
+	n := len(thisNetwork.Peers)
+	if spec.Role == "" {
+		spec.Role = RoleVP
+	}
+	spec.Role = strings.ToUpper(spec.Role)
+	if spec.Role != RoleVP && spec.Role != RoleNVP {
+		return thisNetwork, errors.New("unknown peer role " + spec.Role)
+	}
+	if spec.Container == "" {
+		spec.Container = "PEER" + strconv.Itoa(n)
+	}
+	if spec.PeerID == "" {
+		spec.PeerID = strings.ToLower(spec.Role) + strconv.Itoa(n)
+	}
+	if spec.RestPort == "" {
+		spec.RestPort = strconv.Itoa(7050 + 10*n)
+	}
+	if spec.GrpcPort == "" {
+		spec.GrpcPort = strconv.Itoa(30001 + 2*n)
+	}
+	for i := range thisNetwork.Peers {
+		if thisNetwork.Peers[i].PeerDetails["name"] == spec.Container && PeerStateOf(thisNetwork, i) != DECOMMISSIONED {
+			return thisNetwork, errors.New("network " + thisNetwork.Name + " already has a peer " + spec.Container)
+		}
+	}
+
+	template, err := peerTemplate(thisNetwork)
+	if err != nil {
+		return thisNetwork, err
+	}
+	env := setEnv(template.Env, "CORE_PEER_ID", spec.PeerID)
+	if getEnv(env, "CORE_SECURITY_ENABLED") == "true" {
+		if spec.EnrollID == "" {
+			spec.EnrollID = "test_" + spec.PeerID
+		}
+		if spec.EnrollSecret == "" {
+			if spec.EnrollSecret, err = membersrvcSecret(spec.EnrollID); err != nil {
+				return thisNetwork, err
+			}
+		}
+		env = setEnv(env, "CORE_SECURITY_ENROLLID", spec.EnrollID)
+		env = setEnv(env, "CORE_SECURITY_ENROLLSECRET", spec.EnrollSecret)
+		if spec.Users == nil {
+			// like local_fabric, give the new peer the next test user, so the tests can send it requests
+			if secret, err := membersrvcSecret("test_user" + strconv.Itoa(n)); err == nil {
+				spec.Users = map[string]string{"test_user" + strconv.Itoa(n): secret}
+			}
+		}
+	}
+	if getEnv(env, "CORE_PEER_ADDRESSAUTODETECT") == "false" {
+		// the peer advertises a host address: use the gRPC port published for this one
+		address := getEnv(env, "CORE_PEER_ADDRESS")
+		if i := strings.LastIndex(address, ":"); i > 0 {
+			env = setEnv(env, "CORE_PEER_ADDRESS", address[:i+1]+spec.GrpcPort)
+		}
+	}
+	if spec.Role == RoleNVP {
+		env = setEnv(env, "CORE_PEER_VALIDATOR_ENABLED", "false")
+	} else {
+		env = unsetEnv(env, "CORE_PEER_VALIDATOR_ENABLED")
+	}
+
+	args := []string{"run", "-d", "--name=" + spec.Container, "-p", spec.RestPort + ":7050", "-p", spec.GrpcPort + ":7051"}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, template.Image)
+	args = append(args, template.Cmd...)
+	fmt.Println("Adding " + spec.Role + " " + spec.PeerID + " to network " + thisNetwork.Name + ": docker " + strings.Join(args, " "))
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return thisNetwork, errors.New("could not start " + spec.Container + ": " + strings.TrimSpace(string(out)))
+	}
+
+	details := map[string]string{
+		"ip":        ContainerIP(spec.Container),
+		"port":      "7050",
+		"name":      spec.Container,
+		"peerid":    spec.PeerID,
+		"role":      spec.Role,
+		"grpc-host": ContainerIP(spec.Container),
+		"grpc-port": "7051",
+	}
+	users := make(map[string]string)
+	for user, secret := range spec.Users {
+		users[user] = secret
+	}
+	newPeers := make([]Peer, n, n+1)
+	copy(newPeers, thisNetwork.Peers)
+	newPeers = append(newPeers, Peer{PeerDetails: details, UserData: users, State: STARTED, StateSince: time.Now()})
+	thisNetwork.Peers = newPeers
+	SetPeerState(thisNetwork, spec.Container, waitForPeerUp(thisNetwork, spec.Container))
+	updateRegisteredNetwork(thisNetwork)
+	return thisNetwork, nil
+}
+
+/*
+  removes a peer from the network for good: its container and data are deleted, and it stays
+  in Peers in state DECOMMISSIONED.
+*/
+func DecommissionPeerLocal(thisNetwork PeerNetwork, peer string) error {
+	var aPeer *Peer
+	for i := range thisNetwork.Peers {
+		if thisNetwork.Peers[i].PeerDetails["name"] == peer {
+			aPeer = &thisNetwork.Peers[i]
+		}
+	}
+	if aPeer == nil {
+		return errors.New(peer + ", Not found on network")
+	}
+	if out, err := exec.Command("docker", "rm", "-f", "-v", peer).CombinedOutput(); err != nil {
+		return errors.New("could not remove " + peer + ": " + strings.TrimSpace(string(out)))
+	}
+	recordPeerState(aPeer, DECOMMISSIONED, "DecommissionPeer")
+	fmt.Println("Decommissioned " + peer + " from network " + thisNetwork.Name)
+	return nil
+}
+
+/*
+  returns the container config of a peer started by local_fabric that joined through the root
+  node (not PEER0, which is the root node itself), to configure a new peer the same way.
+*/
+func peerTemplate(thisNetwork PeerNetwork) (containerConfig, error) {
+	var config containerConfig
+	for i := range thisNetwork.Peers {
+		if PeerStateOf(thisNetwork, i) == DECOMMISSIONED {
+			continue
+		}
+		out, err := exec.Command("docker", "inspect", "--format", "{{json .Config}}", thisNetwork.Peers[i].PeerDetails["name"]).Output()
+		if err != nil {
+			continue
+		}
+		if err = json.Unmarshal(out, &config); err != nil {
+			continue
+		}
+		if getEnv(config.Env, "CORE_PEER_DISCOVERY_ROOTNODE") != "" {
+			return config, nil
+		}
+	}
+	return config, errors.New("no peer of network " + thisNetwork.Name + " to copy the configuration from (a running container with CORE_PEER_DISCOVERY_ROOTNODE)")
+}
+
+/*
+  looks up the secret of an enrollment ID in the membersrvc.yaml that local_fabric downloaded,
+  where users are listed as "<id>: <affiliation role> <secret> ...".
+*/
+func membersrvcSecret(enrollID string) (string, error) {
+	path := filepath.Join(AutomationDir(), "membersrvc.yaml")
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.New("no EnrollSecret given for " + enrollID + ", and cannot read " + path + ": " + err.Error())
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == enrollID+":" {
+			return fields[2], nil
+		}
+	}
+	return "", errors.New("no EnrollSecret given for " + enrollID + ", and it is not in " + path)
+}
+
+func getEnv(env []string, key string) string {
+	for _, e := range env {
+		if strings.HasPrefix(e, key+"=") {
+			return e[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+func setEnv(env []string, key string, value string) []string {
+	result := unsetEnv(env, key)
+	return append(result, key+"="+value)
+}
+
+func unsetEnv(env []string, key string) []string {
+	var result []string
+	for _, e := range env {
+		if !strings.HasPrefix(e, key+"=") {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,421 @@
+package peernetwork
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/peerrest"
+)
+
+/*
+  Network profile: a versioned JSON description of a peer network, replacing the hand-edited
+  NetworkCredentials.json variants. Besides the peer REST endpoints and the users, it covers the
+  gRPC endpoints, container names and roles of the peers, the CA, which peer each user belongs to,
+  TLS settings, how to disrupt the nodes (node controller), and the consensus configuration.
+  The REST calls to the peers use the TLS settings, and chco2.Setup takes N, F and the batch
+  settings of the test from the consensus configuration.
+
+	{
+	  "version": "1",
+	  "name": "local4",
+	  "peers": [ { "name": "vp0", "container": "PEER0", "role": "VP",
+	               "rest": { "host": "172.17.0.1", "port": "7050" }, "grpc": { "host": "172.17.0.1", "port": "30001" } }, ... ],
+	  "ca": { "container": "caserver", "endpoint": { "host": "172.17.0.1", "port": "7054" } },
+	  "users": [ { "name": "test_user0", "secret": "MS9qrN8hFjlE", "peer": "vp0" }, ... ],
+	  "tls": { "enabled": false },
+	  "nodeController": { "type": "docker" },
+	  "consensus": { "plugin": "pbft", "mode": "batch", "n": 4, "f": 1, "batchSize": 2, "batchTimeout": "2s" }
+	}
+
+  See util/NetworkProfile.json.local for a complete example, and the netprofile command to
+  validate a profile or convert an old NetworkCredentials.json file into one.
+*/
+
+const NetworkProfileVersion = "1"
+
+const (
+	RoleVP  = "VP"	// validating peer: takes part in consensus
+	RoleNVP = "NVP"	// non-validating peer: forwards transactions to the validating peers
+)
+
+const (
+	ControllerDocker = "docker"	// local docker containers: stop/start/pause, partitions, netem, throttling
+	ControllerNone   = "none"	// nodes we cannot disrupt, e.g. a remote network
+)
+
+type Endpoint struct {
+	Host string `json:"host"`
+	Port string `json:"port"`
+}
+
+type ProfilePeer struct {
+	Name      string    `json:"name"`			// peer ID, e.g. vp0
+	Container string    `json:"container,omitempty"`	// docker container name, e.g. PEER0; defaults to name
+	Role      string    `json:"role,omitempty"`		// VP (default) or NVP
+	Rest      Endpoint  `json:"rest"`
+	Grpc      *Endpoint `json:"grpc,omitempty"`
+}
+
+type ProfileCA struct {
+	Container string   `json:"container,omitempty"`
+	Endpoint  Endpoint `json:"endpoint"`
+}
+
+type ProfileUser struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	Peer   string `json:"peer,omitempty"`	// the peer this user is registered on; users without one are spread evenly
+}
+
+type ProfileTLS struct {
+	Enabled            bool   `json:"enabled"`
+	CACert             string `json:"caCert,omitempty"`		// PEM file of the CA that signed the peer certificates
+	ServerHostOverride string `json:"serverHostOverride,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+type ProfileController struct {
+	Type          string `json:"type"`				// docker or none
+	NetToolsImage string `json:"netToolsImage,omitempty"`	// see NET_TOOLS_IMAGE
+}
+
+type ProfileConsensus struct {
+	Plugin        string `json:"plugin,omitempty"`	// pbft, noops
+	Mode          string `json:"mode,omitempty"`	// batch
+	N             int    `json:"n,omitempty"`
+	F             int    `json:"f,omitempty"`
+	BatchSize     int    `json:"batchSize,omitempty"`
+	BatchTimeout  string `json:"batchTimeout,omitempty"`
+	K             int    `json:"k,omitempty"`
+	LogMultiplier int    `json:"logMultiplier,omitempty"`
+}
+
+type NetworkProfile struct {
+	Version    string            `json:"version"`
+	Name       string            `json:"name"`
+	Peers      []ProfilePeer     `json:"peers"`
+	CA         *ProfileCA        `json:"ca,omitempty"`
+	Users      []ProfileUser     `json:"users"`
+	TLS        ProfileTLS        `json:"tls"`
+	Controller ProfileController `json:"nodeController"`
+	Consensus  ProfileConsensus  `json:"consensus"`
+}
+
+func UnmarshalNetworkProfile(reader io.Reader) (*NetworkProfile, error) {
+	decoder := json.NewDecoder(reader)
+	profile := new(NetworkProfile)
+	if err := decoder.Decode(profile); err != nil {
+		return nil, errors.New("could not decode network profile: " + err.Error())
+	}
+	return profile, nil
+}
+
+func LoadNetworkProfile(path string) (*NetworkProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return UnmarshalNetworkProfile(file)
+}
+
+func (profile *NetworkProfile) Write(writer io.Writer) error {
+	out, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(out, '\n'))
+	return err
+}
+
+func (peer ProfilePeer) ContainerName() string {
+	if peer.Container != "" {
+		return peer.Container
+	}
+	return peer.Name
+}
+
+func (peer ProfilePeer) PeerRole() string {
+	if peer.Role == "" {
+		return RoleVP
+	}
+	return strings.ToUpper(peer.Role)
+}
+
+/*
+  checks the profile and returns every problem found, or nil if it is valid.
+*/
+func (profile *NetworkProfile) Validate() []error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	validEndpoint := func(what string, e Endpoint) {
+		if e.Host == "" {
+			add("%s: host is missing", what)
+		}
+		if port, err := strconv.Atoi(e.Port); err != nil || port <= 0 || port > 65535 {
+			add("%s: invalid port %q", what, e.Port)
+		}
+	}
+
+	if profile.Version != NetworkProfileVersion {
+		add("unsupported version %q, expecting %q", profile.Version, NetworkProfileVersion)
+	}
+	if profile.Name == "" {
+		add("name is missing")
+	}
+	if len(profile.Peers) == 0 {
+		add("no peers")
+	}
+	peerNames := make(map[string]bool)
+	containers := make(map[string]bool)
+	numVP := 0
+	for i, peer := range profile.Peers {
+		what := fmt.Sprintf("peers[%d] %s", i, peer.Name)
+		if peer.Name == "" {
+			add("peers[%d]: name is missing", i)
+		} else if peerNames[peer.Name] {
+			add("%s: duplicate peer name", what)
+		}
+		peerNames[peer.Name] = true
+		if containers[peer.ContainerName()] {
+			add("%s: duplicate container %s", what, peer.ContainerName())
+		}
+		containers[peer.ContainerName()] = true
+		switch peer.PeerRole() {
+		case RoleVP:
+			numVP++
+		case RoleNVP:
+		default:
+			add("%s: invalid role %q, expecting VP or NVP", what, peer.Role)
+		}
+		validEndpoint(what+" rest", peer.Rest)
+		if peer.Grpc != nil {
+			validEndpoint(what+" grpc", *peer.Grpc)
+		}
+	}
+	if profile.CA != nil {
+		validEndpoint("ca endpoint", profile.CA.Endpoint)
+	}
+
+	if len(profile.Users) == 0 {
+		add("no users")
+	}
+	userNames := make(map[string]bool)
+	for i, user := range profile.Users {
+		what := fmt.Sprintf("users[%d] %s", i, user.Name)
+		if user.Name == "" {
+			add("users[%d]: name is missing", i)
+		} else if userNames[user.Name] {
+			add("%s: duplicate user name", what)
+		}
+		userNames[user.Name] = true
+		if user.Secret == "" {
+			add("%s: secret is missing", what)
+		}
+		if user.Peer != "" && !peerNames[user.Peer] {
+			add("%s: unknown peer %q", what, user.Peer)
+		}
+	}
+
+	if profile.TLS.CACert != "" {
+		if _, err := os.Stat(profile.TLS.CACert); err != nil {
+			add("tls caCert: %s", err.Error())
+		}
+	}
+	switch profile.Controller.Type {
+	case "", ControllerDocker, ControllerNone:
+	default:
+		add("nodeController: unknown type %q, expecting %s or %s", profile.Controller.Type, ControllerDocker, ControllerNone)
+	}
+
+	c := profile.Consensus
+	if c.N != 0 && c.N != numVP {
+		add("consensus: n=%d but the profile has %d validating peers", c.N, numVP)
+	}
+	if c.F < 0 || (c.N > 0 && c.F > (c.N-1)/3) {
+		add("consensus: f=%d must be between 0 and (n-1)/3", c.F)
+	}
+	if c.BatchSize < 0 || c.K < 0 || c.LogMultiplier < 0 {
+		add("consensus: batchSize, k and logMultiplier must not be negative")
+	}
+	if c.BatchTimeout != "" {
+		if _, err := time.ParseDuration(c.BatchTimeout); err != nil {
+			add("consensus: invalid batchTimeout %q", c.BatchTimeout)
+		}
+	}
+	return problems
+}
+
+/*
+  converts an old NetworkCredentials.json into a profile. The users are given the peer affinity
+  that LoadNetwork would give them (spread evenly, in order), so the converted network behaves the same.
+*/
+func ConvertNetworkCredentials(reader io.Reader) (*NetworkProfile, error) {
+	nc, err := unmarshalNetworkCredentials(reader)
+	if err != nil {
+		return nil, err
+	}
+	profile := &NetworkProfile{Version: NetworkProfileVersion, Name: nc.NAME}
+	for i, p := range nc.PEERHTTP {
+		peer := ProfilePeer{Name: p.NAME, Role: RoleVP, Rest: Endpoint{Host: p.IP, Port: p.PORT}}
+		if strings.ToUpper(p.ROLE) == RoleNVP {
+			peer.Role = RoleNVP
+		}
+		if strings.HasPrefix(strings.ToUpper(p.NAME), "PEER") {
+			peer.Name = strings.ToLower(peer.Role) + p.NAME[len("PEER"):]
+			peer.Container = p.NAME
+		}
+		if i < len(nc.PEERGRPC) {
+			peer.Grpc = &Endpoint{Host: nc.PEERGRPC[i].IP, Port: nc.PEERGRPC[i].PORT}
+		}
+		profile.Peers = append(profile.Peers, peer)
+	}
+	numPeers, numUsers := len(profile.Peers), len(nc.USERDATA)
+	for k, u := range nc.USERDATA {
+		user := ProfileUser{Name: u.USER, Secret: u.SECRET}
+		if numPeers > 0 && numUsers >= numPeers {
+			factor := numUsers / numPeers
+			if k < factor*numPeers {
+				user.Peer = profile.Peers[k/factor].Name
+			} else {
+				user.Peer = profile.Peers[k-factor*numPeers].Name	// left over users, as in initializePeers
+			}
+		}
+		profile.Users = append(profile.Users, user)
+	}
+	profile.Controller.Type = ControllerNone
+	if strings.HasPrefix(strings.ToUpper(nc.NAME), "LOCAL") || (numPeers > 0 && profile.Peers[0].Container != "") {
+		profile.Controller.Type = ControllerDocker
+	}
+	numVPs := 0
+	for _, peer := range profile.Peers {
+		if peer.Role == RoleVP {
+			numVPs++
+		}
+	}
+	profile.Consensus = ProfileConsensus{Plugin: "pbft", N: numVPs, F: (numVPs - 1) / 3}
+	return profile, nil
+}
+
+/*
+  builds the PeerNetwork described by a profile. Besides ip, port and name (the container), each
+  peer detail map has the peer ID (peerid), role, and grpc-host and grpc-port when known.
+*/
+func NetworkFromProfile(profile *NetworkProfile) (PeerNetwork, error) {
+	if problems := profile.Validate(); len(problems) > 0 {
+		return PeerNetwork{}, errors.New("invalid network profile " + profile.Name + ": " + problems[0].Error())
+	}
+	peers := make([]Peer, len(profile.Peers))
+	index := make(map[string]int)
+	for i, pp := range profile.Peers {
+		details := map[string]string{
+			"ip":     pp.Rest.Host,
+			"port":   pp.Rest.Port,
+			"name":   pp.ContainerName(),
+			"peerid": pp.Name,
+			"role":   pp.PeerRole(),
+		}
+		if pp.Grpc != nil && pp.Grpc.Host != "" {
+			details["grpc-host"] = pp.Grpc.Host
+			details["grpc-port"] = pp.Grpc.Port
+		}
+		peers[i] = Peer{PeerDetails: details, UserData: make(map[string]string), State: RUNNING, StateSince: time.Now()}
+		index[pp.Name] = i
+	}
+	next := 0
+	for _, u := range profile.Users {
+		if u.Peer != "" {
+			peers[index[u.Peer]].UserData[u.Name] = u.Secret
+		} else {
+			peers[next%len(peers)].UserData[u.Name] = u.Secret
+			next++
+		}
+	}
+	return PeerNetwork{Peers: peers, Name: profile.Name}, nil
+}
+
+/*
+  returns the TLS configuration to reach the peers of a profile with: verifying their certificates with the
+  CA certificate of the profile (else the system CAs), under the serverHostOverride name; nil if TLS is not enabled.
+*/
+func TLSConfigFromProfile(profile *NetworkProfile) (*tls.Config, error) {
+	if !profile.TLS.Enabled {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: profile.TLS.ServerHostOverride, InsecureSkipVerify: profile.TLS.InsecureSkipVerify}
+	if profile.TLS.CACert != "" {
+		pem, err := ioutil.ReadFile(profile.TLS.CACert)
+		if err != nil {
+			return nil, errors.New("tls caCert: " + err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tls caCert: no PEM certificate in " + profile.TLS.CACert)
+		}
+	}
+	return config, nil
+}
+
+/*
+  makes peerrest reach the peers of a profile over https with its TLS settings, if it enables TLS.
+*/
+func applyProfileTLS(profile *NetworkProfile) error {
+	config, err := TLSConfigFromProfile(profile)
+	if err != nil || config == nil {
+		return err
+	}
+	for _, peer := range profile.Peers {
+		peerrest.SetTLSConfig(peer.Rest.Host+":"+peer.Rest.Port, config)
+	}
+	return nil
+}
+
+/*
+  returns the node controller a profile asks for.
+*/
+func NodeControllerFromProfile(profile *NetworkProfile) NodeController {
+	if profile.Controller.Type == ControllerNone {
+		return noController{}
+	}
+	if profile.Controller.NetToolsImage != "" {
+		return NewDockerControllerWithImage(profile.Controller.NetToolsImage)
+	}
+	return NewDockerController()
+}
+
+/*
+  noController refuses every disruption, for networks whose nodes we cannot reach.
+*/
+type noController struct{}
+
+var errNoController = errors.New("the network profile has no node controller (type none)")
+
+func (noController) Stop(PeerNetwork, string) error                         { return errNoController }
+func (noController) Start(PeerNetwork, string) error                        { return errNoController }
+func (noController) Pause(PeerNetwork, string) error                        { return errNoController }
+func (noController) Unpause(PeerNetwork, string) error                      { return errNoController }
+func (noController) Kill(PeerNetwork, string) error                         { return errNoController }
+func (noController) KillAndWipe(PeerNetwork, string) error                  { return errNoController }
+func (noController) Partition(PeerNetwork, ...[]string) error               { return errNoController }
+func (noController) DropLink(PeerNetwork, string, string) error             { return errNoController }
+func (noController) Heal(PeerNetwork) error                                 { return nil }
+func (noController) DroppedLinks() []string                                 { return nil }
+func (noController) Degrade(PeerNetwork, string, LinkProfile) error         { return errNoController }
+func (noController) Restore(PeerNetwork, string) error                      { return nil }
+func (noController) DegradedPeers() []string                                { return nil }
+func (noController) Throttle(PeerNetwork, string, ResourceLimits) error     { return errNoController }
+func (noController) Unthrottle(PeerNetwork, string) error                   { return nil }
+func (noController) ThrottledPeers() []string                               { return nil }
+func (noController) AddPeer(n PeerNetwork, _ NewPeer) (PeerNetwork, error)   { return n, errNoController }
+func (noController) Decommission(PeerNetwork, string) error                  { return errNoController }
@@ -0,0 +1,188 @@
+package peernetwork
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+  Registry of named networks, so that one test program can drive several networks at once: e.g. a v0.5
+  and a v0.6 network under the same load, or two local networks with distinct container names and ports.
+  Each network keeps its own profile and node controller. The functions that take a PeerNetwork work
+  on any of them; the single network of LoadNetwork and the global node controller are unchanged.
+  Set NETWORK_PROFILES to a comma-separated list of profile files to load them all with LoadPeerNetworks.
+*/
+
+type PeerNetworks struct {
+	PNetworks []PeerNetwork
+}
+
+type registeredNetwork struct {
+	network    PeerNetwork
+	profile    *NetworkProfile	// nil if the network was not loaded from a profile
+	controller NodeController
+}
+
+var networksLock sync.Mutex
+var networks []registeredNetwork
+
+/*
+  registers a network under its name; the controller may be nil, to use the global node controller.
+*/
+func AddAPeerNetwork(thisNetwork PeerNetwork, controller NodeController) error {
+	return addNetwork(registeredNetwork{network: thisNetwork, controller: controller})
+}
+
+/*
+  loads a network profile and registers the network it describes, with the node controller it names.
+*/
+func AddPeerNetworkFromProfile(path string) (PeerNetwork, error) {
+	profile, err := LoadNetworkProfile(path)
+	if err != nil {
+		return PeerNetwork{}, errors.New(path + ": " + err.Error())
+	}
+	thisNetwork, err := NetworkFromProfile(profile)
+	if err != nil {
+		return PeerNetwork{}, errors.New(path + ": " + err.Error())
+	}
+	if err = addNetwork(registeredNetwork{network: thisNetwork, profile: profile, controller: NodeControllerFromProfile(profile)}); err != nil {
+		return PeerNetwork{}, err
+	}
+	if err = applyProfileTLS(profile); err != nil {
+		DeleteAPeerNetwork(thisNetwork.Name)
+		return PeerNetwork{}, errors.New(path + ": " + err.Error())
+	}
+	fmt.Println("Added network " + thisNetwork.Name + " from profile " + path)
+	return thisNetwork, nil
+}
+
+func addNetwork(rn registeredNetwork) error {
+	if rn.network.Name == "" {
+		return errors.New("cannot register a network without a name")
+	}
+	networksLock.Lock()
+	defer networksLock.Unlock()
+	for _, n := range networks {
+		if n.network.Name == rn.network.Name {
+			return errors.New("network " + rn.network.Name + " is already registered")
+		}
+	}
+	networks = append(networks, rn)
+	return nil
+}
+
+/*
+  removes a network from the registry; returns false if there was none with this name.
+*/
+func DeleteAPeerNetwork(name string) bool {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+	for i, n := range networks {
+		if n.network.Name == name {
+			networks = append(networks[:i], networks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  registers the networks of the profiles listed in NETWORK_PROFILES that are not registered yet,
+  and returns all registered networks, in the order they were added.
+*/
+func LoadPeerNetworks() PeerNetworks {
+	for _, path := range strings.Split(os.Getenv("NETWORK_PROFILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		profile, err := LoadNetworkProfile(path)
+		if err != nil {
+			fmt.Println("WARNING: " + path + ": " + err.Error())
+			continue
+		}
+		if _, found := lookupNetwork(profile.Name); found {
+			continue
+		}
+		if _, err = AddPeerNetworkFromProfile(path); err != nil {
+			fmt.Println("WARNING: " + err.Error())
+		}
+	}
+	networksLock.Lock()
+	defer networksLock.Unlock()
+	var all PeerNetworks
+	for _, n := range networks {
+		all.PNetworks = append(all.PNetworks, n.network)
+	}
+	return all
+}
+
+/*
+  returns the registered network with this name (loading NETWORK_PROFILES first, if needed).
+*/
+func LoadNetworkByName(name string) (PeerNetwork, error) {
+	if rn, found := lookupNetwork(name); found {
+		return rn.network, nil
+	}
+	for _, n := range LoadPeerNetworks().PNetworks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return PeerNetwork{}, errors.New("network " + name + " is not registered")
+}
+
+/*
+  returns the node controller of a registered network, or the global one for other networks.
+*/
+func NodeControllerOf(thisNetwork PeerNetwork) NodeController {
+	if rn, found := lookupNetwork(thisNetwork.Name); found && rn.controller != nil {
+		return rn.controller
+	}
+	return GetNodeController()
+}
+
+/*
+  returns the profile of a registered network, or nil if it was not loaded from one.
+*/
+func ProfileOf(thisNetwork PeerNetwork) *NetworkProfile {
+	rn, _ := lookupNetwork(thisNetwork.Name)
+	return rn.profile
+}
+
+/*
+  returns the container name of the CA of the network: as given in its profile, else caserver.
+*/
+func CAContainer(thisNetwork PeerNetwork) string {
+	if profile := ProfileOf(thisNetwork); profile != nil && profile.CA != nil && profile.CA.Container != "" {
+		return profile.CA.Container
+	}
+	return "caserver"
+}
+
+/*
+  replaces the registered network of the same name, e.g. after a peer was added to it.
+*/
+func updateRegisteredNetwork(thisNetwork PeerNetwork) {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+	for i := range networks {
+		if networks[i].network.Name == thisNetwork.Name {
+			networks[i].network = thisNetwork
+		}
+	}
+}
+
+func lookupNetwork(name string) (registeredNetwork, bool) {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+	for _, n := range networks {
+		if n.network.Name == name {
+			return n, true
+		}
+	}
+	return registeredNetwork{}, false
+}
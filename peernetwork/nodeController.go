@@ -0,0 +1,376 @@
+package peernetwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+/*
+  The node controller is the one place that knows how to disrupt the nodes of a network.
+
+  Besides the whole-container operations (stop, start, pause, unpause, kill) already done by the
+  *PeerLocal functions, it can cut the network links between peers: isolate sets of peers from
+  each other, drop the link between two specific peers, and heal everything again.
+
+  DockerController does this for a local docker network with iptables rules inside the network
+  namespace of each peer container. The rules are added by a short-lived helper container that
+  joins that namespace (docker run --net container:PEERn --cap-add NET_ADMIN), so the fabric peer
+  image does not need iptables. Note a stopped and restarted container gets a fresh namespace,
+  which drops its rules; pause/unpause keeps them.
+
+  The same helper container applies netem queueing disciplines (see linkQuality.go) to degrade,
+  rather than cut, the links of a peer; and docker update changes the CPU quota and memory limit
+  of a running peer (see resourceLimits.go) to make it a slow or starved replica.
+*/
+
+type NodeController interface {
+	Stop(thisNetwork PeerNetwork, peer string) error
+	Start(thisNetwork PeerNetwork, peer string) error
+	Pause(thisNetwork PeerNetwork, peer string) error
+	Unpause(thisNetwork PeerNetwork, peer string) error
+	// Kill stops a peer with SIGKILL, like a crash; KillAndWipe also deletes its ledger, so it restarts empty.
+	Kill(thisNetwork PeerNetwork, peer string) error
+	KillAndWipe(thisNetwork PeerNetwork, peer string) error
+
+	// Partition cuts every link between peers in different groups; links inside a group stay up.
+	// Peers not named in any group are left untouched.
+	Partition(thisNetwork PeerNetwork, groups ...[]string) error
+	DropLink(thisNetwork PeerNetwork, peerA string, peerB string) error
+	// Heal restores every link dropped by Partition or DropLink.
+	Heal(thisNetwork PeerNetwork) error
+	DroppedLinks() []string
+
+	// Degrade makes the links of a peer slow or lossy as described by the profile; Restore clears it.
+	Degrade(thisNetwork PeerNetwork, peer string, profile LinkProfile) error
+	Restore(thisNetwork PeerNetwork, peer string) error
+	DegradedPeers() []string
+
+	// Throttle limits the CPU and memory of a running peer; Unthrottle gives back its original limits.
+	Throttle(thisNetwork PeerNetwork, peer string, limits ResourceLimits) error
+	Unthrottle(thisNetwork PeerNetwork, peer string) error
+	ThrottledPeers() []string
+
+	// AddPeer launches a new peer that joins the network (see membership.go); Decommission removes one for good.
+	AddPeer(thisNetwork PeerNetwork, spec NewPeer) (PeerNetwork, error)
+	Decommission(thisNetwork PeerNetwork, peer string) error
+}
+
+const (
+	DefaultNetToolsImage = "nicolaka/netshoot"	// any image with sh, iptables and tc (iproute2)
+	faultChain           = "OBCSDK"		// iptables chain holding our rules, so Heal can flush just those
+)
+
+// Image of the helper container of a new DockerController; override with env var NET_TOOLS_IMAGE,
+// or per network with the netToolsImage of its profile.
+var NetToolsImage = envString("NET_TOOLS_IMAGE", DefaultNetToolsImage)
+
+var nodeController NodeController = NewDockerController()
+
+func GetNodeController() NodeController {
+	return nodeController
+}
+
+func SetNodeController(controller NodeController) {
+	nodeController = controller
+}
+
+func envString(envvar string, defaultValue string) string {
+	value := strings.TrimSpace(os.Getenv(envvar))
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+type DockerController struct {
+	droppedLinks  map[[2]string]bool		// the containers at both ends of each dropped link, in sorted order
+	degradedPeers map[string]LinkProfile
+	origLimits    map[string]throttledContainer	// limits of each throttled container before Throttle
+	netToolsImage string				// image of the helper container
+}
+
+func NewDockerController() *DockerController {
+	return NewDockerControllerWithImage(NetToolsImage)
+}
+
+/*
+  returns a DockerController whose helper containers run the given image instead of NetToolsImage,
+  so that each network can have its own.
+*/
+func NewDockerControllerWithImage(netToolsImage string) *DockerController {
+	return &DockerController{droppedLinks: make(map[[2]string]bool), degradedPeers: make(map[string]LinkProfile),
+		origLimits: make(map[string]throttledContainer), netToolsImage: netToolsImage}
+}
+
+func (d *DockerController) Stop(thisNetwork PeerNetwork, peer string) error {
+	StopPeerLocal(thisNetwork, peer)
+	return nil
+}
+
+func (d *DockerController) Start(thisNetwork PeerNetwork, peer string) error {
+	StartPeerLocal(thisNetwork, peer)
+	return nil
+}
+
+func (d *DockerController) Pause(thisNetwork PeerNetwork, peer string) error {
+	PausePeerLocal(thisNetwork, peer)
+	return nil
+}
+
+func (d *DockerController) Unpause(thisNetwork PeerNetwork, peer string) error {
+	UnpausePeerLocal(thisNetwork, peer)
+	return nil
+}
+
+func (d *DockerController) Kill(thisNetwork PeerNetwork, peer string) error {
+	KillPeerLocal(thisNetwork, peer)
+	return nil
+}
+
+func (d *DockerController) KillAndWipe(thisNetwork PeerNetwork, peer string) error {
+	KillPeerLocal(thisNetwork, peer)
+	if err := wipePeerData(peer, d.netToolsImage); err != nil {
+		fmt.Println("ERROR: Could not wipe the data of " + peer)
+		log.Fatal(err)
+	}
+	return nil
+}
+
+func (d *DockerController) AddPeer(thisNetwork PeerNetwork, spec NewPeer) (PeerNetwork, error) {
+	return AddPeerLocal(thisNetwork, spec)
+}
+
+/*
+  removes the peer, and forgets the links, netem and limits we had set on it: they went with the container.
+*/
+func (d *DockerController) Decommission(thisNetwork PeerNetwork, peer string) error {
+	if err := DecommissionPeerLocal(thisNetwork, peer); err != nil {
+		return err
+	}
+	for link := range d.droppedLinks {
+		if link[0] == peer || link[1] == peer {
+			delete(d.droppedLinks, link)
+		}
+	}
+	delete(d.degradedPeers, peer)
+	delete(d.origLimits, peer)
+	return nil
+}
+
+func (d *DockerController) Partition(thisNetwork PeerNetwork, groups ...[]string) error {
+	fmt.Println("Partition peers into groups:", groups)
+	for g := 0; g < len(groups); g++ {
+		for h := g + 1; h < len(groups); h++ {
+			for _, peerA := range groups[g] {
+				for _, peerB := range groups[h] {
+					if err := d.DropLink(thisNetwork, peerA, peerB); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+  drops all traffic between two peers, in both directions: traffic to and from the other
+  container IP, and traffic to the host ports the other container publishes (PEER0 advertises
+  its host address, so other peers reach it through the published gRPC port).
+  If the rules cannot be added to the second peer, those added to the first one are removed.
+*/
+func (d *DockerController) DropLink(thisNetwork PeerNetwork, peerA string, peerB string) error {
+	if !LocalDockerNetwork() {
+		return errors.New("DropLink: link faults are only supported on a local docker network")
+	}
+	nameA, errA := GetFullPeerName(thisNetwork, peerA)
+	nameB, errB := GetFullPeerName(thisNetwork, peerB)
+	if errA != nil || errB != nil || nameA == nameB {
+		return errors.New("DropLink: invalid peers " + peerA + ", " + peerB)
+	}
+	if d.droppedLinks[linkKey(nameA, nameB)] {
+		return nil
+	}
+	fmt.Println("DropLink: " + nameA + " <-X-> " + nameB)
+	rulesA := dropRulesTo(thisNetwork, nameB)
+	if err := d.addFaultRules(nameA, rulesA); err != nil {
+		return err
+	}
+	if err := d.addFaultRules(nameB, dropRulesTo(thisNetwork, nameA)); err != nil {
+		if rollbackErr := d.removeFaultRules(nameA, rulesA); rollbackErr != nil {
+			d.droppedLinks[linkKey(nameA, nameB)] = true	// so Heal flushes the rules left in nameA
+		}
+		return err
+	}
+	d.droppedLinks[linkKey(nameA, nameB)] = true
+	return nil
+}
+
+/*
+  flushes the rules of every running peer. A stopped container lost its rules already; a paused one cannot
+  be entered, so its links stay recorded as dropped, for a Heal once it is unpaused.
+*/
+func (d *DockerController) Heal(thisNetwork PeerNetwork) error {
+	if len(d.droppedLinks) == 0 {
+		return nil
+	}
+	fmt.Println("Heal: restore links", d.DroppedLinks())
+	var failed, kept []string
+	for i := range thisNetwork.Peers {
+		name := thisNetwork.Peers[i].PeerDetails["name"]
+		switch ContainerStatus(name) {
+		case "running":
+		case "paused":
+			kept = append(kept, name)
+			continue
+		default:
+			continue
+		}
+		if _, err := d.runInNetNamespace(name, "iptables -F "+faultChain+" 2>/dev/null; true"); err != nil {
+			failed = append(failed, name)
+			kept = append(kept, name)
+		}
+	}
+	for link := range d.droppedLinks {
+		if !containsName(kept, link[0]) && !containsName(kept, link[1]) {
+			delete(d.droppedLinks, link)
+		}
+	}
+	if len(d.droppedLinks) > 0 {
+		fmt.Println("Heal: links still dropped, in paused or failed peers:", d.DroppedLinks())
+	}
+	if len(failed) > 0 {
+		return errors.New("Heal: could not flush the rules of " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Returns the dropped links, as "PEER1-PEER2".
+func (d *DockerController) DroppedLinks() []string {
+	links := make([]string, 0, len(d.droppedLinks))
+	for link := range d.droppedLinks {
+		links = append(links, link[0]+"-"+link[1])
+	}
+	sort.Strings(links)
+	return links
+}
+
+func linkKey(nameA string, nameB string) [2]string {
+	if nameA > nameB {
+		nameA, nameB = nameB, nameA
+	}
+	return [2]string{nameA, nameB}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  returns the IP address of a container on its docker network(s).
+*/
+func ContainerIP(container string) string {
+	out, err := exec.Command("docker", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", container).Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+/*
+  returns the host ports published by a container.
+*/
+func ContainerHostPorts(container string) []string {
+	out, err := exec.Command("docker", "inspect", "--format", "{{json .NetworkSettings.Ports}}", container).Output()
+	if err != nil {
+		return nil
+	}
+	var ports map[string][]struct {
+		HostIp   string
+		HostPort string
+	}
+	if err = json.Unmarshal(out, &ports); err != nil {
+		return nil
+	}
+	var hostPorts []string
+	for _, bindings := range ports {
+		for _, b := range bindings {
+			if b.HostPort != "" {
+				hostPorts = append(hostPorts, b.HostPort)
+			}
+		}
+	}
+	sort.Strings(hostPorts)
+	return hostPorts
+}
+
+/*
+  builds the iptables rules that drop the traffic of a container to and from the given peer.
+*/
+func dropRulesTo(thisNetwork PeerNetwork, peername string) []string {
+	var rules []string
+	if ip := ContainerIP(peername); ip != "" {
+		rules = append(rules, "-s "+ip+" -j DROP", "-d "+ip+" -j DROP")
+	}
+	if aPeer := findPeer(thisNetwork, peername); aPeer != nil && aPeer.PeerDetails["ip"] != "" {
+		for _, port := range ContainerHostPorts(peername) {
+			rules = append(rules, "-d "+aPeer.PeerDetails["ip"]+" -p tcp --dport "+port+" -j DROP")
+		}
+	}
+	return rules
+}
+
+func (d *DockerController) addFaultRules(container string, rules []string) error {
+	if len(rules) == 0 {
+		return errors.New("no address found to block for container " + container)
+	}
+	script := "iptables -N " + faultChain + " 2>/dev/null; " +
+		"iptables -C INPUT -j " + faultChain + " 2>/dev/null || iptables -I INPUT -j " + faultChain + "; " +
+		"iptables -C OUTPUT -j " + faultChain + " 2>/dev/null || iptables -I OUTPUT -j " + faultChain
+	for _, rule := range rules {
+		script += " && iptables -A " + faultChain + " " + rule
+	}
+	_, err := d.runInNetNamespace(container, script)
+	return err
+}
+
+/*
+  removes rules added by addFaultRules, e.g. to roll back half a DropLink.
+*/
+func (d *DockerController) removeFaultRules(container string, rules []string) error {
+	var deletes []string
+	for _, rule := range rules {
+		deletes = append(deletes, "iptables -D "+faultChain+" "+rule)
+	}
+	_, err := d.runInNetNamespace(container, strings.Join(deletes, " && "))
+	return err
+}
+
+/*
+  runs a shell script in a helper container sharing the network namespace of the given container.
+*/
+func (d *DockerController) runInNetNamespace(container string, script string) (string, error) {
+	out, err := exec.Command("docker", "run", "--rm", "--net", "container:"+container, "--cap-add", "NET_ADMIN",
+		d.netToolsImage, "sh", "-c", script).CombinedOutput()
+	if err != nil {
+		fmt.Println("ERROR: Could not run in network of " + container + ": " + script)
+		fmt.Println(string(out))
+		return string(out), errors.New("network command failed in " + container + ": " + err.Error())
+	}
+	return string(out), nil
+}
@@ -0,0 +1,61 @@
+package peernetwork
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+/*
+  Wiping the data of a stopped peer container: its ledger (blockchain and world state db) is
+  under the peer file system path, which is not a docker volume but a directory in the container
+  writable layer. With the overlay storage drivers that layer is a plain directory on the docker
+  host (GraphDriver.Data.UpperDir), which a helper container can mount and empty while the peer
+  is stopped. For other storage drivers, the peer is started, its data deleted with docker exec,
+  and it is killed again right away; the peer may write a little in between, so prefer overlay.
+*/
+
+// Directory where the fabric peer keeps its ledger and state (peer.fileSystemPath in core.yaml);
+// override with env var PEER_DATA_PATH.
+var PeerDataPath = envString("PEER_DATA_PATH", "/var/hyperledger/production")
+
+func WipePeerDataLocal(container string) error {
+	return wipePeerData(container, NetToolsImage)
+}
+
+// wipes the data of a stopped peer container, with a helper container of the given image
+func wipePeerData(container string, netToolsImage string) error {
+	if ContainerIsRunning(container) {
+		return errors.New("WipePeerDataLocal: " + container + " must be stopped first")
+	}
+	out, err := exec.Command("docker", "inspect", "--format", "{{.GraphDriver.Name}} {{.GraphDriver.Data.UpperDir}}", container).Output()
+	if err != nil {
+		return errors.New("WipePeerDataLocal: could not inspect " + container + ": " + err.Error())
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 2 && strings.HasPrefix(fields[0], "overlay") && strings.HasPrefix(fields[1], "/") {
+		fmt.Println("Wipe data of " + container + ": " + PeerDataPath)
+		dataDir := fields[1] + PeerDataPath
+		out, err = exec.Command("docker", "run", "--rm", "-v", dataDir+":/wipe", netToolsImage,
+			"sh", "-c", "rm -rf /wipe/* /wipe/.[!.]*; true").CombinedOutput()
+		if err != nil {
+			fmt.Println(string(out))
+			return errors.New("WipePeerDataLocal: could not empty " + dataDir + ": " + err.Error())
+		}
+		return nil
+	}
+
+	fmt.Println("WARNING: storage driver of " + container + " is not overlay; wiping its data through a short restart")
+	if out, err = exec.Command("docker", "start", container).CombinedOutput(); err != nil {
+		fmt.Println(string(out))
+		return errors.New("WipePeerDataLocal: could not start " + container + ": " + err.Error())
+	}
+	out, err = exec.Command("docker", "exec", container, "sh", "-c", "rm -rf "+PeerDataPath+"/*").CombinedOutput()
+	exec.Command("docker", "kill", container).Run()
+	if err != nil {
+		fmt.Println(string(out))
+		return errors.New("WipePeerDataLocal: could not delete the data of " + container + ": " + err.Error())
+	}
+	return WaitForContainerStatus(container, "exited", StoppedTimeout)
+}
@@ -0,0 +1,207 @@
+package peernetwork
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+  Peer state reconciliation.
+
+  Peer.State used to change only when a test called SetPeerState, so a peer that crashed
+  on its own still looked RUNNING. A HealthMonitor polls every peer in the background
+  (docker container status when local, plus the REST /chain endpoint) and records what it
+  observes, with timestamps and the history of transitions, so GetPeerState tracks reality.
+*/
+
+type StateTransition struct {
+	From   int
+	To     int
+	At     time.Time
+	Source string	// who made the change: "SetPeerState" or "HealthMonitor"
+}
+
+// Guards State, StateSince and StateHistory, which the HealthMonitor updates from its own goroutine.
+var peerStateLock sync.Mutex
+
+// Number of consecutive failed REST probes of a running container before it is marked NOTRESPONDIN;
+// a single slow answer under heavy load should not take a peer out of the test expectations.
+var FailuresBeforeNotResponding = 2
+
+var stateNames = map[int]string{
+	RUNNING:        "RUNNING",
+	STOPPED:        "STOPPED",
+	STARTED:        "STARTED",
+	PAUSED:         "PAUSED",
+	UNPAUSED:       "UNPAUSED",
+	NOTRESPONDIN:   "NOTRESPONDING",
+	DECOMMISSIONED: "DECOMMISSIONED",
+}
+
+func StateName(state int) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", state)
+}
+
+/*
+  sets the state of a peer, and records the transition if the state changed.
+*/
+func recordPeerState(aPeer *Peer, newState int, source string) bool {
+	peerStateLock.Lock()
+	defer peerStateLock.Unlock()
+	return transition(aPeer, newState, source)
+}
+
+// records a change of state of a peer, with peerStateLock held
+func transition(aPeer *Peer, newState int, source string) bool {
+	if aPeer.State == newState && !aPeer.StateSince.IsZero() {
+		return false
+	}
+	now := time.Now()
+	aPeer.StateHistory = append(aPeer.StateHistory, StateTransition{From: aPeer.State, To: newState, At: now, Source: source})
+	aPeer.State = newState
+	aPeer.StateSince = now
+	return true
+}
+
+/*
+  returns the state of the peer at index i of the network, read under the lock the HealthMonitor updates it with.
+*/
+func PeerStateOf(thisNetwork PeerNetwork, i int) int {
+	peerStateLock.Lock()
+	defer peerStateLock.Unlock()
+	return thisNetwork.Peers[i].State
+}
+
+/*
+  returns a copy of a peer of a network, taken under the lock the HealthMonitor updates the states with;
+  pass a peer by value with it, rather than with *aPeer or Peers[i].
+*/
+func PeerCopy(aPeer *Peer) Peer {
+	peerStateLock.Lock()
+	defer peerStateLock.Unlock()
+	peerCopy := *aPeer
+	peerCopy.StateHistory = append([]StateTransition(nil), aPeer.StateHistory...)
+	return peerCopy
+}
+
+/*
+  returns a copy of the recorded state transitions of a peer, oldest first.
+*/
+func GetPeerStateHistory(thisNetwork PeerNetwork, peername string) []StateTransition {
+	aPeer := findPeer(thisNetwork, peername)
+	if aPeer == nil {
+		return nil
+	}
+	peerStateLock.Lock()
+	defer peerStateLock.Unlock()
+	history := make([]StateTransition, len(aPeer.StateHistory))
+	copy(history, aPeer.StateHistory)
+	return history
+}
+
+type HealthMonitor struct {
+	network  PeerNetwork
+	interval time.Duration
+	failures map[string]int
+	stop     chan bool
+	done     chan bool
+}
+
+/*
+  starts polling every peer of thisNetwork each interval, until Stop is called.
+  The peers are shared with thisNetwork (and every copy of it), so all see the updated states.
+*/
+func StartHealthMonitor(thisNetwork PeerNetwork, interval time.Duration) *HealthMonitor {
+	m := &HealthMonitor{
+		network:  thisNetwork,
+		interval: interval,
+		failures: make(map[string]int),
+		stop:     make(chan bool),
+		done:     make(chan bool),
+	}
+	fmt.Println("HealthMonitor: polling " + fmt.Sprint(len(thisNetwork.Peers)) + " peers every " + interval.String())
+	go m.run()
+	return m
+}
+
+func (m *HealthMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.PollOnce()
+		}
+	}
+}
+
+func (m *HealthMonitor) Stop() {
+	if m == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+/*
+  probes every peer once and updates its state to what was observed, unless the state changed while the
+  peer was probed (e.g. a test just stopped it): then the next poll observes it afresh.
+*/
+func (m *HealthMonitor) PollOnce() {
+	for i := range m.network.Peers {
+		aPeer := &m.network.Peers[i]
+		peerStateLock.Lock()
+		before := *aPeer
+		peerStateLock.Unlock()
+		observed := m.observe(before)
+		if recordObservedState(aPeer, before.State, observed) {
+			fmt.Println("HealthMonitor: " + aPeer.PeerDetails["name"] + " is now " + StateName(observed))
+		}
+	}
+}
+
+/*
+  sets the state of a peer to what the HealthMonitor observed, only if it is still the state it had before.
+*/
+func recordObservedState(aPeer *Peer, before int, observed int) bool {
+	peerStateLock.Lock()
+	defer peerStateLock.Unlock()
+	if aPeer.State != before {
+		return false
+	}
+	return transition(aPeer, observed, "HealthMonitor")
+}
+
+func (m *HealthMonitor) observe(aPeer Peer) int {
+	name := aPeer.PeerDetails["name"]
+	if aPeer.State == DECOMMISSIONED {
+		return DECOMMISSIONED
+	}
+	if LocalDockerNetwork() {
+		switch ContainerStatus(name) {
+		case "paused":
+			m.failures[name] = 0
+			return PAUSED
+		case "running", "restarting":
+		default:
+			m.failures[name] = 0
+			return STOPPED
+		}
+	}
+	if _, ok := ProbeChainHeight(aPeer); ok {
+		m.failures[name] = 0
+		return RUNNING
+	}
+	m.failures[name]++
+	if m.failures[name] < FailuresBeforeNotResponding {
+		return aPeer.State
+	}
+	return NOTRESPONDIN
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"log"
 	"strings"
-	"time"
 	"os/exec"
 )
 
@@ -99,17 +98,24 @@ func AddCCToLibrary(path string, name string) {
 func APeer(thisNetwork PeerNetwork) (thisPeer *Peer, err error) {
 	//thisNetwork := LoadNetwork()
 	Peers := thisNetwork.Peers
-	var aPeer *Peer
+	var aPeer, otherPeer *Peer
 	var errStr string
-	//get any running peer that has at a minimum one userData and one peerDetails
+	//get any running peer that has at a minimum one userData and one peerDetails, of the role chosen by RequestRouting
 	for peerIter := range Peers {
 		if (len(Peers[peerIter].UserData) > 0) && (len(Peers[peerIter].PeerDetails) > 0) {
-			if Peers[peerIter].State == RUNNING {
+			if PeerStateOf(thisNetwork, peerIter) == RUNNING {
 			//if Peers[peerIter].State == 0 || Peers[peerIter].State == 2 || Peers[peerIter].State == 4 {
-				aPeer = &Peers[peerIter]
+				if routable(PeerCopy(&Peers[peerIter])) {
+					aPeer = &Peers[peerIter]
+				} else {
+					otherPeer = &Peers[peerIter]
+				}
 			}
 		}
 	}
+	if aPeer == nil && RequestRouting == RouteNVP {
+		aPeer = otherPeer	// no NVP running: fall back to a validating peer
+	}
 	if aPeer != nil {
 		return (aPeer), nil
 	} else {
@@ -199,20 +205,14 @@ func AUserFromThisPeer(thisNetwork PeerNetwork, host string) (ip string, port st
 
 	//get a random peer that has at a minimum one userData and one peerDetails
 	//for p := range Peers {
-	for p := 0; p < len(Peers); p++  {
+	for p := 0; p < len(Peers) && aPeer == nil; p++  {
 		//fmt.Println("AUserFromThisPeer: peer %d state %d",p,Peers[p].State)
 		//if Peers[p].State == 0 || Peers[p].State == 2 || Peers[p].State == 4 {
-		if Peers[p].State == RUNNING || Peers[p].State == STARTED || Peers[p].State == UNPAUSED {
-				if (strings.Contains(host, ":")) {
-					//host: ip address
-					if strings.Contains(Peers[p].PeerDetails["ip"], host) {
-						aPeer = &Peers[p]
-					}
-				}else { //host: "vp1"
-					if strings.Contains(Peers[p].PeerDetails["name"], host) {
-						//fmt.Println("Inside name IP resolution")
-						aPeer = &Peers[p]
-					}
+		if state := PeerStateOf(thisNetwork, p); state == RUNNING || state == STARTED || state == UNPAUSED {
+				details := Peers[p].PeerDetails
+				//host: "vp1", an ip address, or ip:port; exactly, so that PEER1 is not PEER10
+				if details["name"] == host || details["ip"] == host || details["ip"] + ":" + details["port"] == host {
+					aPeer = &Peers[p]
 				}
 		}
 	}
@@ -249,7 +249,7 @@ func PeerOfThisUser_OLD(thisNetwork PeerNetwork, username string) (ip string, po
 	//get a random peer that has at a minimum one userData and one peerDetails
 	for peerIter := range Peers {
 		if (len(Peers[peerIter].UserData) > 0) && (len(Peers[peerIter].PeerDetails) > 0) {
-			if Peers[peerIter].State == RUNNING {
+			if PeerStateOf(thisNetwork, peerIter) == RUNNING {
 			//if Peers[peerIter].State == 0 || Peers[peerIter].State == 2 || Peers[peerIter].State == 4 {
 				if _, ok := Peers[peerIter].UserData[username]; ok {
 					fmt.Println("Found %s in network", username)
@@ -277,7 +277,7 @@ func PeerOfThisUser(thisNetwork PeerNetwork, username string) (ip string, port s
 	//fmt.Println("Inside function")
 	//get a random peer that has at a minimum one userData and one peerDetails
 	for peerIter := range Peers {
-		if len(Peers[peerIter].UserData) > 0 && len(Peers[peerIter].PeerDetails) > 0 && (Peers[peerIter].State == RUNNING ||  Peers[peerIter].State == STARTED){
+		if len(Peers[peerIter].UserData) > 0 && len(Peers[peerIter].PeerDetails) > 0 && (PeerStateOf(thisNetwork, peerIter) == RUNNING ||  PeerStateOf(thisNetwork, peerIter) == STARTED){
 				if _, ok := Peers[peerIter].UserData[username]; ok {
 					//fmt.Printf("Found %s in network on peer %d\n", username, peerIter)
 					fmt.Printf("Found %s in network on peer %d\n", username, peerIter)
@@ -307,7 +307,9 @@ func PeerOfThisUser(thisNetwork PeerNetwork, username string) (ip string, port s
 
 
 /*Gets the peer details corresponding to a given peer-name
-state if running/stopped/unresponsive/paused:0/1/2/3
+state if running/stopped/started/paused/unpaused/notresponding:0/1/2/3/4/5
+When a HealthMonitor is running, State, StateSince and StateHistory reflect the observed availability of the peer;
+the peer returned is a copy, taken under the lock the HealthMonitor updates them with.
 err	is an error message, or nil if no error occurred.
 */
 func GetPeerState(thisNetwork PeerNetwork, peername string) (currPeer *Peer, err error) {
@@ -319,7 +321,7 @@ func GetPeerState(thisNetwork PeerNetwork, peername string) (currPeer *Peer, err
 	fullName, _ := GetFullPeerName(thisNetwork, peername)
 	for peerIter := range Peers {
 		if (len(Peers[peerIter].UserData) > 0) && (len(Peers[peerIter].PeerDetails) > 0) {
-			if strings.Contains(Peers[peerIter].PeerDetails["name"], fullName) {
+			if Peers[peerIter].PeerDetails["name"] == fullName {
 				aPeer = &Peers[peerIter]
 			}
 		}
@@ -330,7 +332,8 @@ func GetPeerState(thisNetwork PeerNetwork, peername string) (currPeer *Peer, err
 		emptyPD := new(Peer)
 		return emptyPD, errors.New(errStr)
 	} else {
-		return aPeer, nil
+		peerCopy := PeerCopy(aPeer)
+		return &peerCopy, nil
 	}
 }
 
@@ -350,7 +353,7 @@ func SetPeerState(thisNetwork PeerNetwork, peername string, curstate int) (peerD
 	fullName, _ := GetFullPeerName(thisNetwork, peername)
 	for peerIter := range Peers {
 		if (len(Peers[peerIter].UserData) > 0) && (len(Peers[peerIter].PeerDetails) > 0) {
-			if strings.Contains(Peers[peerIter].PeerDetails["name"], fullName) {
+			if Peers[peerIter].PeerDetails["name"] == fullName {
 				aPeer = &Peers[peerIter]
 			}
 		}
@@ -361,8 +364,8 @@ func SetPeerState(thisNetwork PeerNetwork, peername string, curstate int) (peerD
 		emptyPD := make(map[string]string)
 		return emptyPD, errors.New(errStr)
 	} else {
-		aPeer.State = curstate
-		// fmt.Println("SetPeerState=", curstate)   // RUNNING=0 STOPPED=1 NOTRESPONDIN=2 PAUSED=3
+		recordPeerState(aPeer, curstate, "SetPeerState")
+		// fmt.Println("SetPeerState=", curstate)   // RUNNING=0 STOPPED=1 STARTED=2 PAUSED=3 UNPAUSED=4 NOTRESPONDIN=5
 		return aPeer.PeerDetails, nil
 	}
 }
@@ -384,8 +387,9 @@ func PausePeersLocal(thisNetwork PeerNetwork, peers []string) {
 		//fmt.Println("Paused peer " + peers[i])
 		SetPeerState(thisNetwork, peers[i], PAUSED)
 	}
-	fmt.Println("After pause peers, sleep 5 secs")
-	time.Sleep(5000 * time.Millisecond)
+	for i:=0 ; i < len(peers); i++ {
+		waitForPeerDown(thisNetwork, peers[i], "paused")
+	}
 }
 
 
@@ -399,8 +403,7 @@ func PausePeerLocal(thisNetwork PeerNetwork, peer string) {
 			log.Fatal(err)
 		} else {
 			//fmt.Println("Paused peer " + peer)
-			fmt.Println("After pause peer, sleep 5 secs")
-			time.Sleep(5000 * time.Millisecond)
+			waitForPeerDown(thisNetwork, peer, "paused")
 			SetPeerState(thisNetwork, peer, PAUSED)
 	}
 
@@ -420,10 +423,10 @@ func UnpausePeersLocal(thisNetwork PeerNetwork, peers []string) {
                 }
 		//exec.Command(cmd)
 		//fmt.Println("Unpaused peer " + peers[i])
-		SetPeerState(thisNetwork, peers[i], RUNNING)
 	}
-	fmt.Println("After unpause peers, sleep 5 secs")
-	time.Sleep(5000 * time.Millisecond)
+	for i:=0; i < len(peers); i++ {
+		SetPeerState(thisNetwork, peers[i], waitForPeerUp(thisNetwork, peers[i]))
+	}
 }
 
 
@@ -438,9 +441,7 @@ func UnpausePeerLocal(thisNetwork PeerNetwork, peer string) {
 			fmt.Println(out)
 			log.Fatal(err)
         } else {
-			fmt.Println("After unpause peer, sleep 5 secs")
-			time.Sleep(5000 * time.Millisecond)
-			SetPeerState(thisNetwork, peer, RUNNING)
+			SetPeerState(thisNetwork, peer, waitForPeerUp(thisNetwork, peer))
 	}
 }
 
@@ -475,8 +476,9 @@ func genCMD( keyword<stop|restart|pause|unpause>    {
                 }
 		SetPeerState(thisNetwork, peers[i], STOPPED)
 	}
-	fmt.Println("After stop peers, sleep 5 secs")
-	time.Sleep(5000 * time.Millisecond)
+	for i:=0; i < len(peers); i++ {
+		waitForPeerDown(thisNetwork, peers[i], "exited")
+	}
 }
 
 func StartPeersLocal(thisNetwork PeerNetwork, peers []string) {
@@ -488,12 +490,10 @@ func StartPeersLocal(thisNetwork PeerNetwork, peers []string) {
 			fmt.Println("StartPeersLocal: Could not exec docker start " + peers[i])
 			fmt.Println(out)
 			log.Fatal(err)
-		} else {
-			//exec.Command(cmd)
-			SetPeerState(thisNetwork, peers[i], RUNNING)
 		}
-		fmt.Println("After start peers, sleep 5 secs")
-		time.Sleep(5000 * time.Millisecond)
+	}
+	for i:=0; i < len(peers); i++ {
+		SetPeerState(thisNetwork, peers[i], waitForPeerUp(thisNetwork, peers[i]))
 	}
 }
 func StartPeerLocal(thisNetwork PeerNetwork, peer string) {
@@ -505,10 +505,10 @@ func StartPeerLocal(thisNetwork PeerNetwork, peer string) {
 		fmt.Println(out)
 		log.Fatal(err)
 	} else {
-		if peer != "caserver" {
-			fmt.Println("After start peer, sleep 5 secs")
-			time.Sleep(5000 * time.Millisecond)
-			SetPeerState(thisNetwork, peer, RUNNING)
+		if peer != CAContainer(thisNetwork) {
+			SetPeerState(thisNetwork, peer, waitForPeerUp(thisNetwork, peer))
+		} else {
+			if err = WaitForContainerStatus(peer, "running", ReadyTimeout); err != nil { fmt.Println("WARNING: " + err.Error()) }
 		}
 	}
 }
@@ -522,14 +522,75 @@ func StopPeerLocal(thisNetwork PeerNetwork, peer string) {
 	   fmt.Println(out)
            log.Fatal(err)
         } else {
-		if peer != "caserver" {
-			fmt.Println("After stop peer, sleep 5 secs")
-			time.Sleep(5000 * time.Millisecond)
+		if peer != CAContainer(thisNetwork) {
+			waitForPeerDown(thisNetwork, peer, "exited")
+			SetPeerState(thisNetwork, peer, STOPPED)
+		} else {
+			if err = WaitForContainerStatus(peer, "exited", StoppedTimeout); err != nil { fmt.Println("WARNING: " + err.Error()) }
+		}
+	}
+}
+
+/*
+  kills a peer with SIGKILL: unlike docker stop, the peer gets no chance to shut down gracefully,
+  as when a node crashes or loses power.
+*/
+func KillPeerLocal(thisNetwork PeerNetwork, peer string) {
+
+	cmd := "docker kill " + peer
+	out, err := exec.Command("/bin/sh", "-c", cmd).Output()
+	if (err != nil) {
+		fmt.Println("ERROR: Could not exec docker kill " + peer)
+		fmt.Println(out)
+		log.Fatal(err)
+	} else {
+		if peer != CAContainer(thisNetwork) {
+			waitForPeerDown(thisNetwork, peer, "exited")
 			SetPeerState(thisNetwork, peer, STOPPED)
+		} else {
+			if err = WaitForContainerStatus(peer, "exited", StoppedTimeout); err != nil { fmt.Println("WARNING: " + err.Error()) }
 		}
 	}
 }
 
+/*
+  kills a peer, and deletes its ledger and state (the peer data directory), so that when it is
+  started again it has an empty ledger and must get everything by state transfer from the others.
+*/
+func KillAndWipePeerLocal(thisNetwork PeerNetwork, peer string) {
+	KillPeerLocal(thisNetwork, peer)
+	if err := WipePeerDataLocal(peer); err != nil {
+		fmt.Println("ERROR: Could not wipe the data of " + peer)
+		log.Fatal(err)
+	}
+}
+
+/*
+  waits (instead of sleeping a fixed time) until a peer we just started or unpaused
+  is ready, and returns the state to record for it: RUNNING, or NOTRESPONDIN if the
+  peer did not answer /chain before ReadyTimeout expired.
+*/
+func waitForPeerUp(thisNetwork PeerNetwork, peer string) int {
+	if err := WaitForPeerReady(thisNetwork, peer, ReadyTimeout); err != nil {
+		fmt.Println("WARNING: " + err.Error())
+		return NOTRESPONDIN
+	}
+	return RUNNING
+}
+
+/*
+  waits until a peer we just stopped or paused has reached the docker status ("exited" or "paused").
+*/
+func waitForPeerDown(thisNetwork PeerNetwork, peer string, status string) {
+	if err := WaitForPeerDown(thisNetwork, peer, status, StoppedTimeout); err != nil {
+		fmt.Println("WARNING: " + err.Error())
+	}
+}
+
+/*
+  returns the name of the peer with exactly this name (PEER1 does not match PEER10),
+  or an error if there is no such peer on the network.
+*/
 func GetFullPeerName(thisNetwork PeerNetwork, shortname string) (name string, err error) {
 	Peers := thisNetwork.Peers
 	var aPeer *Peer
@@ -538,7 +599,7 @@ func GetFullPeerName(thisNetwork PeerNetwork, shortname string) (name string, er
 	//get a peerDetails from peername
 	for peerIter := range Peers {
 		if (len(Peers[peerIter].UserData) > 0) && (len(Peers[peerIter].PeerDetails) > 0) {
-			if strings.Contains(Peers[peerIter].PeerDetails["name"], shortname) {
+			if Peers[peerIter].PeerDetails["name"] == shortname {
 				aPeer = &Peers[peerIter]
 			}
 		}
@@ -552,44 +613,3 @@ func GetFullPeerName(thisNetwork PeerNetwork, shortname string) (name string, er
 
 	}
 }
-
-func AddAPeerNetwork() {
-
-}
-
-/********************
-type PeerNetworks struct {
-	PNetworks      []PeerNetwork
-}
-
-
-func AddAPeerNetwork() {
-
-}
-
-func DeleteAPeerNetwork() {
-
-}
-
-func AddUserOnAPeer(){
-
-}
-
-func RemoveUserOnAPeer(){
-
-}
-
-
-func LoadNetworkByName(name string) PeerNetwork {
-
-  networks := LoadPeerNetworks()
-	pnetworks := networks.PNetworks
-	for peerIter := range pnetworks {
-		//fmt.Println(pnetworks[peerIter].Name)
-		if strings.Contains(pnetworks[peerIter].Name, name) {
-			return pnetworks[peerIter]
-		}
-	}
-	//return *new(PeerNetwork)
-}
-*********************/
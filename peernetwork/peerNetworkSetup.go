@@ -7,26 +7,30 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"errors"
+	"time"
 	//"github.com/pkg/sftp"
 	//"golang.org/x/crypto/ssh"
 )
 
 const (
-	RUNNING      = 0
-	STOPPED      = 1
-	STARTED      = 2
-	PAUSED       = 3
-	UNPAUSED     = 4
-	NOTRESPONDIN = 5
+	RUNNING        = 0
+	STOPPED        = 1
+	STARTED        = 2
+	PAUSED         = 3
+	UNPAUSED       = 4
+	NOTRESPONDIN   = 5
+	DECOMMISSIONED = 6	// removed from the network for good (see DecommissionPeerLocal)
 )
 
 type Peer struct {
-	PeerDetails map[string]string
-	UserData    map[string]string
-	State       int
+	PeerDetails  map[string]string
+	UserData     map[string]string
+	State        int
+	StateSince   time.Time		// when State last changed
+	StateHistory []StateTransition	// every change of State, oldest first
 }
 
 type PeerNetwork struct {
@@ -133,22 +137,9 @@ func SetupLocalNetworkWithMoreOptions(
         commit_envvar := strings.TrimSpace(os.Getenv("COMMIT"))
         if commit_envvar != "" { commitImage = commit_envvar }
 
-	// run script located in the ../automation directory
-	pwd, _ := os.Getwd()
-	if strings.Contains(pwd, "obcsdk/") {
-		// good: it looks like we are probably in a testing subdirectory of obcsdk; hopefully it is an immediate subdirectory.
-		os.Chdir(pwd + "/../automation")
-	} else {
-		if strings.Contains(pwd, "obcsdk") {
-			// ok, we are IN ../obcsdk so lets work with that...
-			os.Chdir(pwd + "/automation")
-		} else {
-			fmt.Println("peernetwork/peerNetworkSetup.go SetupLocalNetworkWithMoreOptions(): ERROR: you must be in a subdirectory of obcsdk/ when running go tests:\ncurrent pwd: ", pwd)
-			panic(errors.New("ERROR: first cd to a test directory underneath obcsdk/ to run go tests"))
-		}
-	}
-	pwd_automation, _ := os.Getwd()
-	script_cmd := pwd_automation + script_name
+	// run script located in the automation directory; it writes the networkcredentials file and the LOGFILES in its working directory
+	pwd_automation := AutomationDir()
+	script_cmd := filepath.Join(pwd_automation, script_name)
 
  // ================
  //   arg1,2     -c   - specific commit image [latest]
@@ -180,6 +171,7 @@ func SetupLocalNetworkWithMoreOptions(
 	cmd =        exec.Command(    script_cmd, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11, arg12, arg13 )
 	fmt.Println("exec.Command done")
 
+	cmd.Dir = pwd_automation
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	// cmd.Run()
@@ -193,22 +185,19 @@ func SetupLocalNetworkWithMoreOptions(
 	}
 
 	GetNC_Local()
-	os.Chdir(pwd)
-	pwd, _ = os.Getwd()
-	//fmt.Println("Returned to starting directory pwd: ", pwd)
 	//errStr := "SetupLocalNetworkWithMoreOptions done"
 	//fmt.Println(errStr)
 	//log.Fatal(errors.New(errStr))
 }
 
 func GetNC_Local() {
-	inFileName := "../automation/networkcredentials"
+	inFileName := filepath.Join(AutomationDir(), "networkcredentials")
 
 	inputfile, err := os.Open(inFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	outFileName := "../util/NetworkCredentials.json"
+	outFileName := NetworkCredentialsFile()
 	outfile, err := os.Create(outFileName)
 	if err != nil {
 		fmt.Println("Error in creating NetworkCredentials file ", err)
@@ -381,10 +370,31 @@ func callSFTP(connection *ssh.Client) {
 }*/
 
 /*
-  creates network as defined in NetworkCredentials.json, distributing users evenly among the peers of the network.
+  creates network as defined in NetworkCredentials.json, distributing users evenly among the peers of the network;
+  or, when env var NETWORK_PROFILE names a network profile file, as defined in that profile.
 */
 func LoadNetwork() PeerNetwork {
 
+	if profilePath := strings.TrimSpace(os.Getenv("NETWORK_PROFILE")); profilePath != "" {
+		fmt.Println("Getting and Initializing Peer details from network profile", profilePath)
+		profile, err := LoadNetworkProfile(profilePath)
+		if err != nil {
+			log.Fatal("Error in loading network profile: ", err)
+		}
+		peerNetwork, err := NetworkFromProfile(profile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		SetNodeController(NodeControllerFromProfile(profile))
+		DeleteAPeerNetwork(peerNetwork.Name)	// register it (again), so CAContainer and ProfileOf know it
+		addNetwork(registeredNetwork{network: peerNetwork, profile: profile, controller: GetNodeController()})
+		FirstUser = profile.Users[0].Name
+		if err = applyProfileTLS(profile); err != nil {
+			log.Fatal("Error in loading network profile: ", err)
+		}
+		return peerNetwork
+	}
+
 	p, n := initializePeers()
 
 	peerNetwork := PeerNetwork{Peers: p, Name: n}
@@ -395,10 +405,16 @@ func LoadNetwork() PeerNetwork {
   reads CC_Collection.json and returns a library of chain codes.
 */
 func InitializeChainCodes() LibChainCodes {
-	pwd, _ := os.Getwd()
-	file, err := os.Open(pwd + "/../util/CC_Collection.json")
+	return InitializeChainCodesFrom(ChainCodeCollectionFile())
+}
+
+/*
+  reads the given chaincode collection file and returns a library of chain codes.
+*/
+func InitializeChainCodesFrom(path string) LibChainCodes {
+	file, err := os.Open(path)
 	if err != nil {
-		log.Fatal("Error in opening CC_Collection.json file ")
+		log.Fatal("Error in opening CC_Collection.json file " + path)
 	}
 
 	poolChainCode, err := unmarshalChainCodes(file)
@@ -454,6 +470,9 @@ func initializePeers() (peers []Peer, name string) {
 		aPeerDetail["port"] = peerDetails[i].PORT
 		//aPeerDetail["name"] = name
 		aPeerDetail["name"] = peerDetails[i].NAME
+		if peerDetails[i].ROLE != "" {
+			aPeerDetail["role"] = strings.ToUpper(peerDetails[i].ROLE)
+		}
 
 		//fmt.Println(aPeerDetail["ip"], aPeerDetail["port"], aPeerDetail["name"])
 
@@ -476,6 +495,7 @@ func initializePeers() (peers []Peer, name string) {
 		aPeer.PeerDetails = aPeerDetail
 		aPeer.UserData = userInfo
 		aPeer.State = RUNNING
+		aPeer.StateSince = time.Now()
 		allPeers[i] = *aPeer
 		i++
 	}
@@ -490,9 +510,9 @@ func initializePeers() (peers []Peer, name string) {
 }
 
 func initNetworkCredentials() ([]peerHTTP, []userData, string) {
-	pwd, _ := os.Getwd()
-	fmt.Println("PWD :", pwd)
-	file, err := os.Open(pwd + "/../util/NetworkCredentials.json")
+	path := NetworkCredentialsFile()
+	fmt.Println("NetworkCredentials :", path)
+	file, err := os.Open(path)
 
 	if err != nil {
 		fmt.Println("Error in opening NetworkCredentials file ", err)
@@ -668,39 +688,3 @@ func PeerOfThisUser(thisNetwork PeerNetwork, username string) (ip string, port s
 	}
 }
 *****************/
-/********************
-type PeerNetworks struct {
-	PNetworks      []PeerNetwork
-}
-
-
-func AddAPeerNetwork() {
-
-}
-
-func DeleteAPeerNetwork() {
-
-}
-
-func AddUserOnAPeer(){
-
-}
-
-func RemoveUserOnAPeer(){
-
-}
-
-
-func LoadNetworkByName(name string) PeerNetwork {
-
-  networks := LoadPeerNetworks()
-	pnetworks := networks.PNetworks
-	for peerIter := range pnetworks {
-		//fmt.Println(pnetworks[peerIter].Name)
-		if strings.Contains(pnetworks[peerIter].Name, name) {
-			return pnetworks[peerIter]
-		}
-	}
-	//return *new(PeerNetwork)
-}
-*********************/
@@ -0,0 +1,235 @@
+package peernetwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"obcsdk/peerrest"
+)
+
+/*
+  Readiness and liveness probes for the peer nodes.
+
+  Instead of sleeping a fixed time after every docker stop/start/pause/unpause, the lifecycle
+  functions wait on a predicate (container state, REST port answering /chain, and optionally
+  the chain height being close to the rest of the network) until it holds or a timeout expires.
+*/
+
+const (
+	DefaultReadyTimeout   = 60 * time.Second	// how long to wait for a started or unpaused peer to answer /chain
+	DefaultStoppedTimeout = 30 * time.Second	// how long to wait for a container to reach the stopped or paused state
+	probeInterval         = 1 * time.Second		// time between two probes while waiting
+	probeRequestTimeout   = 3 * time.Second		// a single probe must be answered within this time
+)
+
+// ReadyTimeout and StoppedTimeout may be overridden by the env vars PEER_READY_TIMEOUT
+// and PEER_STOPPED_TIMEOUT (in seconds), or set directly by a test program.
+var ReadyTimeout = envSeconds("PEER_READY_TIMEOUT", DefaultReadyTimeout)
+var StoppedTimeout = envSeconds("PEER_STOPPED_TIMEOUT", DefaultStoppedTimeout)
+
+func envSeconds(envvar string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(envvar))
+	if value == "" {
+		return defaultValue
+	}
+	secs, err := strconv.Atoi(value)
+	if err != nil || secs <= 0 {
+		fmt.Println("WARNING: ignoring invalid value (" + value + ") for " + envvar)
+		return defaultValue
+	}
+	return time.Duration(secs) * time.Second
+}
+
+/*
+  returns true when the peers run in local docker containers that we can inspect,
+  i.e. when NETWORK is unset or LOCAL (as opposed to Z or another remote network).
+*/
+func LocalDockerNetwork() bool {
+	ntwk := strings.ToUpper(strings.TrimSpace(os.Getenv("NETWORK")))
+	return ntwk == "" || ntwk == "LOCAL"
+}
+
+/*
+  returns the docker status of a container: created, restarting, running, paused, exited or dead.
+  An empty string is returned when the container cannot be inspected.
+*/
+func ContainerStatus(container string) string {
+	out, err := exec.Command("docker", "inspect", "--format", "{{.State.Status}}", container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+/*
+  returns the ID of a container, which changes when a container of the same name is created anew;
+  an empty string when the container cannot be inspected.
+*/
+func ContainerID(container string) string {
+	out, err := exec.Command("docker", "inspect", "--format", "{{.Id}}", container).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func ContainerIsRunning(container string) bool {
+	return ContainerStatus(container) == "running"
+}
+
+/*
+  finds the peer with the given name; nil if there is no such peer.
+*/
+func findPeer(thisNetwork PeerNetwork, peername string) *Peer {
+	fullName, err := GetFullPeerName(thisNetwork, peername)
+	if err != nil {
+		return nil
+	}
+	for i := range thisNetwork.Peers {
+		if thisNetwork.Peers[i].PeerDetails["name"] == fullName {
+			return &thisNetwork.Peers[i]
+		}
+	}
+	return nil
+}
+
+/*
+  builds the REST URL (http://IP:PORT or https://IP:PORT) of a peer.
+*/
+func PeerRestURL(thisPeer Peer) string {
+	protocol := "http://"
+	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" || peerrest.HasTLSConfig(thisPeer.PeerDetails["ip"]+":"+thisPeer.PeerDetails["port"]) {
+		protocol = "https://"
+	}
+	return protocol + thisPeer.PeerDetails["ip"] + ":" + thisPeer.PeerDetails["port"]
+}
+
+/*
+  asks a peer for /chain and returns its height; ok is false if the peer did not answer properly.
+*/
+func ProbeChainHeight(thisPeer Peer) (height int, ok bool) {
+	body, status, err := peerrest.ProbeChainInfo(PeerRestURL(thisPeer)+"/chain", probeRequestTimeout)
+	if err != nil || !strings.HasPrefix(status, "200") {
+		return 0, false
+	}
+	type chainMsg struct {
+		HT int `json:"height"`
+	}
+	resCh := new(chainMsg)
+	if err = json.Unmarshal([]byte(body), resCh); err != nil {
+		return 0, false
+	}
+	return resCh.HT, true
+}
+
+/*
+  returns the highest chain height reported by the running peers of the network,
+  not counting the named peer. Returns 0 if no other peer answers.
+*/
+func NetworkChainHeight(thisNetwork PeerNetwork, excludePeer string) int {
+	maxHeight := 0
+	for i := range thisNetwork.Peers {
+		if thisNetwork.Peers[i].PeerDetails["name"] == excludePeer || PeerStateOf(thisNetwork, i) != RUNNING {
+			continue
+		}
+		if ht, ok := ProbeChainHeight(PeerCopy(&thisNetwork.Peers[i])); ok && ht > maxHeight {
+			maxHeight = ht
+		}
+	}
+	return maxHeight
+}
+
+/*
+  readiness: the container is running (when local) and the REST port answers /chain.
+*/
+func PeerIsReady(thisNetwork PeerNetwork, peername string) bool {
+	aPeer := findPeer(thisNetwork, peername)
+	if aPeer == nil {
+		return false
+	}
+	if LocalDockerNetwork() && !ContainerIsRunning(aPeer.PeerDetails["name"]) {
+		return false
+	}
+	_, ok := ProbeChainHeight(PeerCopy(aPeer))
+	return ok
+}
+
+/*
+  catch-up: the peer is ready and its chain height is within withinBlocks of the
+  highest height reported by the other running peers.
+*/
+func PeerIsCaughtUp(thisNetwork PeerNetwork, peername string, withinBlocks int) bool {
+	aPeer := findPeer(thisNetwork, peername)
+	if aPeer == nil {
+		return false
+	}
+	ht, ok := ProbeChainHeight(PeerCopy(aPeer))
+	if !ok {
+		return false
+	}
+	return NetworkChainHeight(thisNetwork, aPeer.PeerDetails["name"])-ht <= withinBlocks
+}
+
+/*
+  polls cond until it returns true or the timeout expires.
+  Returns the time it took, and an error naming the description if it timed out.
+*/
+func WaitUntil(description string, timeout time.Duration, cond func() bool) (time.Duration, error) {
+	start := time.Now()
+	for {
+		if cond() {
+			return time.Since(start), nil
+		}
+		if time.Since(start) >= timeout {
+			return time.Since(start), errors.New(fmt.Sprintf("timed out after %s waiting for %s", timeout, description))
+		}
+		time.Sleep(probeInterval)
+	}
+}
+
+func WaitForContainerStatus(container string, status string, timeout time.Duration) error {
+	_, err := WaitUntil(container+" to be "+status, timeout, func() bool { return ContainerStatus(container) == status })
+	return err
+}
+
+func WaitForPeerReady(thisNetwork PeerNetwork, peername string, timeout time.Duration) error {
+	elapsed, err := WaitUntil(peername+" to answer /chain", timeout, func() bool { return PeerIsReady(thisNetwork, peername) })
+	if err == nil {
+		fmt.Println("Peer " + peername + " is ready after " + elapsed.String())
+	}
+	return err
+}
+
+func WaitForPeerCaughtUp(thisNetwork PeerNetwork, peername string, withinBlocks int, timeout time.Duration) error {
+	elapsed, err := WaitUntil(peername+" to catch up within "+strconv.Itoa(withinBlocks)+" blocks", timeout,
+		func() bool { return PeerIsCaughtUp(thisNetwork, peername, withinBlocks) })
+	if err == nil {
+		fmt.Println("Peer " + peername + " caught up after " + elapsed.String())
+	}
+	return err
+}
+
+/*
+  waits until the container is no longer running (exited or paused, as requested);
+  for a remote network where we cannot inspect containers, waits until REST stops answering.
+*/
+func WaitForPeerDown(thisNetwork PeerNetwork, peername string, status string, timeout time.Duration) error {
+	if LocalDockerNetwork() {
+		return WaitForContainerStatus(peername, status, timeout)
+	}
+	_, err := WaitUntil(peername+" to stop answering /chain", timeout, func() bool {
+		aPeer := findPeer(thisNetwork, peername)
+		if aPeer == nil {
+			return true
+		}
+		_, ok := ProbeChainHeight(PeerCopy(aPeer))
+		return !ok
+	})
+	return err
+}
@@ -0,0 +1,61 @@
+package peernetwork
+
+import (
+	"strings"
+)
+
+/*
+  Peer roles: validating peers (VP) take part in consensus; non-validating peers (NVP) keep a copy
+  of the ledger and forward the transactions they receive to the validating peers. In production,
+  clients send their requests to NVPs, so the chaincode API can route its requests through them.
+
+  The role of a peer is PeerDetails["role"]: from the network profile, from the optional "role" of
+  a peer in NetworkCredentials.json, or given when the peer is added (AddPeerLocal). Peers without
+  one are validating peers.
+*/
+
+const (
+	RouteAny = "ANY"	// send requests to any running peer (the default)
+	RouteNVP = "NVP"	// send requests to the non-validating peers, or to any peer when no NVP is running
+	RouteVP  = "VP"		// send requests to the validating peers only
+)
+
+// Which peers APeer (and so the chaincode API) sends requests to; override with env var REQUEST_ROUTING.
+var RequestRouting = strings.ToUpper(envString("REQUEST_ROUTING", RouteAny))
+
+func PeerRole(aPeer Peer) string {
+	if role := strings.ToUpper(aPeer.PeerDetails["role"]); role == RoleNVP {
+		return RoleNVP
+	}
+	return RoleVP
+}
+
+func IsValidatingPeer(aPeer Peer) bool {
+	return PeerRole(aPeer) == RoleVP
+}
+
+/*
+  returns the names of the peers of the network with the given role, decommissioned ones excepted.
+*/
+func PeersWithRole(thisNetwork PeerNetwork, role string) []string {
+	var names []string
+	for i := range thisNetwork.Peers {
+		if PeerStateOf(thisNetwork, i) != DECOMMISSIONED && PeerRole(PeerCopy(&thisNetwork.Peers[i])) == role {
+			names = append(names, thisNetwork.Peers[i].PeerDetails["name"])
+		}
+	}
+	return names
+}
+
+/*
+  tells whether APeer should choose this peer for a request, given RequestRouting.
+*/
+func routable(aPeer Peer) bool {
+	switch RequestRouting {
+	case RouteNVP:
+		return PeerRole(aPeer) == RoleNVP
+	case RouteVP:
+		return PeerRole(aPeer) == RoleVP
+	}
+	return true
+}
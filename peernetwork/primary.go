@@ -0,0 +1,141 @@
+package peernetwork
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"obcsdk/peerlogs"
+)
+
+/*
+  Discovery of the current PBFT view and primary.
+
+  The peers do not publish their view: the REST API has no such resource, and the consensus
+  metadata of a block holds only the PBFT sequence number. So the view is derived from the
+  view-change events the peers log, the primary of view v being replica v mod N, and replica i
+  being the peer with CORE_PEER_ID vpi (container PEERi on a local network).
+  This needs CORE_LOGGING_LEVEL info or debug; at the default error level nothing is logged.
+*/
+
+/*
+  returns the latest view accepted by any peer and its primary (a replica id; see PeerOfReplica for the peer).
+  known is false when the logs say nothing either way (no PBFT activity logged at all, e.g. because
+  of the logging level, or because this is not a local docker network whose logs we can read).
+  When the peers logged PBFT activity but no view change, the network is in view 0 with primary 0.
+  What each peer logged is remembered, so every call reads only the lines logged since the last one.
+*/
+func DiscoverPrimary(thisNetwork PeerNetwork) (view int, primary int, known bool) {
+	if !LocalDockerNetwork() {
+		return 0, 0, false
+	}
+	n := NumberOfReplicas(thisNetwork)
+	viewLogsLock.Lock()
+	defer viewLogsLock.Unlock()
+	newView := false
+	for i := range thisNetwork.Peers {
+		vl := readViewLog(thisNetwork.Peers[i].PeerDetails["name"], n)
+		if vl == nil {
+			continue
+		}
+		if vl.newView && (!newView || vl.view > view) {
+			view, newView = vl.view, true
+		}
+		known = known || vl.active
+	}
+	if newView && n > 0 {
+		return view, view % n, true
+	}
+	return 0, 0, known
+}
+
+// What DiscoverPrimary read so far from the log of a peer container.
+type viewLog struct {
+	id      string    // the container ID: a container created anew with the same name has a new log
+	read    time.Time // the log was read up to this time
+	view    int       // the latest view the peer accepted, if newView
+	newView bool
+	active  bool // the peer logged PBFT activity
+}
+
+var viewLogs = make(map[string]*viewLog)
+var viewLogsLock sync.Mutex
+
+/*
+  reads the lines the container logged since the last read, and returns what its log says so far;
+  nil if the container cannot be inspected.
+*/
+func readViewLog(container string, n int) *viewLog {
+	id := ContainerID(container)
+	if id == "" {
+		return nil
+	}
+	vl := viewLogs[container]
+	if vl == nil || vl.id != id {
+		vl = &viewLog{id: id}
+		viewLogs[container] = vl
+	}
+	started := time.Now().Add(-time.Second) // read a line logged meanwhile again, rather than miss it
+	events, err := peerlogs.ParseContainerSince(container, vl.read, n)
+	if err != nil {
+		return vl
+	}
+	for _, ev := range events {
+		switch ev.Kind {
+		case peerlogs.NewView:
+			if !vl.newView || ev.View > vl.view {
+				vl.view, vl.newView = ev.View, true
+			}
+			vl.active = true
+		case peerlogs.BatchExecuted, peerlogs.Checkpoint, peerlogs.ViewChange:
+			vl.active = true
+		}
+	}
+	vl.read = started
+	return vl
+}
+
+/*
+  returns the PBFT replica id of the peer at index i of the network: the number in its peer ID
+  (vp2 is replica 2), or i when the peer ID is not known (networks loaded from NetworkCredentials.json).
+  Returns -1 for a non-validating peer.
+*/
+func ReplicaID(thisNetwork PeerNetwork, i int) int {
+	details := thisNetwork.Peers[i].PeerDetails
+	if !IsValidatingPeer(PeerCopy(&thisNetwork.Peers[i])) {
+		return -1
+	}
+	if peerid := details["peerid"]; strings.HasPrefix(peerid, "vp") {
+		if id, err := strconv.Atoi(peerid[2:]); err == nil {
+			return id
+		}
+	}
+	return i
+}
+
+/*
+  returns N, the number of PBFT replicas: the distinct replica ids of the validating peers, including
+  decommissioned ones (a peer that replaces a decommissioned one takes its replica id).
+*/
+func NumberOfReplicas(thisNetwork PeerNetwork) int {
+	replicas := make(map[int]bool)
+	for i := range thisNetwork.Peers {
+		if id := ReplicaID(thisNetwork, i); id >= 0 {
+			replicas[id] = true
+		}
+	}
+	return len(replicas)
+}
+
+/*
+  returns the index in the network of the peer that is now the given replica, or -1 if none is.
+*/
+func PeerOfReplica(thisNetwork PeerNetwork, replica int) int {
+	for i := range thisNetwork.Peers {
+		if PeerStateOf(thisNetwork, i) != DECOMMISSIONED && ReplicaID(thisNetwork, i) == replica {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,170 @@
+package peernetwork
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+  CPU and memory throttling of a running peer container, with docker update.
+  The limits the container had before are remembered, and Unthrottle gives back those that Throttle changed;
+  if it cannot, the peer stays recorded as throttled, so that a later Unthrottle can try again.
+*/
+
+type ResourceLimits struct {
+	CPUPercent int	// percent of one CPU the peer may use, 1-100 (or more, for several CPUs); 0 = leave unchanged
+	MemoryMB   int	// memory limit in MB, swap included; 0 = leave unchanged
+}
+
+const cpuPeriod = 100000	// CFS period in microseconds (the docker default); the quota is a fraction of it
+
+type containerLimits struct {
+	CpuPeriod  int64
+	CpuQuota   int64
+	Memory     int64
+	MemorySwap int64
+}
+
+// the limits of a throttled container before Throttle, and which of them Throttle changed
+type throttledContainer struct {
+	orig   containerLimits
+	cpu    bool
+	memory bool
+}
+
+func (l ResourceLimits) String() string {
+	return fmt.Sprintf("cpu=%d%% memory=%dMB", l.CPUPercent, l.MemoryMB)
+}
+
+func inspectLimits(container string) (containerLimits, error) {
+	var limits containerLimits
+	out, err := exec.Command("docker", "inspect", "--format",
+		"{{.HostConfig.CpuPeriod}} {{.HostConfig.CpuQuota}} {{.HostConfig.Memory}} {{.HostConfig.MemorySwap}}", container).Output()
+	if err != nil {
+		return limits, errors.New("could not inspect the limits of " + container + ": " + err.Error())
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 4 {
+		return limits, errors.New("unexpected limits of " + container + ": " + string(out))
+	}
+	values := make([]int64, 4)
+	for i, f := range fields {
+		if values[i], err = strconv.ParseInt(f, 10, 64); err != nil {
+			return limits, errors.New("unexpected limits of " + container + ": " + string(out))
+		}
+	}
+	return containerLimits{CpuPeriod: values[0], CpuQuota: values[1], Memory: values[2], MemorySwap: values[3]}, nil
+}
+
+/*
+  docker cannot remove a memory limit once set, so an originally unlimited container
+  gets back a limit equal to the memory of the docker host, which amounts to the same.
+*/
+func hostMemory() (int64, error) {
+	out, err := exec.Command("docker", "info", "--format", "{{.MemTotal}}").Output()
+	if err != nil {
+		return 0, errors.New("docker info failed: " + err.Error())
+	}
+	mem, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil || mem <= 0 {
+		return 0, errors.New("docker info gave no host memory: " + strings.TrimSpace(string(out)))
+	}
+	return mem, nil
+}
+
+func dockerUpdate(container string, args ...string) error {
+	out, err := exec.Command("docker", append(append([]string{"update"}, args...), container)...).CombinedOutput()
+	if err != nil {
+		fmt.Println("ERROR: Could not exec docker update " + strings.Join(args, " ") + " " + container)
+		fmt.Println(string(out))
+		return errors.New("docker update of " + container + " failed: " + err.Error())
+	}
+	return nil
+}
+
+func (d *DockerController) Throttle(thisNetwork PeerNetwork, peer string, limits ResourceLimits) error {
+	if !LocalDockerNetwork() {
+		return errors.New("Throttle: resource limits are only supported on a local docker network")
+	}
+	name, err := GetFullPeerName(thisNetwork, peer)
+	if err != nil {
+		return errors.New("Throttle: invalid peer " + peer)
+	}
+	if limits.CPUPercent < 0 || limits.MemoryMB < 0 || (limits.CPUPercent == 0 && limits.MemoryMB == 0) {
+		return errors.New("Throttle: invalid limits " + limits.String())
+	}
+	throttled, ok := d.origLimits[name]
+	if !ok {
+		if throttled.orig, err = inspectLimits(name); err != nil {
+			return err
+		}
+	}
+	var args []string
+	if limits.CPUPercent > 0 {
+		args = append(args, "--cpu-period", strconv.Itoa(cpuPeriod), "--cpu-quota", strconv.Itoa(cpuPeriod*limits.CPUPercent/100))
+	}
+	if limits.MemoryMB > 0 {
+		mem := strconv.Itoa(limits.MemoryMB) + "m"
+		args = append(args, "--memory", mem, "--memory-swap", mem)
+	}
+	fmt.Println("Throttle: " + name + " " + limits.String())
+	if err = dockerUpdate(name, args...); err != nil {
+		return err
+	}
+	throttled.cpu = throttled.cpu || limits.CPUPercent > 0
+	throttled.memory = throttled.memory || limits.MemoryMB > 0
+	d.origLimits[name] = throttled
+	return nil
+}
+
+func (d *DockerController) Unthrottle(thisNetwork PeerNetwork, peer string) error {
+	name, err := GetFullPeerName(thisNetwork, peer)
+	if err != nil {
+		return errors.New("Unthrottle: invalid peer " + peer)
+	}
+	throttled, ok := d.origLimits[name]
+	if !ok {
+		return nil
+	}
+	orig := throttled.orig
+	var args []string
+	if throttled.cpu {
+		period, quota := orig.CpuPeriod, orig.CpuQuota
+		if period == 0 { period = cpuPeriod }
+		if quota == 0 { quota = -1 }	// -1 = no CPU limit
+		args = append(args, "--cpu-period", strconv.FormatInt(period, 10), "--cpu-quota", strconv.FormatInt(quota, 10))
+	}
+	if throttled.memory {
+		mem, swap := orig.Memory, orig.MemorySwap
+		if mem == 0 {
+			if mem, err = hostMemory(); err != nil {
+				return errors.New("Unthrottle: cannot restore the memory of " + name + ", still throttled: " + err.Error())
+			}
+		}
+		if swap == 0 { swap = -1 }	// -1 = unlimited swap
+		args = append(args, "--memory", strconv.FormatInt(mem, 10), "--memory-swap", strconv.FormatInt(swap, 10))
+	}
+	if len(args) == 0 {
+		delete(d.origLimits, name)
+		return nil
+	}
+	fmt.Println("Unthrottle: " + name)
+	if err = dockerUpdate(name, args...); err != nil {
+		return err
+	}
+	delete(d.origLimits, name)
+	return nil
+}
+
+func (d *DockerController) ThrottledPeers() []string {
+	peers := make([]string, 0, len(d.origLimits))
+	for name := range d.origLimits {
+		peers = append(peers, name)
+	}
+	sort.Strings(peers)
+	return peers
+}
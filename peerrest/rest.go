@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"crypto/tls"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -21,7 +22,7 @@ const (
 // "NETWORK" = "Z" - would use https protocol
 
 func GetChainInfo(url string) (respBody string, respStatus string){
-	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" {
+	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" || strings.HasPrefix(url, "https://") {
 		respBody, respStatus = GetChainInfo_HTTPS(url)
 	} else  {
 		respBody, respStatus = GetChainInfo_HTTP(url)
@@ -69,7 +70,7 @@ func GetChainInfo_HTTPS(url string) (respBody string, respStatus string) {
 	//fmt.Println("GetChainInfo_HTTPS :", url)
 
         tr := &http.Transport{
-	         TLSClientConfig:    &tls.Config{RootCAs: nil},
+	         TLSClientConfig:    tlsConfigFor(url, &tls.Config{RootCAs: nil}),
 	         DisableCompression: true,
         }
         httpsclient := &http.Client{ Timeout: time.Second * waitSecs, Transport: tr }
@@ -90,12 +91,40 @@ func GetChainInfo_HTTPS(url string) (respBody string, respStatus string) {
 	}
 }
 
+/*
+  Issue a quiet GET request, for use by readiness and liveness probes that poll a peer
+  repeatedly and do not want every refused connection printed to stdout.
+	url is the GET request.
+	timeout bounds the whole request; a peer that does not answer in time is not ready.
+	respBody, respStatus are the same as GetChainInfo; err is non-nil when no HTTP response was received.
+*/
+func ProbeChainInfo(url string, timeout time.Duration) (respBody string, respStatus string, err error) {
+	httpclient := &http.Client{ Timeout: timeout }
+	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" || strings.HasPrefix(url, "https://") {
+		tr := &http.Transport{
+			TLSClientConfig:    tlsConfigFor(url, &tls.Config{InsecureSkipVerify: true}),
+			DisableCompression: true,
+		}
+		httpclient.Transport = tr
+	}
+	response, err := httpclient.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", response.Status, err
+	}
+	return string(contents), response.Status, nil
+}
+
 // Calling GetChainInfo according to http or https api according to the value in env variable "NETWORK"
 // "NETWORK" = "LOCAL" - would use a network with http protocol
 // "NETWORK" = "Z" || "NET_COMM_PROTOCOL" = "HTTPS" - we would use https protocol
 
 func PostChainAPI(url string, payLoad []byte) (respBody string, respStatus string){
-	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" {
+	if os.Getenv("NET_COMM_PROTOCOL") == "HTTPS" || os.Getenv("NETWORK") == "Z" || strings.HasPrefix(url, "https://") {
 		respBody, respStatus = PostChainAPI_HTTPS(url, payLoad)
 	} else  {
 		respBody, respStatus = PostChainAPI_HTTP(url, payLoad)
@@ -161,7 +190,7 @@ func PostChainAPI_HTTPS(url string, payLoad []byte) (respBody string, respStatus
 		fmt.Println("PostChainAPI()_HTTPS url=" + url) 
 	}
         tr := &http.Transport{
-                 TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+                 TLSClientConfig: tlsConfigFor(url, &tls.Config{InsecureSkipVerify: true}),
 	         //TLSClientConfig:    &tls.Config{RootCAs: nil},
 	         DisableCompression: true,
         }
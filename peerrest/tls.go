@@ -0,0 +1,51 @@
+package peerrest
+
+import (
+	"crypto/tls"
+	"net/url"
+	"sync"
+)
+
+/*
+  TLS settings of the peers, by the host:port of their REST API, e.g. from the tls section of a network
+  profile. A peer without settings is reached over https as before.
+*/
+
+var tlsLock sync.Mutex
+var tlsConfigs = make(map[string]*tls.Config)
+
+/*
+  sets the TLS configuration to reach the peer at hostPort (IP:PORT) with; nil removes it.
+*/
+func SetTLSConfig(hostPort string, config *tls.Config) {
+	tlsLock.Lock()
+	defer tlsLock.Unlock()
+	if config == nil {
+		delete(tlsConfigs, hostPort)
+	} else {
+		tlsConfigs[hostPort] = config
+	}
+}
+
+/*
+  returns true if the peer at hostPort (IP:PORT) is to be reached over https, as set by SetTLSConfig.
+*/
+func HasTLSConfig(hostPort string) bool {
+	tlsLock.Lock()
+	defer tlsLock.Unlock()
+	_, ok := tlsConfigs[hostPort]
+	return ok
+}
+
+// returns the TLS configuration set for the peer of the url, else defaultConfig
+func tlsConfigFor(rawurl string, defaultConfig *tls.Config) *tls.Config {
+	if u, err := url.Parse(rawurl); err == nil {
+		tlsLock.Lock()
+		config, ok := tlsConfigs[u.Host]
+		tlsLock.Unlock()
+		if ok {
+			return config
+		}
+	}
+	return defaultConfig
+}
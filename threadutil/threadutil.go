@@ -3,6 +3,7 @@ package threadutil
 
 import (
         "os"
+        "strconv"
 )
 
 // A Utility program, contains several utility methods that can be used across test programs for multithreading
@@ -32,6 +33,9 @@ func GetPeer(peerNumber int) string {
 	if os.Getenv("NETWORK") == "Z" {
 		return ZPeers[peerNumber]
 	} else {
+		if peerNumber >= len(LocalPeers) {
+			return "PEER" + strconv.Itoa(peerNumber)	// local_fabric names the containers PEER0, PEER1, ...
+		}
 		return LocalPeers[peerNumber]
 	}
 }